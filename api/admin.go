@@ -1,6 +1,7 @@
 package api
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 
@@ -9,13 +10,15 @@ import (
 	"github.com/KiloProjects/kilonova/internal/util"
 )
 
+// resetWaitingSubs puts back in the queue the working submissions that no grader holds.
+// The ones with a live lease are left alone, otherwise they would be evaluated twice
 func (s *API) resetWaitingSubs(w http.ResponseWriter, r *http.Request) {
-	err := s.sserv.BulkUpdateSubmissions(r.Context(), kilonova.SubmissionFilter{Status: kilonova.StatusWorking}, kilonova.SubmissionUpdate{Status: kilonova.StatusWaiting})
+	cnt, err := s.sserv.RequeueExpiredSubmissions(r.Context())
 	if err != nil {
 		errorData(w, err, 500)
 		return
 	}
-	returnData(w, "Reset waiting subs")
+	returnData(w, fmt.Sprintf("Reset %d waiting subs", cnt))
 }
 
 func (s *API) reevaluateSubmission(w http.ResponseWriter, r *http.Request) {
@@ -28,15 +31,19 @@ func (s *API) reevaluateSubmission(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := s.sserv.UpdateSubmission(r.Context(), args.ID, kilonova.SubmissionUpdate{Status: kilonova.StatusWaiting})
+	prio := kilonova.PriorityRejudge
+	err := s.sserv.UpdateSubmission(r.Context(), args.ID, kilonova.SubmissionUpdate{Status: kilonova.StatusWaiting, Priority: &prio})
 	if err != nil {
 		errorData(w, err, 500)
 		return
 	}
 
+	// The old verdicts are cleared too, so the submission doesn't match the verdict filters until it is evaluated again
 	var f = false
 	var zero = 0
-	err = s.stserv.UpdateSubmissionSubTests(r.Context(), args.ID, kilonova.SubTestUpdate{Done: &f, Score: &zero})
+	var verdict = ""
+	var code = kilonova.VerdictNone
+	err = s.stserv.UpdateSubmissionSubTests(r.Context(), args.ID, kilonova.SubTestUpdate{Done: &f, Score: &zero, Verdict: &verdict, VerdictCode: &code})
 	if err != nil {
 		errorData(w, err, 500)
 		return
@@ -45,6 +52,15 @@ func (s *API) reevaluateSubmission(w http.ResponseWriter, r *http.Request) {
 	returnData(w, "Reset waiting subs")
 }
 
+// graderStats returns the sandbox usage and the submissions held by the grader
+func (s *API) graderStats(w http.ResponseWriter, r *http.Request) {
+	if s.grader == nil {
+		errorData(w, "The grader is not available", http.StatusServiceUnavailable)
+		return
+	}
+	returnData(w, s.grader.Stats())
+}
+
 func (s *API) getUsers(w http.ResponseWriter, r *http.Request) {
 	r.ParseForm()
 	var args kilonova.UserFilter
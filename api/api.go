@@ -24,15 +24,19 @@ type API struct {
 	stserv  kilonova.SubTestService
 	stkserv kilonova.SubTaskService
 	aserv   kilonova.AttachmentService
+	solserv kilonova.ProblemSolutionService
 
 	manager kilonova.DataStore
 
 	testArchiveLock *sync.Mutex
+
+	grader Grader
+	jobs   *jobStore
 }
 
 // New declares a new API instance
-func New(kn *logic.Kilonova, db kilonova.TypeServicer) *API {
-	return &API{kn, db.UserService(), db.SubmissionService(), db.ProblemService(), db.ProblemListService(), db.TestService(), db.SubTestService(), db.SubTaskService(), db.AttachmentService(), kn.DM, &sync.Mutex{}}
+func New(kn *logic.Kilonova, db kilonova.TypeServicer, grader Grader) *API {
+	return &API{kn, db.UserService(), db.SubmissionService(), db.ProblemService(), db.ProblemListService(), db.TestService(), db.SubTestService(), db.SubTaskService(), db.AttachmentService(), db.ProblemSolutionService(), kn.DM, &sync.Mutex{}, grader, newJobStore()}
 }
 
 // Handler is the magic behind the API
@@ -52,6 +56,7 @@ func (s *API) Handler() http.Handler {
 		})
 
 		r.Get("/getAllUsers", s.getUsers)
+		r.Get("/graderStats", s.graderStats)
 	})
 
 	r.Route("/auth", func(r chi.Router) {
@@ -73,37 +78,58 @@ func (s *API) Handler() http.Handler {
 			r.Route("/update", func(r chi.Router) {
 				r.Post("/", s.updateProblem)
 
-				r.Post("/addTest", s.createTest)
-				r.Route("/test/{tID}", func(r chi.Router) {
-					r.Use(s.validateTestID)
-					r.Post("/data", s.saveTestData)
-					r.Post("/id", s.updateTestID)
-					r.Post("/score", s.updateTestScore)
-					r.Post("/orphan", s.orphanTest)
+				// The problem solutions are checked again after the tests or the subtasks change
+				r.Group(func(r chi.Router) {
+					r.Use(s.rechecksSolutions)
+
+					r.Post("/addTest", s.createTest)
+					r.Route("/test/{tID}", func(r chi.Router) {
+						r.Use(s.validateTestID)
+						r.Post("/data", s.saveTestData)
+						r.Post("/id", s.updateTestID)
+						r.Post("/score", s.updateTestScore)
+						r.Post("/orphan", s.orphanTest)
+					})
+
+					r.Post("/bulkDeleteTests", s.bulkDeleteTests)
+					r.Post("/bulkUpdateTestScores", s.bulkUpdateTestScores)
+					r.Post("/orphanTests", s.purgeTests)
+					r.Post("/processTestArchive", s.processTestArchive)
+					r.Post("/importPolygon", s.importPolygon)
+					r.Post("/importCMS", s.importCMS)
+
+					r.Post("/addSubTask", s.createSubTask)
+					r.Post("/updateSubTask", s.updateSubTask)
+					r.Post("/bulkUpdateSubTaskScores", s.bulkUpdateSubTaskScores)
+					r.Post("/bulkDeleteSubTasks", s.bulkDeleteSubTasks)
 				})
 
 				r.Post("/addAttachment", s.createAttachment)
 				r.With(s.validateAttachmentID).Post("/attachment/{aID}/", s.updateAttachmentMetadata)
 				r.Post("/bulkDeleteAttachments", s.bulkDeleteAttachments)
 
-				r.Post("/bulkDeleteTests", s.bulkDeleteTests)
-				r.Post("/bulkUpdateTestScores", s.bulkUpdateTestScores)
-				r.Post("/orphanTests", s.purgeTests)
-				r.Post("/processTestArchive", s.processTestArchive)
+				r.Post("/addSolution", s.createSolution)
+				r.Post("/updateSolution", s.updateSolution)
+				r.Post("/deleteSolution", s.deleteSolution)
+				r.Post("/checkSolutions", s.checkSolutions)
+
+				r.Post("/generator", s.updateGenerator)
+				r.Post("/regenerateTests", s.regenerateTests)
 
-				r.Post("/addSubTask", s.createSubTask)
-				r.Post("/updateSubTask", s.updateSubTask)
-				r.Post("/bulkUpdateSubTaskScores", s.bulkUpdateSubTaskScores)
-				r.Post("/bulkDeleteSubTasks", s.bulkDeleteSubTasks)
+				r.Post("/calibrate", s.calibrateTimeLimit)
 
 			})
 			r.Route("/get", func(r chi.Router) {
 				r.Get("/attachments", s.getAttachments)
+				r.Get("/solutions", s.getSolutions)
+				r.Get("/generator", s.getGenerator)
 
 				r.Get("/tests", s.getTests)
 				r.Get("/test", s.getTest)
 
 				r.Get("/testData", s.getTestData)
+
+				r.Get("/cmsTask", s.exportCMS)
 			})
 			r.Post("/delete", s.deleteProblem)
 		})
@@ -119,6 +145,10 @@ func (s *API) Handler() http.Handler {
 		r.With(s.MustBeAuthed).Post("/submit", s.submissionSend)
 		r.With(s.MustBeAdmin).Post("/delete", s.deleteSubmission)
 	})
+	r.With(s.MustBeAuthed).Route("/run", func(r chi.Router) {
+		r.Post("/start", s.startCustomRun)
+		r.Get("/get", s.getJob)
+	})
 	r.Route("/user", func(r chi.Router) {
 		r.With(s.MustBeAuthed).Post("/setSubVisibility", s.setSubVisibility)
 		r.With(s.MustBeAuthed).Post("/setBio", s.setBio())
@@ -3,21 +3,40 @@ package api
 import (
 	"io"
 	"net/http"
+	"path"
+	"strings"
 
 	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/internal/config"
 	"github.com/KiloProjects/kilonova/internal/util"
 )
 
 func (s *API) createAttachment(w http.ResponseWriter, r *http.Request) {
 	r.ParseMultipartForm(50 * 1024 * 1024) // 50MB
 	var args struct {
-		Visible bool   `json:"visible"`
-		Name    string `json:"name,required"`
+		Visible    bool   `json:"visible"`
+		Name       string `json:"name,required"`
+		GraderLang string `json:"grader_lang"`
 	}
 	if err := decoder.Decode(&args, r.Form); err != nil {
 		errorData(w, err, 400)
 		return
 	}
+	if args.GraderLang != "" {
+		lang, ok := config.Languages[args.GraderLang]
+		if !ok {
+			errorData(w, "Invalid grader language", 400)
+			return
+		}
+		if !lang.IsCompiled {
+			errorData(w, "Grader files are supported only for compiled languages", 400)
+			return
+		}
+		if args.Name != path.Base(args.Name) || strings.HasPrefix(args.Name, ".") {
+			errorData(w, "Invalid grader file name", 400)
+			return
+		}
+	}
 	file, _, err := r.FormFile("data")
 	if err != nil {
 		errorData(w, err, 400)
@@ -34,6 +53,8 @@ func (s *API) createAttachment(w http.ResponseWriter, r *http.Request) {
 		Visible:   args.Visible,
 		Name:      args.Name,
 		Data:      data,
+
+		GraderLang: args.GraderLang,
 	}
 
 	if err := s.aserv.CreateAttachment(r.Context(), &att); err != nil {
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/internal/config"
+	"github.com/KiloProjects/kilonova/internal/util"
+)
+
+// regenerateTimeout is the maximum duration of a test generation
+const regenerateTimeout = 30 * time.Minute
+
+func (s *API) getGenerator(w http.ResponseWriter, r *http.Request) {
+	pb := util.Problem(r)
+	returnData(w, struct {
+		GeneratorCode string `json:"generator_code"`
+		GeneratorLang string `json:"generator_lang"`
+		ValidatorCode string `json:"validator_code"`
+		ValidatorLang string `json:"validator_lang"`
+		TestScript    string `json:"test_script"`
+	}{pb.GeneratorCode, pb.GeneratorLang, pb.ValidatorCode, pb.ValidatorLang, pb.TestScript})
+}
+
+func (s *API) updateGenerator(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	var args struct {
+		GeneratorCode *string `json:"generator_code"`
+		GeneratorLang *string `json:"generator_lang"`
+		ValidatorCode *string `json:"validator_code"`
+		ValidatorLang *string `json:"validator_lang"`
+		TestScript    *string `json:"test_script"`
+	}
+	if err := decoder.Decode(&args, r.Form); err != nil {
+		errorData(w, err, 400)
+		return
+	}
+
+	for _, lang := range []*string{args.GeneratorLang, args.ValidatorLang} {
+		if lang == nil {
+			continue
+		}
+		if l, ok := config.Languages[*lang]; !ok || l.Disabled {
+			errorData(w, "Invalid language", 400)
+			return
+		}
+	}
+	// An empty script is allowed while the generator is written
+	if args.TestScript != nil && *args.TestScript != "" {
+		if _, err := kilonova.ParseTestScript(*args.TestScript); err != nil {
+			errorData(w, err, 400)
+			return
+		}
+	}
+
+	if err := s.pserv.UpdateProblem(r.Context(), util.Problem(r).ID, kilonova.ProblemUpdate{
+		GeneratorCode: args.GeneratorCode,
+		GeneratorLang: args.GeneratorLang,
+		ValidatorCode: args.ValidatorCode,
+		ValidatorLang: args.ValidatorLang,
+		TestScript:    args.TestScript,
+	}); err != nil {
+		errorData(w, err, 500)
+		return
+	}
+	returnData(w, "Updated generator")
+}
+
+// regenerateTests replaces the tests of the problem with the ones created by its generator and test script.
+// The outputs are created by the solution with the specified ID or, if it is missing, by the first solution that is expected to get AC.
+// If the generation finishes in jobWait, the result is returned directly, otherwise the job must be polled with getJob
+func (s *API) regenerateTests(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	var args struct {
+		SolutionID int `json:"solution_id"`
+	}
+	if err := decoder.Decode(&args, r.Form); err != nil {
+		errorData(w, err, 400)
+		return
+	}
+	if s.grader == nil {
+		errorData(w, "Generating tests is not available", http.StatusServiceUnavailable)
+		return
+	}
+	problem := util.Problem(r)
+
+	sols, err := s.solserv.ProblemSolutions(r.Context(), problem.ID)
+	if err != nil {
+		errorData(w, err, 500)
+		return
+	}
+	var ref *kilonova.ProblemSolution
+	for _, sol := range sols {
+		if args.SolutionID != 0 {
+			if sol.ID == args.SolutionID {
+				ref = sol
+				break
+			}
+			continue
+		}
+		if exp, err := kilonova.ParseExpectedOutcome(sol.Expected); err == nil && exp.AllAccepted() {
+			ref = sol
+			break
+		}
+	}
+	if ref == nil {
+		if args.SolutionID != 0 {
+			errorData(w, "Solution not found", 404)
+			return
+		}
+		errorData(w, "The problem has no solution that is expected to get AC, add one or choose the reference solution", 400)
+		return
+	}
+
+	s.runJob(w, r, regenerateTimeout, func(ctx context.Context) (interface{}, error) {
+		res, err := s.grader.RegenerateTests(ctx, problem, ref)
+		if err == nil {
+			s.scheduleSolutionCheck(problem.ID)
+		}
+		return res, err
+	})
+}
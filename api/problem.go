@@ -1,11 +1,18 @@
 package api
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"reflect"
 	"strconv"
+	"strings"
 
 	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval/checkers"
+	"github.com/KiloProjects/kilonova/internal/config"
 	"github.com/KiloProjects/kilonova/internal/util"
 )
 
@@ -75,6 +82,9 @@ func (s *API) initProblem(w http.ResponseWriter, r *http.Request) {
 	problem.Name = title
 	problem.AuthorID = util.User(r).ID
 	problem.ConsoleInput = consoleInput
+	// CreateProblem keeps the zero values that are valid settings, so their defaults are set here
+	problem.OutputSizeLimit = kilonova.DefaultOutputSizeLimit
+	problem.ComparatorEpsilon = kilonova.DefaultComparatorEpsilon
 	if err := s.pserv.CreateProblem(r.Context(), &problem); err != nil {
 		errorData(w, err, 500)
 		return
@@ -178,10 +188,19 @@ func (s *API) updateProblem(w http.ResponseWriter, r *http.Request) {
 		Description  *string `json:"description"`
 		ConsoleInput *bool   `json:"console_input"`
 		TestName     *string `json:"test_name"`
+		ShortCircuit *bool   `json:"short_circuit"`
+
+		SourceFileName *string `json:"source_file_name"`
+
+		ScoringPolicy kilonova.ScoringPolicy `json:"scoring_policy"`
 
 		Type       kilonova.ProblemType `json:"type"`
 		HelperCode *string              `json:"helper_code"`
 
+		CheckerProtocol   kilonova.CheckerProtocol `json:"checker_protocol"`
+		Comparator        kilonova.Comparator      `json:"comparator"`
+		ComparatorEpsilon *float64                 `json:"comparator_epsilon"`
+
 		SourceCredits *string `json:"source_credits"`
 		AuthorCredits *string `json:"author_credits"`
 
@@ -189,6 +208,10 @@ func (s *API) updateProblem(w http.ResponseWriter, r *http.Request) {
 		MemoryLimit *int     `json:"memory_limit"`
 		StackLimit  *int     `json:"stack_limit"`
 
+		OutputSizeLimit *int `json:"output_size_limit"`
+		// LanguageLimits is the JSON encoding of the language limit overrides
+		LanguageLimits *string `json:"language_limits"`
+
 		DefaultPoints *int `json:"default_points"`
 
 		Visible *bool `json:"visible"`
@@ -203,6 +226,57 @@ func (s *API) updateProblem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if args.SourceFileName != nil && strings.ContainsAny(*args.SourceFileName, "/\\.") {
+		errorData(w, "The source file name must not contain an extension or a path", 400)
+		return
+	}
+
+	if args.ScoringPolicy != "" && !args.ScoringPolicy.Valid() {
+		errorData(w, "Invalid scoring policy", 400)
+		return
+	}
+
+	if args.CheckerProtocol != "" && !args.CheckerProtocol.Valid() {
+		errorData(w, "Invalid checker protocol", 400)
+		return
+	}
+
+	if args.OutputSizeLimit != nil && *args.OutputSizeLimit < 0 {
+		errorData(w, "Output size limit can't be negative", 400)
+		return
+	}
+
+	var langLimits kilonova.LanguageLimits
+	if args.LanguageLimits != nil {
+		if err := json.Unmarshal([]byte(*args.LanguageLimits), &langLimits); err != nil {
+			errorData(w, "Invalid language limits", 400)
+			return
+		}
+		if langLimits == nil {
+			langLimits = kilonova.LanguageLimits{}
+		}
+		for lang, adj := range langLimits {
+			if _, ok := config.Languages[lang]; !ok {
+				errorData(w, fmt.Sprintf("Unknown language %q in language limits", lang), 400)
+				return
+			}
+			if adj.TimeMultiplier < 0 || adj.MemoryMultiplier < 0 {
+				errorData(w, "Language limit multipliers can't be negative", 400)
+				return
+			}
+		}
+	}
+
+	if args.Comparator != "" && !args.Comparator.Valid() {
+		errorData(w, "Invalid comparator", 400)
+		return
+	}
+
+	if args.ComparatorEpsilon != nil && *args.ComparatorEpsilon < 0 {
+		errorData(w, "Comparator epsilon can't be negative", 400)
+		return
+	}
+
 	if args.Visible != nil && !util.User(r).Admin && *args.Visible != util.Problem(r).Visible {
 		errorData(w, "You can't update visibility!", 403)
 		return
@@ -213,10 +287,19 @@ func (s *API) updateProblem(w http.ResponseWriter, r *http.Request) {
 		Description:  args.Description,
 		ConsoleInput: args.ConsoleInput,
 		TestName:     args.TestName,
+		ShortCircuit: args.ShortCircuit,
+
+		SourceFileName: args.SourceFileName,
+
+		ScoringPolicy: args.ScoringPolicy,
 
 		Type:       args.Type,
 		HelperCode: args.HelperCode,
 
+		CheckerProtocol:   args.CheckerProtocol,
+		Comparator:        args.Comparator,
+		ComparatorEpsilon: args.ComparatorEpsilon,
+
 		SourceCredits: args.SourceCredits,
 		AuthorCredits: args.AuthorCredits,
 
@@ -224,6 +307,9 @@ func (s *API) updateProblem(w http.ResponseWriter, r *http.Request) {
 		MemoryLimit: args.MemoryLimit,
 		StackLimit:  args.StackLimit,
 
+		OutputSizeLimit: args.OutputSizeLimit,
+		LanguageLimits:  langLimits,
+
 		DefaultPoints: args.DefaultPoints,
 		Visible:       args.Visible,
 	}); err != nil {
@@ -231,5 +317,25 @@ func (s *API) updateProblem(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	pb := util.Problem(r)
+	limitsChanged := (args.TimeLimit != nil && *args.TimeLimit != pb.TimeLimit) ||
+		(args.MemoryLimit != nil && *args.MemoryLimit != pb.MemoryLimit) ||
+		(args.StackLimit != nil && *args.StackLimit != pb.StackLimit) ||
+		(args.OutputSizeLimit != nil && *args.OutputSizeLimit != pb.OutputSizeLimit) ||
+		(args.LanguageLimits != nil && (len(langLimits) > 0 || len(pb.LanguageLimits) > 0) && !reflect.DeepEqual(langLimits, pb.LanguageLimits))
+	checkerChanged := (args.HelperCode != nil && *args.HelperCode != pb.HelperCode) ||
+		(args.Type != "" && args.Type != pb.Type) ||
+		(args.CheckerProtocol != "" && args.CheckerProtocol != pb.CheckerProtocol) ||
+		(args.Comparator != "" && args.Comparator != pb.Comparator) ||
+		(args.ComparatorEpsilon != nil && *args.ComparatorEpsilon != pb.ComparatorEpsilon)
+	if checkerChanged {
+		if err := checkers.InvalidateCache(pb.ID); err != nil {
+			log.Println("Couldn't invalidate checker cache:", err)
+		}
+	}
+	if limitsChanged || checkerChanged {
+		s.scheduleSolutionCheck(pb.ID)
+	}
+
 	returnData(w, "Updated problem")
 }
@@ -0,0 +1,272 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval/grader"
+	"github.com/KiloProjects/kilonova/internal/config"
+	"github.com/KiloProjects/kilonova/internal/util"
+)
+
+const (
+	// maxCustomInput is the maximum size of the input of a custom run, in bytes
+	maxCustomInput = 1024 * 1024
+
+	// A user can start at most jobLimit jobs every jobWindow, and only one at a time
+	jobLimit  = 5
+	jobWindow = time.Minute
+
+	// jobWait is how long a request waits for the job to finish, before the client has to poll it
+	jobWait = 10 * time.Second
+	// jobTTL is how long the results of a finished job are kept
+	jobTTL = 10 * time.Minute
+
+	// customRunTimeout is the maximum duration of a custom run, including the compilation
+	customRunTimeout = 2 * time.Minute
+	// calibrationTimeout is the maximum duration of a time limit calibration
+	calibrationTimeout = 15 * time.Minute
+)
+
+// Grader runs code outside of submissions and checks the problem solutions
+type Grader interface {
+	CustomRun(ctx context.Context, pb *kilonova.Problem, code, lang string, input []byte) (*grader.CustomRunResult, error)
+	Calibrate(ctx context.Context, pb *kilonova.Problem, solutions []grader.Solution, multiplier float64) (*grader.Calibration, error)
+	CheckSolutions(ctx context.Context, pb *kilonova.Problem) ([]*kilonova.ProblemSolution, error)
+	ScheduleSolutionCheck(pbID int)
+	RegenerateTests(ctx context.Context, pb *kilonova.Problem, ref *kilonova.ProblemSolution) (*grader.RegenerateResult, error)
+	CheckerBinary(ctx context.Context, pb *kilonova.Problem) ([]byte, error)
+	Stats() grader.Stats
+}
+
+// job is a grader task started from the API, whose result can be polled
+type job struct {
+	ID     string      `json:"id"`
+	Done   bool        `json:"done"`
+	Error  string      `json:"error,omitempty"`
+	Result interface{} `json:"result,omitempty"`
+
+	userID   int
+	started  time.Time
+	finished chan struct{}
+}
+
+// jobStore keeps the jobs in memory, until they expire
+type jobStore struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+	// recent holds the start times of every user's jobs from the last jobWindow
+	recent map[int][]time.Time
+}
+
+func newJobStore() *jobStore {
+	return &jobStore{jobs: make(map[string]*job), recent: make(map[int][]time.Time)}
+}
+
+// start registers a new job for the user, or returns nil if the user is over the rate limit
+func (c *jobStore) start(userID int) *job {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for id, job := range c.jobs {
+		if job.Done && now.Sub(job.started) > jobTTL {
+			delete(c.jobs, id)
+		}
+	}
+
+	var recent []time.Time
+	for _, t := range c.recent[userID] {
+		if now.Sub(t) < jobWindow {
+			recent = append(recent, t)
+		}
+	}
+	c.recent[userID] = recent
+	if len(recent) >= jobLimit {
+		return nil
+	}
+	for _, job := range c.jobs {
+		if job.userID == userID && !job.Done {
+			return nil
+		}
+	}
+
+	j := &job{ID: kilonova.RandomString(16), userID: userID, started: now, finished: make(chan struct{})}
+	c.jobs[j.ID] = j
+	c.recent[userID] = append(recent, now)
+	return j
+}
+
+func (c *jobStore) finish(j *job, res interface{}, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	j.Done = true
+	var kerr *kilonova.Error
+	if errors.As(err, &kerr) {
+		// The message is meant for users, unlike the full error
+		j.Error = kerr.Message
+	} else if err != nil {
+		j.Error = err.Error()
+	} else {
+		j.Result = res
+	}
+	close(j.finished)
+}
+
+// get returns a copy of the job, if it belongs to the user
+func (c *jobStore) get(id string, userID int) (job, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	j, ok := c.jobs[id]
+	if !ok || j.userID != userID {
+		return job{}, false
+	}
+	return *j, true
+}
+
+// runJob starts f in the background and replies with the state of the job, after it finished or after jobWait
+func (s *API) runJob(w http.ResponseWriter, r *http.Request, timeout time.Duration, f func(ctx context.Context) (interface{}, error)) {
+	user := util.User(r)
+	j := s.jobs.start(user.ID)
+	if j == nil {
+		errorData(w, "Too many runs, try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		res, err := f(ctx)
+		s.jobs.finish(j, res, err)
+	}()
+
+	select {
+	case <-j.finished:
+	case <-time.After(jobWait):
+	case <-r.Context().Done():
+	}
+
+	state, _ := s.jobs.get(j.ID, user.ID)
+	returnData(w, state)
+}
+
+// startCustomRun compiles the code and runs it on the input, with the limits of the problem.
+// If the run finishes in jobWait, its result is returned directly, otherwise it must be polled with getJob
+func (s *API) startCustomRun(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	var args struct {
+		Code      string
+		Lang      string
+		Input     string
+		ProblemID int
+	}
+	if err := decoder.Decode(&args, r.Form); err != nil {
+		errorData(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if s.grader == nil {
+		errorData(w, "Custom runs are not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	user := util.User(r)
+	problem, err := s.pserv.ProblemByID(r.Context(), args.ProblemID)
+	if err != nil || !util.IsProblemVisible(user, problem) {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			errorData(w, err, 500)
+			return
+		}
+		errorData(w, "Problem not found", http.StatusBadRequest)
+		return
+	}
+
+	if problem.Type == kilonova.ProblemTypeOutputOnly {
+		errorData(w, "Output-only problems don't run code", http.StatusBadRequest)
+		return
+	}
+	if lang, ok := config.Languages[args.Lang]; !ok || lang.Disabled {
+		errorData(w, "Invalid language", http.StatusBadRequest)
+		return
+	}
+	if args.Code == "" {
+		errorData(w, "No code sent", http.StatusBadRequest)
+		return
+	}
+	if problem.SourceSize != 0 && len(args.Code) > problem.SourceSize {
+		errorData(w, "Code too large", http.StatusBadRequest)
+		return
+	}
+	if len(args.Input) > maxCustomInput {
+		errorData(w, "Input too large", http.StatusBadRequest)
+		return
+	}
+
+	s.runJob(w, r, customRunTimeout, func(ctx context.Context) (interface{}, error) {
+		return s.grader.CustomRun(ctx, problem, args.Code, args.Lang, []byte(args.Input))
+	})
+}
+
+// calibrateTimeLimit runs reference solutions on all tests of the problem and suggests a time limit.
+// The time limit is not changed, the proposer decides if the suggestion is used
+func (s *API) calibrateTimeLimit(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	var args struct {
+		Code       []string
+		Lang       []string
+		Multiplier float64
+	}
+	if err := decoder.Decode(&args, r.Form); err != nil {
+		errorData(w, err, http.StatusBadRequest)
+		return
+	}
+
+	if s.grader == nil {
+		errorData(w, "Calibration is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	problem := util.Problem(r)
+	if problem.Type == kilonova.ProblemTypeInteractive || problem.Type == kilonova.ProblemTypeOutputOnly {
+		errorData(w, "Only problems that run a single program can be calibrated", http.StatusBadRequest)
+		return
+	}
+	if len(args.Code) == 0 || len(args.Code) != len(args.Lang) {
+		errorData(w, "Every solution must have its code and language", http.StatusBadRequest)
+		return
+	}
+	if args.Multiplier == 0 {
+		args.Multiplier = 2
+	}
+	if args.Multiplier < 1 {
+		errorData(w, "The multiplier must be at least 1", http.StatusBadRequest)
+		return
+	}
+
+	solutions := make([]grader.Solution, 0, len(args.Code))
+	for i := range args.Code {
+		if lang, ok := config.Languages[args.Lang[i]]; !ok || lang.Disabled {
+			errorData(w, "Invalid language", http.StatusBadRequest)
+			return
+		}
+		solutions = append(solutions, grader.Solution{Code: args.Code[i], Lang: args.Lang[i]})
+	}
+
+	s.runJob(w, r, calibrationTimeout, func(ctx context.Context) (interface{}, error) {
+		return s.grader.Calibrate(ctx, problem, solutions, args.Multiplier)
+	})
+}
+
+func (s *API) getJob(w http.ResponseWriter, r *http.Request) {
+	j, ok := s.jobs.get(r.FormValue("id"), util.User(r).ID)
+	if !ok {
+		errorData(w, "Job not found", http.StatusNotFound)
+		return
+	}
+	returnData(w, j)
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/internal/config"
+	"github.com/KiloProjects/kilonova/internal/util"
+	"github.com/go-chi/chi/middleware"
+)
+
+// solutionCheckTimeout is the maximum duration of a check of the problem solutions
+const solutionCheckTimeout = 30 * time.Minute
+
+func (s *API) getSolutions(w http.ResponseWriter, r *http.Request) {
+	sols, err := s.solserv.ProblemSolutions(r.Context(), util.Problem(r).ID)
+	if err != nil {
+		errorData(w, err, 500)
+		return
+	}
+	returnData(w, sols)
+}
+
+// validateSolution checks the language and the expected outcome of a solution, it returns an error message if they are invalid
+func validateSolution(pb *kilonova.Problem, lang, expected string) string {
+	if pb.Type == kilonova.ProblemTypeOutputOnly {
+		return "Output-only problems can't have solutions"
+	}
+	if l, ok := config.Languages[lang]; !ok || l.Disabled {
+		return "Invalid language"
+	}
+	if _, err := kilonova.ParseExpectedOutcome(expected); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+func (s *API) createSolution(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	var args struct {
+		Name     string `json:"name"`
+		Code     string `json:"code"`
+		Language string `json:"language"`
+		Expected string `json:"expected"`
+	}
+	if err := decoder.Decode(&args, r.Form); err != nil {
+		errorData(w, err, 400)
+		return
+	}
+	if args.Code == "" {
+		errorData(w, "The code can't be empty", 400)
+		return
+	}
+	if msg := validateSolution(util.Problem(r), args.Language, args.Expected); msg != "" {
+		errorData(w, msg, 400)
+		return
+	}
+
+	sol := kilonova.ProblemSolution{
+		ProblemID: util.Problem(r).ID,
+		Name:      args.Name,
+		Code:      args.Code,
+		Language:  args.Language,
+		Expected:  args.Expected,
+	}
+	if err := s.solserv.CreateProblemSolution(r.Context(), &sol); err != nil {
+		errorData(w, err, 500)
+		return
+	}
+	returnData(w, sol.ID)
+}
+
+func (s *API) updateSolution(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	var args struct {
+		ID       int     `json:"id"`
+		Name     *string `json:"name"`
+		Code     *string `json:"code"`
+		Language *string `json:"language"`
+		Expected *string `json:"expected"`
+	}
+	if err := decoder.Decode(&args, r.Form); err != nil {
+		errorData(w, err, 400)
+		return
+	}
+
+	sol, err := s.solserv.ProblemSolution(r.Context(), args.ID)
+	if err != nil || sol.ProblemID != util.Problem(r).ID {
+		errorData(w, "Solution not found", 404)
+		return
+	}
+	if args.Code != nil && *args.Code == "" {
+		errorData(w, "The code can't be empty", 400)
+		return
+	}
+	lang, expected := sol.Language, sol.Expected
+	if args.Language != nil {
+		lang = *args.Language
+	}
+	if args.Expected != nil {
+		expected = *args.Expected
+	}
+	if msg := validateSolution(util.Problem(r), lang, expected); msg != "" {
+		errorData(w, msg, 400)
+		return
+	}
+
+	// The result of the last check doesn't hold anymore
+	unchecked, noMsg := kilonova.SolutionUnchecked, ""
+	upd := kilonova.ProblemSolutionUpdate{
+		Name:     args.Name,
+		Code:     args.Code,
+		Language: args.Language,
+		Expected: args.Expected,
+
+		CheckStatus:  &unchecked,
+		CheckMessage: &noMsg,
+	}
+	if err := s.solserv.UpdateProblemSolution(r.Context(), sol.ID, upd); err != nil {
+		errorData(w, err, 500)
+		return
+	}
+	returnData(w, "Updated solution")
+}
+
+func (s *API) deleteSolution(w http.ResponseWriter, r *http.Request) {
+	id, ok := getFormInt(w, r, "id")
+	if !ok {
+		return
+	}
+	sol, err := s.solserv.ProblemSolution(r.Context(), id)
+	if err != nil || sol.ProblemID != util.Problem(r).ID {
+		errorData(w, "Solution not found", 404)
+		return
+	}
+	if err := s.solserv.DeleteProblemSolution(r.Context(), sol.ID); err != nil {
+		errorData(w, err, 500)
+		return
+	}
+	returnData(w, "Deleted solution")
+}
+
+// checkSolutions judges all solutions of the problem and reports the ones that didn't get the expected outcome.
+// If the check finishes in jobWait, the solutions are returned directly, otherwise the job must be polled with getJob
+func (s *API) checkSolutions(w http.ResponseWriter, r *http.Request) {
+	if s.grader == nil {
+		errorData(w, "Checking solutions is not available", http.StatusServiceUnavailable)
+		return
+	}
+	problem := util.Problem(r)
+	if problem.Type == kilonova.ProblemTypeOutputOnly {
+		errorData(w, "Output-only problems can't have solutions", http.StatusBadRequest)
+		return
+	}
+
+	s.runJob(w, r, solutionCheckTimeout, func(ctx context.Context) (interface{}, error) {
+		return s.grader.CheckSolutions(ctx, problem)
+	})
+}
+
+// scheduleSolutionCheck checks the problem solutions again in the background, since the problem changed
+func (s *API) scheduleSolutionCheck(pbID int) {
+	if s.grader != nil {
+		s.grader.ScheduleSolutionCheck(pbID)
+	}
+}
+
+// rechecksSolutions is middleware that schedules a check of the problem solutions after a successful change of the problem
+func (s *API) rechecksSolutions(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		if ww.Status() == http.StatusOK {
+			s.scheduleSolutionCheck(util.Problem(r).ID)
+		}
+	})
+}
@@ -1,18 +1,26 @@
 package api
 
 import (
+	"archive/zip"
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
 
 	"github.com/KiloProjects/kilonova"
 	"github.com/KiloProjects/kilonova/internal/config"
 	"github.com/KiloProjects/kilonova/internal/util"
 )
 
+// maxOutputArchive is the maximum size of the archive sent to an output-only problem, in bytes
+const maxOutputArchive = 32 * 1024 * 1024
+
 type subTestLine struct {
 	SubTest *kilonova.SubTest `json:"subtest"`
 	Test    *kilonova.Test    `json:"pb_test"`
@@ -271,6 +279,11 @@ func (s *API) submissionSend(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if problem.Type == kilonova.ProblemTypeOutputOnly {
+		s.outputOnlySend(w, r, user, problem)
+		return
+	}
+
 	if _, ok := config.Languages[args.Lang]; ok == false {
 		errorData(w, "Invalid language", http.StatusBadRequest)
 		return
@@ -311,7 +324,61 @@ func (s *API) submissionSend(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// add the submission along with subtests to the DB
-	sub, err := s.addSubmission(r.Context(), user.ID, problem.ID, args.Code, args.Lang, user.DefaultVisible)
+	sub, err := s.addSubmission(r.Context(), user.ID, problem.ID, args.Code, args.Lang, user.DefaultVisible, nil)
+	if err != nil {
+		log.Println(err)
+		errorData(w, err, 500)
+		return
+	}
+
+	statusData(w, "success", sub.ID, http.StatusCreated)
+}
+
+// outputOnlySend receives a zip archive with the outputs of an output-only problem, named after the tests (`1.out`, `2.out`, ...).
+// The outputs are saved as the subtest outputs, the archive itself is not kept
+func (s *API) outputOnlySend(w http.ResponseWriter, r *http.Request, user *kilonova.User, problem *kilonova.Problem) {
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		errorData(w, "No output archive sent", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	if header.Size > maxOutputArchive {
+		errorData(w, "Archive too large", http.StatusBadRequest)
+		return
+	}
+
+	ar, err := zip.NewReader(file, header.Size)
+	if err != nil {
+		errorData(w, "Invalid archive", http.StatusBadRequest)
+		return
+	}
+
+	tests, err := s.tserv.Tests(r.Context(), problem.ID)
+	if err != nil {
+		errorData(w, err, 500)
+		return
+	}
+	outputs, err := readOutputArchive(ar, tests, problem.OutputSizeLimit*1024)
+	if err != nil {
+		errorData(w, err, http.StatusBadRequest)
+		return
+	}
+
+	// The listing of the received files is saved as the code, so the submission shows what was sent
+	var code strings.Builder
+	for _, test := range tests {
+		if _, ok := outputs.files[test.VisibleID]; ok {
+			fmt.Fprintf(&code, "%d.out\n", test.VisibleID)
+		}
+	}
+	if code.Len() == 0 {
+		errorData(w, "The archive has no output for any test", http.StatusBadRequest)
+		return
+	}
+
+	sub, err := s.addSubmission(r.Context(), user.ID, problem.ID, code.String(), kilonova.LanguageOutputOnly, user.DefaultVisible, outputs)
 	if err != nil {
 		log.Println(err)
 		errorData(w, err, 500)
@@ -321,9 +388,85 @@ func (s *API) submissionSend(w http.ResponseWriter, r *http.Request) {
 	statusData(w, "success", sub.ID, http.StatusCreated)
 }
 
+// outputArchive holds the `N.out` files of an archive sent to an output-only problem, by test visible ID
+type outputArchive struct {
+	files map[int]*zip.File
+	// limit is the maximum size of an output and budget is what is left of the total size of the outputs
+	limit, budget int64
+}
+
+// readOutputArchive finds the outputs of the tests in the archive. Files that aren't named after a test are ignored.
+// No output can be larger than limit bytes, or than maxOutputArchive if limit is 0, and all of them together can't be larger than the limit times the number of tests
+func readOutputArchive(ar *zip.Reader, tests []*kilonova.Test, limit int) (*outputArchive, error) {
+	max := int64(maxOutputArchive)
+	if limit != 0 {
+		max = int64(limit)
+	}
+	outputs := &outputArchive{files: make(map[int]*zip.File), limit: max, budget: max * int64(len(tests))}
+
+	visibleIDs := make(map[int]bool, len(tests))
+	for _, test := range tests {
+		visibleIDs[test.VisibleID] = true
+	}
+
+	var total uint64
+	for _, file := range ar.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		name := path.Base(file.Name)
+		if !strings.HasSuffix(name, ".out") {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimSuffix(name, ".out"))
+		if err != nil || !visibleIDs[id] {
+			continue
+		}
+		if _, ok := outputs.files[id]; ok {
+			return nil, fmt.Errorf("Multiple outputs for test %d", id)
+		}
+		if file.UncompressedSize64 > uint64(max) {
+			return nil, fmt.Errorf("Output for test %d is too large", id)
+		}
+		if total += file.UncompressedSize64; total > uint64(outputs.budget) {
+			return nil, errors.New("The outputs are too large")
+		}
+		outputs.files[id] = file
+	}
+	return outputs, nil
+}
+
+// copyOutput writes the output of the test to w.
+// The sizes from the headers can't be trusted, so the limits are checked again while reading
+func (a *outputArchive) copyOutput(w io.Writer, id int) error {
+	f, err := a.files[id].Open()
+	if err != nil {
+		return fmt.Errorf("Could not read output for test %d", id)
+	}
+	defer f.Close()
+
+	max := a.limit
+	if a.budget < max {
+		max = a.budget
+	}
+	n, err := io.Copy(w, io.LimitReader(f, max+1))
+	if err != nil {
+		return fmt.Errorf("Could not read output for test %d", id)
+	}
+	if n > a.limit {
+		return fmt.Errorf("Output for test %d is too large", id)
+	}
+	if n > a.budget {
+		return errors.New("The outputs are too large")
+	}
+	a.budget -= n
+	return nil
+}
+
 // addSubmission adds the submission to the DB, but also creates the subtests
-// was split away from the function above because it got too big
-func (s *API) addSubmission(ctx context.Context, userID int, problemID int, code string, lang string, visible bool) (*kilonova.Submission, error) {
+// was split away from the function above because it got too big.
+// For output-only problems, outputs holds the uploaded outputs, which are written straight to the subtests
+func (s *API) addSubmission(ctx context.Context, userID int, problemID int, code string, lang string, visible bool, outputs *outputArchive) (*kilonova.Submission, error) {
 	tests, err := s.tserv.Tests(ctx, problemID)
 	if err != nil {
 		return nil, err
@@ -336,15 +479,29 @@ func (s *API) addSubmission(ctx context.Context, userID int, problemID int, code
 	sub.Code = code
 	sub.Language = lang
 	sub.Visible = visible
+	sub.Priority = kilonova.PriorityLive
 	if err := s.sserv.CreateSubmission(ctx, &sub); err != nil {
 		return nil, err
 	}
 
 	// Add subtests
 	for _, test := range tests {
-		if err := s.stserv.CreateSubTest(ctx, &kilonova.SubTest{UserID: userID, TestID: test.ID, SubmissionID: sub.ID}); err != nil {
+		st := kilonova.SubTest{UserID: userID, TestID: test.ID, SubmissionID: sub.ID}
+		if err := s.stserv.CreateSubTest(ctx, &st); err != nil {
 			return nil, err
 		}
+		if outputs == nil {
+			continue
+		}
+		if _, ok := outputs.files[test.VisibleID]; ok {
+			if err := s.saveSubTestOutput(st.ID, outputs, test.VisibleID); err != nil {
+				// The submission was never queued, so it can just be removed
+				if err1 := s.sserv.DeleteSubmission(ctx, sub.ID); err1 != nil {
+					log.Println(err1)
+				}
+				return nil, err
+			}
+		}
 	}
 
 	if err := s.sserv.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{Status: kilonova.StatusWaiting}); err != nil {
@@ -354,6 +511,18 @@ func (s *API) addSubmission(ctx context.Context, userID int, problemID int, code
 	return &sub, nil
 }
 
+func (s *API) saveSubTestOutput(subtest int, outputs *outputArchive, visibleID int) error {
+	w, err := s.manager.SubtestWriter(subtest)
+	if err != nil {
+		return err
+	}
+	if err := outputs.copyOutput(w, visibleID); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
 func (s *API) deleteSubmission(w http.ResponseWriter, r *http.Request) {
 	r.ParseForm()
 	var args struct {
@@ -5,12 +5,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"strconv"
+	"time"
 
 	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval/checkers"
 	"github.com/KiloProjects/kilonova/internal/logic"
 	"github.com/KiloProjects/kilonova/internal/util"
 )
@@ -212,10 +216,96 @@ func (s *API) processTestArchive(w http.ResponseWriter, r *http.Request) {
 		errorData(w, err, 400)
 		return
 	}
+	// The manifest of the archive might have replaced the checker
+	if err := checkers.InvalidateCache(util.Problem(r).ID); err != nil {
+		log.Println("Couldn't invalidate checker cache:", err)
+	}
 
 	returnData(w, "Processed tests")
 }
 
+// importPolygon applies a Polygon package to the problem and returns what couldn't be translated from it
+func (s *API) importPolygon(w http.ResponseWriter, r *http.Request) {
+	s.importPackage(w, r, logic.ParsePolygonPackage)
+}
+
+func (s *API) importCMS(w http.ResponseWriter, r *http.Request) {
+	s.importPackage(w, r, logic.ParseCMSTask)
+}
+
+// importPackage imports the problem package uploaded as "package", which is translated with parse
+func (s *API) importPackage(w http.ResponseWriter, r *http.Request, parse func(*zip.Reader) (*logic.ProblemPackage, error)) {
+	s.testArchiveLock.Lock()
+	defer s.testArchiveLock.Unlock()
+	r.ParseMultipartForm(100 * 1024 * 1024)
+
+	file, fh, err := r.FormFile("package")
+	if err != nil {
+		errorData(w, "Missing package", 400)
+		return
+	}
+	defer file.Close()
+
+	ar, err := zip.NewReader(file, fh.Size)
+	if err != nil {
+		errorData(w, logic.ErrBadArchive, 400)
+		return
+	}
+
+	pkg, err := parse(ar)
+	if err != nil {
+		errorData(w, err, 400)
+		return
+	}
+	if err := s.kn.ImportPackage(r.Context(), util.Problem(r), pkg); err != nil {
+		errorData(w, err, 400)
+		return
+	}
+	if err := checkers.InvalidateCache(util.Problem(r).ID); err != nil {
+		log.Println("Couldn't invalidate checker cache:", err)
+	}
+
+	warnings := pkg.Warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+	returnData(w, struct {
+		Tests     int      `json:"tests"`
+		Solutions int      `json:"solutions"`
+		Warnings  []string `json:"warnings"`
+	}{len(pkg.Tests), len(pkg.Solutions), warnings})
+}
+
+// exportCMS downloads the problem as a CMS task in the italy_yaml layout
+func (s *API) exportCMS(w http.ResponseWriter, r *http.Request) {
+	pb := util.Problem(r)
+	var compileChecker func(context.Context, *kilonova.Problem) ([]byte, error)
+	if s.grader != nil {
+		compileChecker = s.grader.CheckerBinary
+	}
+
+	// The archive is written to a file first, so the errors can still be reported
+	f, err := os.CreateTemp("", "kn-cms-*.zip")
+	if err != nil {
+		errorData(w, err, 500)
+		return
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if err := s.kn.ExportCMSTask(r.Context(), pb, f, compileChecker); err != nil {
+		errorData(w, err, 400)
+		return
+	}
+
+	name := pb.TestName
+	if name == "" {
+		name = fmt.Sprintf("problem%d", pb.ID)
+	}
+	w.Header().Add("Content-Type", "application/zip")
+	w.Header().Add("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, name))
+	http.ServeContent(w, r, name+".zip", time.Now(), f)
+}
+
 // deleteTest ensures that a test is deleted while also removing it from a subtask
 func (s *API) deleteTest(ctx context.Context, pbid, testvid int) error {
 	test, err := s.tserv.Test(ctx, pbid, testvid)
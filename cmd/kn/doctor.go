@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/eval/boxmanager"
+	"github.com/KiloProjects/kilonova/eval/tasks"
+	"github.com/KiloProjects/kilonova/internal/config"
+)
+
+// doctorIDBase is the first compilation ID used by the doctor, it is below the ones of the grader's custom runs
+const doctorIDBase = math.MinInt32 / 2
+
+const helloOutput = "Hello, World!"
+
+// helloPrograms print helloOutput, by language name
+var helloPrograms = map[string]string{
+	"c":       "#include <stdio.h>\nint main() { puts(\"Hello, World!\"); return 0; }\n",
+	"cpp":     "#include <stdio.h>\nint main() { puts(\"Hello, World!\"); return 0; }\n",
+	"golang":  "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"Hello, World!\") }\n",
+	"haskell": "main = putStrLn \"Hello, World!\"\n",
+	"java":    "public class Main { public static void main(String[] args) { System.out.println(\"Hello, World!\"); } }\n",
+	"python":  "print(\"Hello, World!\")\n",
+}
+
+// badPrograms must be detected by the sandbox, they are valid both as C and C++
+var badPrograms = []struct {
+	name    string
+	code    string
+	verdict kilonova.VerdictCode
+}{
+	{"time limit", "int main() { volatile unsigned long long x = 0; for (;;) x++; }\n", kilonova.VerdictTimeLimit},
+	{"memory limit", "#include <stdlib.h>\n#include <string.h>\nint main() { for (int i = 0; i < 512; i++) { char *p = (char *)malloc(1 << 20); if (p) memset(p, 1, 1 << 20); } return 0; }\n", kilonova.VerdictMemoryLimit},
+	{"runtime error", "int main() { volatile int *p = 0; *p = 1; return 0; }\n", kilonova.VerdictRuntimeError},
+}
+
+// doctorLimits are the limits of the sample programs, badLimits are the ones of the programs that must fail
+var (
+	doctorLimits = eval.Limits{TimeLimit: 5, MemoryLimit: 256 * 1024, StackLimit: 64 * 1024, OutputLimit: 1024}
+	badLimits    = eval.Limits{TimeLimit: 0.5, MemoryLimit: 64 * 1024, StackLimit: 16 * 1024, OutputLimit: 1024}
+)
+
+type doctorReport struct {
+	failed int
+}
+
+func (r *doctorReport) pass(name, msg string) {
+	fmt.Printf("[PASS] %s: %s\n", name, msg)
+}
+
+func (r *doctorReport) skip(name, msg string) {
+	fmt.Printf("[SKIP] %s: %s\n", name, msg)
+}
+
+func (r *doctorReport) fail(name string, err error) {
+	r.failed++
+	fmt.Printf("[FAIL] %s: %v\n", name, err)
+}
+
+func (r *doctorReport) check(name string, f func() (string, error)) bool {
+	msg, err := f()
+	if err != nil {
+		r.fail(name, err)
+		return false
+	}
+	r.pass(name, msg)
+	return true
+}
+
+// Doctor checks that submissions can be evaluated on this machine: isolate, cgroups, the sandbox and every enabled language.
+// It prints a report and fails if any check failed. It uses the same sandboxes as the grader, so the grader must not be running
+func Doctor() error {
+	fmt.Printf("Kilonova %s doctor\n", kilonova.Version)
+	r := &doctorReport{}
+
+	r.check("isolate binary", checkIsolate)
+	r.check("isolate files", func() (string, error) {
+		if err := eval.VerifyIsolate(); err != nil {
+			return "", err
+		}
+		if config.Eval.IsolateSHA256 == "" || config.Eval.IsolateConfigSHA256 == "" {
+			return "found, some checksums are not set in the config", nil
+		}
+		return "the checksums match", nil
+	})
+	r.check("cgroups", checkCgroups)
+	r.check("compile directory", func() (string, error) {
+		if err := os.MkdirAll(config.Eval.CompilePath, 0777); err != nil {
+			return "", err
+		}
+		return config.Eval.CompilePath, nil
+	})
+
+	canRun := r.check("sandbox", func() (string, error) {
+		if !boxmanager.CheckCanRun() {
+			return "", errors.New("could not create a sandbox, see the log above")
+		}
+		return "a sandbox was created and cleaned up", nil
+	})
+	if canRun {
+		doctorRun(r)
+	}
+
+	if r.failed > 0 {
+		return fmt.Errorf("%d checks failed", r.failed)
+	}
+	fmt.Println("All checks passed")
+	return nil
+}
+
+func checkIsolate() (string, error) {
+	stat, err := os.Stat(config.Eval.IsolatePath)
+	if err != nil {
+		return "", err
+	}
+	if !stat.Mode().IsRegular() || stat.Mode()&0111 == 0 {
+		return "", fmt.Errorf("%s is not an executable file", config.Eval.IsolatePath)
+	}
+	if os.Geteuid() == 0 {
+		return config.Eval.IsolatePath + ", running as root", nil
+	}
+	sys, ok := stat.Sys().(*syscall.Stat_t)
+	if !ok || sys.Uid != 0 || stat.Mode()&os.ModeSetuid == 0 {
+		return "", fmt.Errorf("%s must be setuid root when not running as root", config.Eval.IsolatePath)
+	}
+	return config.Eval.IsolatePath + ", setuid root", nil
+}
+
+func checkCgroups() (string, error) {
+	if data, err := os.ReadFile("/sys/fs/cgroup/cgroup.controllers"); err == nil {
+		for _, c := range strings.Fields(string(data)) {
+			if c == "memory" {
+				return "cgroup v2 with the memory controller", nil
+			}
+		}
+		return "", errors.New("cgroup v2 is mounted, but the memory controller is not enabled")
+	}
+	if stat, err := os.Stat("/sys/fs/cgroup/memory"); err == nil && stat.IsDir() {
+		return "cgroup v1 with the memory controller", nil
+	}
+	return "", errors.New("no cgroup memory controller was found in /sys/fs/cgroup")
+}
+
+// doctorRun runs the sample programs of every enabled language and the programs that must fail
+func doctorRun(r *doctorReport) {
+	bm, err := boxmanager.New(1, nil)
+	if err != nil {
+		r.fail("box manager", err)
+		return
+	}
+	defer bm.Close(context.Background())
+
+	names := make([]string, 0, len(config.Languages))
+	for name := range config.Languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	id := doctorIDBase
+	for _, name := range names {
+		check := "language " + name
+		lang := config.Languages[name]
+		if lang.Disabled {
+			r.skip(check, "disabled in the config")
+			continue
+		}
+		if err := eval.CheckLanguage(lang); err != nil {
+			r.fail(check, err)
+			continue
+		}
+		code, ok := helloPrograms[name]
+		if !ok {
+			r.skip(check, "no sample program for this language")
+			continue
+		}
+
+		id++
+		r.check(check, func() (string, error) {
+			resp, err := runSample(bm, id, name, code, doctorLimits)
+			if err != nil {
+				return "", err
+			}
+			if resp.Verdict != kilonova.VerdictNone {
+				return "", fmt.Errorf("hello world failed: %s %s", resp.Verdict, resp.Comments)
+			}
+			if strings.TrimSpace(resp.Stdout) != helloOutput {
+				return "", fmt.Errorf("hello world printed %q", resp.Stdout)
+			}
+			return fmt.Sprintf("hello world ran in %.3fs, using %dKB", resp.Time, resp.Memory), nil
+		})
+	}
+
+	badLang := ""
+	for _, name := range []string{"cpp", "c"} {
+		if lang, ok := config.Languages[name]; ok && !lang.Disabled && eval.CheckLanguage(lang) == nil {
+			badLang = name
+			break
+		}
+	}
+	for _, prog := range badPrograms {
+		check := prog.name + " detection"
+		if badLang == "" {
+			r.fail(check, errors.New("C or C++ is needed to check it"))
+			continue
+		}
+
+		id++
+		r.check(check, func() (string, error) {
+			resp, err := runSample(bm, id, badLang, prog.code, badLimits)
+			if err != nil {
+				return "", err
+			}
+			// The grader also looks at the measured usage, the same is done here
+			lim := badLimits.Adjust(eval.LanguageAdjustment(&kilonova.Problem{}, badLang))
+			verdict := resp.Verdict
+			if verdict == kilonova.VerdictNone && resp.Time > lim.TimeLimit {
+				verdict = kilonova.VerdictTimeLimit
+			}
+			if verdict == kilonova.VerdictNone && resp.Memory > lim.MemoryLimit {
+				verdict = kilonova.VerdictMemoryLimit
+			}
+			if verdict != prog.verdict {
+				return "", fmt.Errorf("expected %s, got %q (%s)", prog.verdict, verdict, resp.Comments)
+			}
+			return fmt.Sprintf("%s detected", verdict), nil
+		})
+	}
+}
+
+// runSample compiles the code and runs it on an empty input. The compiled program is removed afterwards
+func runSample(runner eval.Runner, id int, lang, code string, lim eval.Limits) (*eval.CustomRunResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	compile := &tasks.CompileTask{Req: &eval.CompileRequest{ID: id, Code: []byte(code), Lang: lang}}
+	if err := runner.RunTask(ctx, compile); err != nil {
+		return nil, err
+	}
+	defer eval.CleanCompilation(id)
+	if !compile.Resp.Success {
+		return nil, fmt.Errorf("compilation failed: %s%s", compile.Resp.Output, compile.Resp.Other)
+	}
+
+	task := &tasks.CustomRunTask{
+		Req: &eval.CustomRunRequest{
+			ID:          id,
+			Lang:        lang,
+			StackLimit:  lim.StackLimit,
+			MemoryLimit: lim.MemoryLimit,
+			TimeLimit:   lim.TimeLimit,
+			OutputLimit: lim.OutputLimit,
+			Adjustment:  eval.LanguageAdjustment(&kilonova.Problem{}, lang),
+		},
+		Resp: &eval.CustomRunResponse{},
+	}
+	if err := runner.RunTask(ctx, task); err != nil {
+		return nil, err
+	}
+	return task.Resp, nil
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/KiloProjects/kilonova/eval/grader"
 	"github.com/KiloProjects/kilonova/internal/config"
 	"github.com/KiloProjects/kilonova/internal/logic"
+	"github.com/KiloProjects/kilonova/internal/metrics"
 	"github.com/KiloProjects/kilonova/web"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/go-chi/chi"
@@ -138,7 +139,7 @@ func Kilonova() error {
 	// Initialize components
 	grader := grader.NewHandler(ctx, kn, db)
 
-	r.Mount("/api", api.New(kn, db).Handler())
+	r.Mount("/api", api.New(kn, db, grader).Handler())
 	r.Mount("/cdn", http.StripPrefix("/cdn/", &web.CDN{CDN: manager}))
 	r.Mount("/", web.NewWeb(kn, db).Handler())
 
@@ -187,5 +188,6 @@ func launchProfiler() error {
 	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
 	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
 	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/metrics", metrics.Handler())
 	return http.ListenAndServe(":6080", mux)
 }
@@ -32,12 +32,34 @@ func main() {
 		log.Fatal(err)
 	}
 
-	if err := eval.Initialize(); err != nil {
-		log.Fatalln("Could not initialize the box manager")
+	switch {
+	case flag.Arg(0) == "doctor":
+		// The doctor reports what is missing, instead of downloading it or disabling the languages
+	case flag.Arg(0) != "worker" && len(config.Eval.RemoteWorkers) > 0:
+		if err := eval.InitializeRemote(); err != nil {
+			log.Fatalln("Could not initialize the compile directory:", err)
+		}
+	default:
+		if err := eval.Initialize(); err != nil {
+			log.Fatalln("Could not initialize the box manager:", err)
+		}
 	}
 
-	if err := Kilonova(); err != nil {
-		log.Fatal(err)
+	switch flag.Arg(0) {
+	case "", "main":
+		if err := Kilonova(); err != nil {
+			log.Fatal(err)
+		}
+	case "worker":
+		if err := Worker(); err != nil {
+			log.Fatal(err)
+		}
+	case "doctor":
+		if err := Doctor(); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("Unknown command %q\n", flag.Arg(0))
 	}
 
 	os.Exit(0)
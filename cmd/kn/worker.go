@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/eval/boxmanager"
+	"github.com/KiloProjects/kilonova/eval/remote"
+	"github.com/KiloProjects/kilonova/internal/config"
+	"github.com/KiloProjects/kilonova/internal/metrics"
+)
+
+// Worker runs the remote eval worker, which evaluates the tasks sent by the web nodes
+func Worker() error {
+	fmt.Printf("Starting Kilonova %s worker\n", kilonova.Version)
+
+	if !boxmanager.CheckCanRun() {
+		return &kilonova.Error{Code: kilonova.EINTERNAL, Message: "Can't run isolate on this machine"}
+	}
+
+	bm, err := boxmanager.New(config.Eval.NumConcurrent, nil)
+	if err != nil {
+		return err
+	}
+
+	worker, err := remote.NewWorker(bm, config.Eval.NumConcurrent, config.Eval.Token, config.Common.Debug)
+	if err != nil {
+		return err
+	}
+
+	eval.RegisterRunnerMetrics(func() eval.StatsRunner { return bm })
+	if config.Eval.MetricsAddress != "" {
+		go func() {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", metrics.Handler())
+			if err := http.ListenAndServe(config.Eval.MetricsAddress, mux); err != nil {
+				log.Println("Could not serve metrics:", err)
+			}
+		}()
+	}
+
+	l, err := net.Listen("tcp", config.Eval.Address)
+	if err != nil {
+		return err
+	}
+	log.Printf("Listening on %s\n", l.Addr())
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := worker.Serve(ctx, l); err != nil {
+		return err
+	}
+
+	fmt.Println("Shutting Down")
+	return bm.Close(context.Background())
+}
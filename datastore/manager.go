@@ -29,6 +29,9 @@ func NewManager(p string) (kilonova.DataStore, error) {
 	if err := os.MkdirAll(path.Join(p, "tests"), 0777); err != nil {
 		return nil, err
 	}
+	if err := fixTestModes(path.Join(p, "tests")); err != nil {
+		return nil, err
+	}
 
 	if err := os.MkdirAll(path.Join(p, "cdn"), 0777); err != nil {
 		return nil, err
@@ -48,3 +51,27 @@ func writeFile(path string, r io.Reader, perms fs.FileMode) error {
 	}
 	return err
 }
+
+// replaceFile writes the file next to its destination and then moves it in place.
+// The old file is never modified, so the boxes it is linked in keep seeing the old contents
+func replaceFile(fpath string, r io.Reader, perms fs.FileMode) error {
+	f, err := os.CreateTemp(path.Dir(fpath), "."+path.Base(fpath)+"-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	_, err = f.ReadFrom(r)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err == nil {
+		err = os.Chmod(tmp, perms)
+	}
+	if err == nil {
+		err = os.Rename(tmp, fpath)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
@@ -11,9 +11,10 @@ import (
 	"github.com/davecgh/go-spew/spew"
 )
 
-// SubtestWriter should be used by the eval server
+// SubtestWriter should be used by the eval server.
+// The output is read-only for other users, so it can be linked in the checker's sandbox
 func (m *StorageManager) SubtestWriter(subtest int) (io.WriteCloser, error) {
-	return os.OpenFile(m.SubtestPath(subtest), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+	return os.OpenFile(m.SubtestPath(subtest), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 }
 
 // SubtestReader should be used by the grader
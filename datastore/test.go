@@ -1,7 +1,6 @@
 package datastore
 
 import (
-	"fmt"
 	"io"
 	"os"
 	"path"
@@ -9,7 +8,11 @@ import (
 )
 
 func (m *StorageManager) TestInput(testID int) (io.ReadCloser, error) {
-	return os.Open(path.Join(m.RootPath, "tests", strconv.Itoa(testID)+".in"))
+	return os.Open(m.TestInputPath(testID))
+}
+
+func (m *StorageManager) TestInputPath(testID int) string {
+	return path.Join(m.RootPath, "tests", strconv.Itoa(testID)+".in")
 }
 
 func (m *StorageManager) TestOutput(testID int) (io.ReadCloser, error) {
@@ -20,10 +23,39 @@ func (m *StorageManager) TestOutputPath(testID int) string {
 	return path.Join(m.RootPath, "tests", strconv.Itoa(testID)+".out")
 }
 
+// SaveTestInput replaces the input of the test.
+// Test files are read-only for other users, so they can be linked in the sandboxes
 func (m *StorageManager) SaveTestInput(testID int, input io.Reader) error {
-	return writeFile(path.Join(m.RootPath, "tests", fmt.Sprintf("%d.in", testID)), input, 0777)
+	return replaceFile(m.TestInputPath(testID), input, 0644)
 }
 
 func (m *StorageManager) SaveTestOutput(testID int, output io.Reader) error {
-	return writeFile(path.Join(m.RootPath, "tests", fmt.Sprintf("%d.out", testID)), output, 0777)
+	return replaceFile(m.TestOutputPath(testID), output, 0644)
+}
+
+// fixTestModes removes the write permission of the group and of the other users from the test files.
+// Tests saved by older versions are world-writable, so they could never be linked in the sandboxes
+func fixTestModes(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		if perm := info.Mode().Perm(); perm&0022 != 0 {
+			if err := os.Chmod(path.Join(dir, entry.Name()), perm&^0022); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
@@ -15,14 +15,14 @@ type AttachmentService struct {
 	db *sqlx.DB
 }
 
-const createAttachmentQuery = "INSERT INTO attachments (problem_id, visible, name, data) VALUES (?, ?, ?, ?) RETURNING id;"
+const createAttachmentQuery = "INSERT INTO attachments (problem_id, visible, name, data, grader_lang) VALUES (?, ?, ?, ?, ?) RETURNING id;"
 
 func (a *AttachmentService) CreateAttachment(ctx context.Context, att *kilonova.Attachment) error {
 	if att.ProblemID == 0 || att.Data == nil {
 		return kilonova.ErrMissingRequired
 	}
 	var id int
-	err := a.db.GetContext(ctx, &id, a.db.Rebind(createAttachmentQuery), att.ProblemID, att.Visible, att.Name, att.Data)
+	err := a.db.GetContext(ctx, &id, a.db.Rebind(createAttachmentQuery), att.ProblemID, att.Visible, att.Name, att.Data, att.GraderLang)
 	if err == nil {
 		att.ID = id
 	}
@@ -40,7 +40,7 @@ func (a *AttachmentService) Attachments(ctx context.Context, getData bool, filte
 	where, args := a.filterQueryMaker(&filter)
 	toSelect := "*"
 	if !getData {
-		toSelect = "id, created_at, problem_id, visible, name" // Make sure to keep this in sync
+		toSelect = "id, created_at, problem_id, visible, name, grader_lang" // Make sure to keep this in sync
 	}
 	query := a.db.Rebind("SELECT " + toSelect + " FROM attachments WHERE " + strings.Join(where, " AND ") + " ORDER BY name ASC " + FormatLimitOffset(filter.Limit, filter.Offset))
 	err := a.db.SelectContext(ctx, &attachments, query, args...)
@@ -84,6 +84,9 @@ func (a *AttachmentService) filterQueryMaker(filter *kilonova.AttachmentFilter)
 	if v := filter.Visible; v != nil {
 		where, args = append(where, "visible = ?"), append(args, v)
 	}
+	if v := filter.GraderLang; v != nil {
+		where, args = append(where, "grader_lang = ?"), append(args, v)
+	}
 	return where, args
 }
 
@@ -98,6 +101,9 @@ func (a *AttachmentService) updateQueryMaker(upd *kilonova.AttachmentUpdate) ([]
 	if v := upd.Visible; v != nil {
 		toUpd, args = append(toUpd, "visible = ?"), append(args, v)
 	}
+	if v := upd.GraderLang; v != nil {
+		toUpd, args = append(toUpd, "grader_lang = ?"), append(args, v)
+	}
 	return toUpd, args
 }
 
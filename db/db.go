@@ -20,6 +20,9 @@ var _ kilonova.TypeServicer = &DB{}
 
 type DB struct {
 	conn *sqlx.DB
+
+	// queue is shared by all submission services, so graders get notified of the submissions added through any of them
+	queue *queueNotifier
 }
 
 func (d *DB) UserService() kilonova.UserService {
@@ -35,7 +38,7 @@ func (d *DB) TestService() kilonova.TestService {
 }
 
 func (d *DB) SubmissionService() kilonova.SubmissionService {
-	return NewSubmissionService(d.conn)
+	return &SubmissionService{d.conn, d.queue}
 }
 
 func (d *DB) SubTestService() kilonova.SubTestService {
@@ -62,6 +65,10 @@ func (d *DB) AttachmentService() kilonova.AttachmentService {
 	return NewAttachmentService(d.conn)
 }
 
+func (d *DB) ProblemSolutionService() kilonova.ProblemSolutionService {
+	return NewProblemSolutionService(d.conn)
+}
+
 func (d *DB) Close() error {
 	return d.conn.Close()
 }
@@ -93,7 +100,7 @@ func NewSQLite(ctx context.Context, filename string) (*SQLiteDB, error) {
 	if err != nil {
 		return nil, err
 	}
-	db := &SQLiteDB{DB{conn}}
+	db := &SQLiteDB{DB{conn, newQueueNotifier()}}
 	subbed, err := fs.Sub(sqliteSchema, "sqlite_schema")
 	if err != nil {
 		return nil, err
@@ -109,7 +116,7 @@ func NewPSQL(dsn string) (*DB, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &DB{conn}, nil
+	return &DB{conn, newQueueNotifier()}, nil
 }
 
 func AppropriateDB(ctx context.Context, conf config.DBConf) (kilonova.TypeServicer, error) {
@@ -35,9 +35,11 @@ func (s *ProblemService) Problems(ctx context.Context, filter kilonova.ProblemFi
 }
 
 const problemCreateQuery = `INSERT INTO problems (
-	name, description, author_id, console_input, test_name, memory_limit, stack_limit, source_size, time_limit, visible, source_credits, author_credits, short_description, default_points, pb_type, helper_code, helper_code_lang
+	name, description, author_id, console_input, test_name, memory_limit, stack_limit, source_size, time_limit, visible, source_credits, author_credits, short_description, default_points, pb_type, helper_code, helper_code_lang, checker_protocol, comparator, comparator_epsilon,
+	output_size_limit, short_circuit, scoring_policy, language_limits, source_file_name, generator_code, generator_lang, validator_code, validator_lang, test_script
 ) VALUES (
-	?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+	?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?,
+	?, ?, ?, ?, ?, ?, ?, ?, ?, ?
 ) RETURNING id;`
 
 func (s *ProblemService) CreateProblem(ctx context.Context, p *kilonova.Problem) error {
@@ -62,8 +64,24 @@ func (s *ProblemService) CreateProblem(ctx context.Context, p *kilonova.Problem)
 	if p.Type == kilonova.ProblemTypeNone {
 		p.Type = kilonova.ProblemTypeClassic
 	}
+	if p.CheckerProtocol == "" {
+		p.CheckerProtocol = kilonova.CheckerProtocolKilonova
+	}
+	if p.ScoringPolicy == "" {
+		p.ScoringPolicy = kilonova.ScoringSubtaskMin
+	}
+	if p.Comparator == "" {
+		p.Comparator = kilonova.ComparatorDiff
+	}
+	if p.GeneratorLang == "" {
+		p.GeneratorLang = "cpp"
+	}
+	if p.ValidatorLang == "" {
+		p.ValidatorLang = "cpp"
+	}
 	var id int
-	err := s.db.GetContext(ctx, &id, s.db.Rebind(problemCreateQuery), p.Name, p.Description, p.AuthorID, p.ConsoleInput, p.TestName, p.MemoryLimit, p.StackLimit, p.SourceSize, p.TimeLimit, p.Visible, p.SourceCredits, p.AuthorCredits, p.ShortDesc, p.DefaultPoints, p.Type, p.HelperCode, p.HelperCodeLang)
+	err := s.db.GetContext(ctx, &id, s.db.Rebind(problemCreateQuery), p.Name, p.Description, p.AuthorID, p.ConsoleInput, p.TestName, p.MemoryLimit, p.StackLimit, p.SourceSize, p.TimeLimit, p.Visible, p.SourceCredits, p.AuthorCredits, p.ShortDesc, p.DefaultPoints, p.Type, p.HelperCode, p.HelperCodeLang, p.CheckerProtocol, p.Comparator, p.ComparatorEpsilon,
+		p.OutputSizeLimit, p.ShortCircuit, p.ScoringPolicy, p.LanguageLimits, p.SourceFileName, p.GeneratorCode, p.GeneratorLang, p.ValidatorCode, p.ValidatorLang, p.TestScript)
 	if err == nil {
 		p.ID = id
 	}
@@ -165,6 +183,12 @@ func (s *ProblemService) updateQueryMaker(upd *kilonova.ProblemUpdate) ([]string
 	if v := upd.StackLimit; v != nil {
 		toUpd, args = append(toUpd, "stack_limit = ?"), append(args, v)
 	}
+	if v := upd.OutputSizeLimit; v != nil {
+		toUpd, args = append(toUpd, "output_size_limit = ?"), append(args, v)
+	}
+	if v := upd.LanguageLimits; v != nil {
+		toUpd, args = append(toUpd, "language_limits = ?"), append(args, v)
+	}
 
 	if v := upd.DefaultPoints; v != nil {
 		toUpd, args = append(toUpd, "default_points = ?"), append(args, v)
@@ -189,13 +213,47 @@ func (s *ProblemService) updateQueryMaker(upd *kilonova.ProblemUpdate) ([]string
 	if v := upd.SubtaskString; v != nil {
 		toUpd, args = append(toUpd, "subtasks = ?"), append(args, v)
 	}
+	if v := upd.CheckerProtocol; v != "" {
+		toUpd, args = append(toUpd, "checker_protocol = ?"), append(args, v)
+	}
+	if v := upd.Comparator; v != "" {
+		toUpd, args = append(toUpd, "comparator = ?"), append(args, v)
+	}
+	if v := upd.ComparatorEpsilon; v != nil {
+		toUpd, args = append(toUpd, "comparator_epsilon = ?"), append(args, v)
+	}
 
 	if v := upd.ConsoleInput; v != nil {
 		toUpd, args = append(toUpd, "console_input = ?"), append(args, v)
 	}
+	if v := upd.SourceFileName; v != nil {
+		toUpd, args = append(toUpd, "source_file_name = ?"), append(args, v)
+	}
 	if v := upd.Visible; v != nil {
 		toUpd, args = append(toUpd, "visible = ?"), append(args, v)
 	}
+	if v := upd.ShortCircuit; v != nil {
+		toUpd, args = append(toUpd, "short_circuit = ?"), append(args, v)
+	}
+	if v := upd.ScoringPolicy; v != "" {
+		toUpd, args = append(toUpd, "scoring_policy = ?"), append(args, v)
+	}
+
+	if v := upd.GeneratorCode; v != nil {
+		toUpd, args = append(toUpd, "generator_code = ?"), append(args, v)
+	}
+	if v := upd.GeneratorLang; v != nil {
+		toUpd, args = append(toUpd, "generator_lang = ?"), append(args, v)
+	}
+	if v := upd.ValidatorCode; v != nil {
+		toUpd, args = append(toUpd, "validator_code = ?"), append(args, v)
+	}
+	if v := upd.ValidatorLang; v != nil {
+		toUpd, args = append(toUpd, "validator_lang = ?"), append(args, v)
+	}
+	if v := upd.TestScript; v != nil {
+		toUpd, args = append(toUpd, "test_script = ?"), append(args, v)
+	}
 
 	return toUpd, args
 }
@@ -0,0 +1,2 @@
+ALTER TABLE submission_tests ADD COLUMN interactor_time double precision NOT NULL DEFAULT 0;
+ALTER TABLE submission_tests ADD COLUMN interactor_memory integer NOT NULL DEFAULT 0;
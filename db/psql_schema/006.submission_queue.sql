@@ -0,0 +1,2 @@
+ALTER TABLE submissions ADD COLUMN worker_id text;
+ALTER TABLE submissions ADD COLUMN lease_expires_at timestamptz;
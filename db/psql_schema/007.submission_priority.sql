@@ -0,0 +1 @@
+ALTER TABLE submissions ADD COLUMN priority integer NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE problems ADD COLUMN comparator text NOT NULL DEFAULT 'diff';
+ALTER TABLE problems ADD COLUMN comparator_epsilon double precision NOT NULL DEFAULT 0.000001;
@@ -0,0 +1 @@
+ALTER TABLE problems ADD COLUMN checker_protocol text NOT NULL DEFAULT 'kilonova';
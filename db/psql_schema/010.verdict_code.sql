@@ -0,0 +1 @@
+ALTER TABLE submission_tests ADD COLUMN verdict_code text NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+ALTER TABLE problems ADD COLUMN output_size_limit integer NOT NULL DEFAULT 0;
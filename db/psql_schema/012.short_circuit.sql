@@ -0,0 +1 @@
+ALTER TABLE problems ADD COLUMN short_circuit boolean NOT NULL DEFAULT false;
@@ -0,0 +1,8 @@
+ALTER TABLE problems ADD COLUMN scoring_policy text NOT NULL DEFAULT 'subtask_min';
+
+CREATE TABLE IF NOT EXISTS submission_subtasks (
+	id 				bigserial 	PRIMARY KEY,
+	submission_id 	bigint 		NOT NULL REFERENCES submissions(id) ON DELETE CASCADE,
+	subtask_id 		bigint 		NOT NULL REFERENCES subtasks(id) ON DELETE CASCADE,
+	score 			integer 	NOT NULL DEFAULT 0
+);
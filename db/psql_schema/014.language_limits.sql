@@ -0,0 +1 @@
+ALTER TABLE problems ADD COLUMN language_limits text NOT NULL DEFAULT '{}';
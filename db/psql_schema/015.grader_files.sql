@@ -0,0 +1,2 @@
+ALTER TABLE attachments ADD COLUMN grader_lang text NOT NULL DEFAULT '';
+ALTER TABLE problems ADD COLUMN source_file_name text NOT NULL DEFAULT '';
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS problem_solutions (
+	id 				bigserial 	PRIMARY KEY,
+	created_at 		timestamptz NOT NULL DEFAULT NOW(),
+	problem_id 		bigint 		NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+
+	name 			text 		NOT NULL,
+	code 			text 		NOT NULL,
+	language 		text 		NOT NULL,
+	expected 		text 		NOT NULL,
+
+	check_status 	text 		NOT NULL DEFAULT '',
+	check_message 	text 		NOT NULL DEFAULT '',
+	checked_at 		timestamptz
+);
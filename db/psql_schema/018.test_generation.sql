@@ -0,0 +1,5 @@
+ALTER TABLE problems ADD COLUMN generator_code text NOT NULL DEFAULT '';
+ALTER TABLE problems ADD COLUMN generator_lang text NOT NULL DEFAULT 'cpp';
+ALTER TABLE problems ADD COLUMN validator_code text NOT NULL DEFAULT '';
+ALTER TABLE problems ADD COLUMN validator_lang text NOT NULL DEFAULT 'cpp';
+ALTER TABLE problems ADD COLUMN test_script text NOT NULL DEFAULT '';
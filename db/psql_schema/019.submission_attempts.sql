@@ -0,0 +1 @@
+ALTER TABLE submissions ADD COLUMN attempts integer NOT NULL DEFAULT 0;
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/jmoiron/sqlx"
+)
+
+var _ kilonova.ProblemSolutionService = &ProblemSolutionService{}
+
+type ProblemSolutionService struct {
+	db *sqlx.DB
+}
+
+const createProblemSolutionQuery = "INSERT INTO problem_solutions (problem_id, name, code, language, expected) VALUES (?, ?, ?, ?, ?) RETURNING id;"
+
+func (s *ProblemSolutionService) CreateProblemSolution(ctx context.Context, sol *kilonova.ProblemSolution) error {
+	if sol.ProblemID == 0 || sol.Code == "" || sol.Language == "" || sol.Expected == "" {
+		return kilonova.ErrMissingRequired
+	}
+	var id int
+	err := s.db.GetContext(ctx, &id, s.db.Rebind(createProblemSolutionQuery), sol.ProblemID, sol.Name, sol.Code, sol.Language, sol.Expected)
+	if err == nil {
+		sol.ID = id
+	}
+	return err
+}
+
+func (s *ProblemSolutionService) ProblemSolution(ctx context.Context, id int) (*kilonova.ProblemSolution, error) {
+	var sol kilonova.ProblemSolution
+	err := s.db.GetContext(ctx, &sol, s.db.Rebind("SELECT * FROM problem_solutions WHERE id = ? LIMIT 1"), id)
+	return &sol, err
+}
+
+func (s *ProblemSolutionService) ProblemSolutions(ctx context.Context, problemID int) ([]*kilonova.ProblemSolution, error) {
+	var sols []*kilonova.ProblemSolution
+	err := s.db.SelectContext(ctx, &sols, s.db.Rebind("SELECT * FROM problem_solutions WHERE problem_id = ? ORDER BY id ASC"), problemID)
+	return sols, err
+}
+
+func (s *ProblemSolutionService) UpdateProblemSolution(ctx context.Context, id int, upd kilonova.ProblemSolutionUpdate) error {
+	return s.update(ctx, "id = ?", id, upd)
+}
+
+func (s *ProblemSolutionService) UpdateProblemSolutions(ctx context.Context, problemID int, upd kilonova.ProblemSolutionUpdate) error {
+	return s.update(ctx, "problem_id = ?", problemID, upd)
+}
+
+func (s *ProblemSolutionService) update(ctx context.Context, where string, arg interface{}, upd kilonova.ProblemSolutionUpdate) error {
+	toUpd, args := s.updateQueryMaker(&upd)
+	if len(toUpd) == 0 {
+		return kilonova.ErrNoUpdates
+	}
+	args = append(args, arg)
+	query := s.db.Rebind(fmt.Sprintf("UPDATE problem_solutions SET %s WHERE %s", strings.Join(toUpd, ", "), where))
+	_, err := s.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (s *ProblemSolutionService) DeleteProblemSolution(ctx context.Context, id int) error {
+	_, err := s.db.ExecContext(ctx, s.db.Rebind("DELETE FROM problem_solutions WHERE id = ?"), id)
+	return err
+}
+
+func (s *ProblemSolutionService) updateQueryMaker(upd *kilonova.ProblemSolutionUpdate) ([]string, []interface{}) {
+	toUpd, args := []string{}, []interface{}{}
+	if v := upd.Name; v != nil {
+		toUpd, args = append(toUpd, "name = ?"), append(args, v)
+	}
+	if v := upd.Code; v != nil {
+		toUpd, args = append(toUpd, "code = ?"), append(args, v)
+	}
+	if v := upd.Language; v != nil {
+		toUpd, args = append(toUpd, "language = ?"), append(args, v)
+	}
+	if v := upd.Expected; v != nil {
+		toUpd, args = append(toUpd, "expected = ?"), append(args, v)
+	}
+	if v := upd.CheckStatus; v != nil {
+		toUpd, args = append(toUpd, "check_status = ?"), append(args, v)
+	}
+	if v := upd.CheckMessage; v != nil {
+		toUpd, args = append(toUpd, "check_message = ?"), append(args, v)
+	}
+	if v := upd.CheckedAt; v != nil {
+		toUpd, args = append(toUpd, "checked_at = ?"), append(args, v)
+	}
+	return toUpd, args
+}
+
+func NewProblemSolutionService(db *sqlx.DB) kilonova.ProblemSolutionService {
+	return &ProblemSolutionService{db}
+}
@@ -8,9 +8,12 @@ CREATE TABLE IF NOT EXISTS problems (
 	time_limit 	FLOAT 		NOT NULL DEFAULT 0.1,
 	memory_limit INTEGER 	NOT NULL DEFAULT 65536,
 	stack_limit INTEGER 	NOT NULL DEFAULT 16384,
+	output_size_limit INTEGER NOT NULL DEFAULT 0,
+	language_limits TEXT 	NOT NULL DEFAULT '{}',
 
 	source_size INTEGER 	NOT NULL DEFAULT 10000,
 	console_input INTEGER 	NOT NULL DEFAULT FALSE,
+	source_file_name TEXT 	NOT NULL DEFAULT '',
 	visible 	INTEGER 	NOT NULL DEFAULT FALSE,
 
 	source_credits TEXT 	NOT NULL DEFAULT '',
@@ -18,7 +21,19 @@ CREATE TABLE IF NOT EXISTS problems (
 	short_description TEXT 	NOT NULL DEFAULT '',
 	default_points INTEGER 	NOT NULL DEFAULT 0,
 
-	pb_type 	TEXT CHECK(pb_type IN ('classic', 'interactive', 'custom_checker')) NOT NULL DEFAULT 'classic',
+	pb_type 	TEXT CHECK(pb_type IN ('classic', 'interactive', 'custom_checker', 'output_only')) NOT NULL DEFAULT 'classic',
 	helper_code TEXT 		NOT NULL DEFAULT '',
-	helper_code_lang TEXT 	NOT NULL DEFAULT 'cpp'
+	helper_code_lang TEXT 	NOT NULL DEFAULT 'cpp',
+	checker_protocol TEXT 	NOT NULL DEFAULT 'kilonova',
+	short_circuit INTEGER 	NOT NULL DEFAULT FALSE,
+	scoring_policy TEXT 	NOT NULL DEFAULT 'subtask_min',
+
+	comparator 	TEXT 		NOT NULL DEFAULT 'diff',
+	comparator_epsilon FLOAT NOT NULL DEFAULT 0.000001,
+
+	generator_code TEXT 	NOT NULL DEFAULT '',
+	generator_lang TEXT 	NOT NULL DEFAULT 'cpp',
+	validator_code TEXT 	NOT NULL DEFAULT '',
+	validator_lang TEXT 	NOT NULL DEFAULT 'cpp',
+	test_script TEXT 		NOT NULL DEFAULT ''
 );
@@ -12,5 +12,10 @@ CREATE TABLE IF NOT EXISTS submissions (
 
 	score 		INTEGER 	NOT NULL DEFAULT 0,
 	visible 	INTEGER 	NOT NULL DEFAULT FALSE,
-	quality 	INTEGER 	NOT NULL DEFAULT FALSE
+	quality 	INTEGER 	NOT NULL DEFAULT FALSE,
+	priority 	INTEGER 	NOT NULL DEFAULT 0,
+
+	worker_id 			TEXT,
+	lease_expires_at 	TIMESTAMP,
+	attempts 			INTEGER 	NOT NULL DEFAULT 0
 );
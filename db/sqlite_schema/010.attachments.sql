@@ -5,5 +5,6 @@ CREATE TABLE IF NOT EXISTS attachments (
 	visible 	INTEGER 	NOT NULL DEFAULT FALSE,
 
 	name 		TEXT 		NOT NULL,
-	data 		BLOB 		NOT NULL
+	data 		BLOB 		NOT NULL,
+	grader_lang TEXT 		NOT NULL DEFAULT ''
 );
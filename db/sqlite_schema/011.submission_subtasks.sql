@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS submission_subtasks (
+	id 				INTEGER 	PRIMARY KEY,
+	submission_id 	INTEGER 	NOT NULL REFERENCES submissions(id) ON DELETE CASCADE,
+	subtask_id 		INTEGER 	NOT NULL REFERENCES subtasks(id) ON DELETE CASCADE,
+	score 			INTEGER 	NOT NULL DEFAULT 0
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS problem_solutions (
+	id 				INTEGER 	PRIMARY KEY,
+	created_at 		TIMESTAMP	NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	problem_id 		INTEGER 	NOT NULL REFERENCES problems(id) ON DELETE CASCADE,
+
+	name 			TEXT 		NOT NULL,
+	code 			TEXT 		NOT NULL,
+	language 		TEXT 		NOT NULL,
+	expected 		TEXT 		NOT NULL,
+
+	check_status 	TEXT 		NOT NULL DEFAULT '',
+	check_message 	TEXT 		NOT NULL DEFAULT '',
+	checked_at 		TIMESTAMP
+);
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/KiloProjects/kilonova"
 	"github.com/jmoiron/sqlx"
@@ -15,7 +17,8 @@ import (
 var _ kilonova.SubmissionService = &SubmissionService{}
 
 type SubmissionService struct {
-	db *sqlx.DB
+	db    *sqlx.DB
+	queue *queueNotifier
 }
 
 func (s *SubmissionService) SubmissionByID(ctx context.Context, id int) (*kilonova.Submission, error) {
@@ -45,14 +48,14 @@ func (s *SubmissionService) CountSubmissions(ctx context.Context, filter kilonov
 	return cnt, err
 }
 
-const createSubQuery = "INSERT INTO submissions (user_id, problem_id, language, code) VALUES (?, ?, ?, ?) RETURNING id;"
+const createSubQuery = "INSERT INTO submissions (user_id, problem_id, language, code, priority) VALUES (?, ?, ?, ?, ?) RETURNING id;"
 
 func (s *SubmissionService) CreateSubmission(ctx context.Context, sub *kilonova.Submission) error {
 	if sub.UserID == 0 || sub.ProblemID == 0 || sub.Language == "" || sub.Code == "" {
 		return kilonova.ErrMissingRequired
 	}
 	var id int
-	err := s.db.GetContext(ctx, &id, s.db.Rebind(createSubQuery), sub.UserID, sub.ProblemID, sub.Language, sub.Code)
+	err := s.db.GetContext(ctx, &id, s.db.Rebind(createSubQuery), sub.UserID, sub.ProblemID, sub.Language, sub.Code, sub.Priority)
 	if err == nil {
 		sub.ID = id
 	}
@@ -67,6 +70,9 @@ func (s *SubmissionService) UpdateSubmission(ctx context.Context, id int, upd ki
 	args = append(args, id)
 	query := s.db.Rebind(fmt.Sprintf(`UPDATE submissions SET %s WHERE id = ?;`, strings.Join(toUpd, ", ")))
 	_, err := s.db.ExecContext(ctx, query, args...)
+	if err == nil && upd.Status == kilonova.StatusWaiting {
+		s.queue.notify()
+	}
 	return err
 }
 
@@ -79,6 +85,102 @@ func (s *SubmissionService) DeleteSubmission(ctx context.Context, id int) error
 	return err
 }
 
+func (s *SubmissionService) ClaimSubmissions(ctx context.Context, req kilonova.ClaimRequest) ([]*kilonova.Submission, error) {
+	if req.WorkerID == "" || req.Limit <= 0 || req.Lease <= 0 {
+		return nil, kilonova.ErrMissingRequired
+	}
+
+	// Postgres can skip the rows claimed by concurrent transactions. Otherwise, the status check in the outer query
+	// makes sure a submission is not claimed twice
+	lock := ""
+	if s.db.DriverName() == "pgx" {
+		lock = " FOR UPDATE SKIP LOCKED"
+	}
+
+	where, args := "status = 'waiting'", []interface{}{req.WorkerID, leaseExpiry(req.Lease)}
+	if req.Priority != nil {
+		where, args = where+" AND priority = ?", append(args, *req.Priority)
+	}
+	args = append(args, req.Limit)
+
+	var ids []int
+	query := s.db.Rebind(`UPDATE submissions SET status = 'working', worker_id = ?, lease_expires_at = ?, attempts = attempts + 1
+WHERE status = 'waiting' AND id IN (SELECT id FROM submissions WHERE ` + where + ` ORDER BY priority DESC, id ASC LIMIT ?` + lock + `)
+RETURNING id;`)
+	if err := s.db.SelectContext(ctx, &ids, query, args...); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	// SQLite doesn't keep the column types in RETURNING, so the timestamps can't be scanned from there
+	query, args, err := sqlx.In("SELECT * FROM submissions WHERE id IN (?) AND worker_id = ? ORDER BY priority DESC, id ASC", ids, req.WorkerID)
+	if err != nil {
+		return nil, err
+	}
+	var subs []*kilonova.Submission
+	err = s.db.SelectContext(ctx, &subs, s.db.Rebind(query), args...)
+	return subs, err
+}
+
+func (s *SubmissionService) RenewSubmissionLease(ctx context.Context, id int, workerID string, lease time.Duration) error {
+	res, err := s.db.ExecContext(ctx, s.db.Rebind("UPDATE submissions SET lease_expires_at = ? WHERE id = ? AND worker_id = ? AND status = 'working'"), leaseExpiry(lease), id, workerID)
+	if err != nil {
+		return err
+	}
+	return checkLeaseHeld(res)
+}
+
+func (s *SubmissionService) ReleaseSubmission(ctx context.Context, id int, workerID string) error {
+	res, err := s.db.ExecContext(ctx, s.db.Rebind("UPDATE submissions SET status = 'waiting', worker_id = NULL, lease_expires_at = NULL, attempts = attempts - 1 WHERE id = ? AND worker_id = ? AND status = 'working'"), id, workerID)
+	if err != nil {
+		return err
+	}
+	if err := checkLeaseHeld(res); err != nil {
+		return err
+	}
+	s.queue.notify()
+	return nil
+}
+
+func (s *SubmissionService) RequeueExpiredSubmissions(ctx context.Context) (int, error) {
+	res, err := s.db.ExecContext(ctx, s.db.Rebind("UPDATE submissions SET status = 'waiting', worker_id = NULL, lease_expires_at = NULL WHERE status = 'working' AND (lease_expires_at IS NULL OR lease_expires_at < ?)"), time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if cnt > 0 {
+		s.queue.notify()
+	}
+	return int(cnt), nil
+}
+
+func (s *SubmissionService) QueueNotify() <-chan struct{} {
+	return s.queue.wait()
+}
+
+func (s *SubmissionService) SetSubTaskScores(ctx context.Context, subID int, scores map[int]int) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, s.db.Rebind("DELETE FROM submission_subtasks WHERE submission_id = ?"), subID); err != nil {
+		return err
+	}
+	for stkID, score := range scores {
+		if _, err := tx.ExecContext(ctx, s.db.Rebind("INSERT INTO submission_subtasks (submission_id, subtask_id, score) VALUES (?, ?, ?)"), subID, stkID, score); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
 func (s *SubmissionService) MaxScore(ctx context.Context, userid, problemid int) int {
 	var score int
 
@@ -92,9 +194,50 @@ LIMIT 1;`), userid, problemid)
 		}
 		return -1
 	}
+
+	if best, ok := s.bestSubTaskScore(ctx, userid, problemid); ok && best > score {
+		score = best
+	}
 	return score
 }
 
+// bestSubTaskScore returns the sum of the best points obtained by the user on every subtask of the problem, plus the default points.
+// It returns false if the problem doesn't use the best_subtask scoring policy or the submissions have no subtask scores
+func (s *SubmissionService) bestSubTaskScore(ctx context.Context, userid, problemid int) (int, bool) {
+	var pb struct {
+		Policy        kilonova.ScoringPolicy `db:"scoring_policy"`
+		DefaultPoints int                    `db:"default_points"`
+	}
+	if err := s.db.GetContext(ctx, &pb, s.db.Rebind("SELECT scoring_policy, default_points FROM problems WHERE id = ?"), problemid); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Println("bestSubTaskScore:", err)
+		}
+		return 0, false
+	}
+	if pb.Policy != kilonova.ScoringBestSubtask {
+		return 0, false
+	}
+
+	var best []int
+	err := s.db.SelectContext(ctx, &best, s.db.Rebind(`SELECT MAX(stks.score) FROM submission_subtasks stks
+INNER JOIN submissions subs ON stks.submission_id = subs.id
+WHERE subs.user_id = ? AND subs.problem_id = ?
+GROUP BY stks.subtask_id;`), userid, problemid)
+	if err != nil {
+		log.Println("bestSubTaskScore:", err)
+		return 0, false
+	}
+	if len(best) == 0 {
+		return 0, false
+	}
+
+	score := pb.DefaultPoints
+	for _, v := range best {
+		score += v
+	}
+	return score, true
+}
+
 func (s *SubmissionService) MaxScores(ctx context.Context, userid int, pbids []int) map[int]int {
 	if pbids == nil || len(pbids) == 0 {
 		return nil
@@ -127,16 +270,68 @@ func (s *SubmissionService) MaxScores(ctx context.Context, userid int, pbids []i
 	for _, col := range cols {
 		rez[col.ProblemID] = col.MaxScore
 	}
+
+	// Only the problems scored by the best subtasks need another look
+	var bestPbs []int
+	err = s.db.SelectContext(ctx, &bestPbs, s.db.Rebind("SELECT id FROM problems WHERE id IN "+inClause+" AND scoring_policy = ?"), append(args[:len(args)-1:len(args)-1], kilonova.ScoringBestSubtask)...)
+	if err != nil {
+		log.Println("MaxScores:", err)
+		return rez
+	}
+	for _, pbid := range bestPbs {
+		if best, ok := s.bestSubTaskScore(ctx, userid, pbid); ok && best > rez[pbid] {
+			rez[pbid] = best
+		}
+	}
 	return rez
 }
 
 func (s *SubmissionService) SolvedProblems(ctx context.Context, userid int) ([]int, error) {
-	var pbs []int
-	err := s.db.SelectContext(ctx, &pbs, s.db.Rebind(`SELECT problem_id FROM submissions
-WHERE score = 100 AND user_id = ?
+	var ids []int
+	err := s.db.SelectContext(ctx, &ids, s.db.Rebind(`SELECT problem_id FROM submissions
+WHERE user_id = ?
 GROUP BY problem_id
 ORDER BY problem_id;`), userid)
-	return pbs, err
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	scores := s.MaxScores(ctx, userid, ids)
+	var pbs []int
+	for _, id := range ids {
+		full, err := s.fullScore(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if score, ok := scores[id]; ok && score >= full {
+			pbs = append(pbs, id)
+		}
+	}
+	return pbs, nil
+}
+
+// fullScore returns the score needed to solve the problem, from its current tests and subtasks
+func (s *SubmissionService) fullScore(ctx context.Context, problemid int) (int, error) {
+	var pb struct {
+		Policy        kilonova.ScoringPolicy `db:"scoring_policy"`
+		DefaultPoints int                    `db:"default_points"`
+	}
+	if err := s.db.GetContext(ctx, &pb, s.db.Rebind("SELECT scoring_policy, default_points FROM problems WHERE id = ?"), problemid); err != nil {
+		return 0, err
+	}
+	var tests []*kilonova.Test
+	if err := s.db.SelectContext(ctx, &tests, s.db.Rebind("SELECT * FROM tests WHERE problem_id = ? AND orphaned = false"), problemid); err != nil {
+		return 0, err
+	}
+	var stks []*subtask
+	if err := s.db.SelectContext(ctx, &stks, s.db.Rebind("SELECT * FROM subtasks WHERE problem_id = ?"), problemid); err != nil {
+		return 0, err
+	}
+	subTasks := make([]*kilonova.SubTask, 0, len(stks))
+	for _, stk := range stks {
+		subTasks = append(subTasks, InternalToSubTask(stk))
+	}
+	return kilonova.FullScore(pb.Policy, pb.DefaultPoints, tests, subTasks), nil
 }
 
 func (s *SubmissionService) bulkUpdateSubs(ctx context.Context, filter *kilonova.SubmissionFilter, upd *kilonova.SubmissionUpdate) error {
@@ -148,6 +343,9 @@ func (s *SubmissionService) bulkUpdateSubs(ctx context.Context, filter *kilonova
 	args = append(args, whereArgs...)
 	query := s.db.Rebind(fmt.Sprintf(`UPDATE submissions SET %s WHERE %s;`, strings.Join(toUpd, ", "), strings.Join(where, " AND ")))
 	_, err := s.db.ExecContext(ctx, query, args...)
+	if err == nil && upd.Status == kilonova.StatusWaiting {
+		s.queue.notify()
+	}
 	return err
 }
 
@@ -183,6 +381,10 @@ func (s *SubmissionService) filterQueryMaker(filter *kilonova.SubmissionFilter)
 		where, args = append(where, "quality = ?"), append(args, v)
 	}
 
+	if v := filter.VerdictCode; v != nil {
+		where, args = append(where, "EXISTS (SELECT 1 FROM submission_tests WHERE submission_tests.submission_id = submissions.id AND submission_tests.verdict_code = ?)"), append(args, v)
+	}
+
 	return where, args
 }
 
@@ -190,10 +392,21 @@ func (s *SubmissionService) updateQueryMaker(upd *kilonova.SubmissionUpdate) ([]
 	toUpd, args := []string{}, []interface{}{}
 	if v := upd.Status; v != kilonova.StatusNone {
 		toUpd, args = append(toUpd, "status = ?"), append(args, v)
+		// Only working submissions can be leased
+		if v != kilonova.StatusWorking {
+			toUpd = append(toUpd, "worker_id = NULL", "lease_expires_at = NULL")
+		}
+		// A reevaluation starts over
+		if v == kilonova.StatusWaiting {
+			toUpd = append(toUpd, "attempts = 0")
+		}
 	}
 	if v := upd.Score; v != nil {
 		toUpd, args = append(toUpd, "score = ?"), append(args, v)
 	}
+	if v := upd.Priority; v != nil {
+		toUpd, args = append(toUpd, "priority = ?"), append(args, v)
+	}
 
 	if v := upd.CompileError; v != nil {
 		toUpd, args = append(toUpd, "compile_error = ?"), append(args, v)
@@ -213,5 +426,46 @@ func (s *SubmissionService) updateQueryMaker(upd *kilonova.SubmissionUpdate) ([]
 }
 
 func NewSubmissionService(db *sqlx.DB) kilonova.SubmissionService {
-	return &SubmissionService{db}
+	return &SubmissionService{db, newQueueNotifier()}
+}
+
+// leaseExpiry returns the expiry time of a lease starting now.
+// It is always in UTC, so the times stored by SQLite compare correctly as strings
+func leaseExpiry(lease time.Duration) time.Time {
+	return time.Now().Add(lease).UTC()
+}
+
+func checkLeaseHeld(res sql.Result) error {
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if cnt == 0 {
+		return &kilonova.Error{Code: kilonova.ENOTFOUND, Message: "Submission is not held by the worker"}
+	}
+	return nil
+}
+
+// queueNotifier wakes up the graders waiting for submissions.
+// It only works inside a process, other processes notice the new submissions when they poll the queue
+type queueNotifier struct {
+	mu sync.Mutex
+	ch chan struct{}
+}
+
+func newQueueNotifier() *queueNotifier {
+	return &queueNotifier{ch: make(chan struct{})}
+}
+
+func (q *queueNotifier) wait() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.ch
+}
+
+func (q *queueNotifier) notify() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	close(q.ch)
+	q.ch = make(chan struct{})
 }
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+)
+
+func TestBestSubTaskScore(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	pb := newTestProblem(t, db)
+	sserv := db.SubmissionService()
+
+	policy := kilonova.ScoringBestSubtask
+	if err := db.ProblemService().UpdateProblem(ctx, pb.ID, kilonova.ProblemUpdate{ScoringPolicy: policy}); err != nil {
+		t.Fatal(err)
+	}
+	var stks []*kilonova.SubTask
+	for vid, score := range []int{30, 70} {
+		test := &kilonova.Test{ProblemID: pb.ID, VisibleID: vid + 1, Score: score}
+		if err := db.TestService().CreateTest(ctx, test); err != nil {
+			t.Fatal(err)
+		}
+		stk := &kilonova.SubTask{ProblemID: pb.ID, VisibleID: vid + 1, Score: score, Tests: []int{test.ID}}
+		if err := db.SubTaskService().CreateSubTask(ctx, stk); err != nil {
+			t.Fatal(err)
+		}
+		stks = append(stks, stk)
+	}
+
+	// The score is the sum of the best subtask scores across the submissions, even if no submission gets it
+	for i, scores := range []map[int]int{{stks[0].ID: 30, stks[1].ID: 0}, {stks[0].ID: 0, stks[1].ID: 50}, {stks[0].ID: 10, stks[1].ID: 70}} {
+		sub := &kilonova.Submission{UserID: pb.AuthorID, ProblemID: pb.ID, Language: "cpp", Code: "int main() {}"}
+		if err := sserv.CreateSubmission(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+		score := scores[stks[0].ID] + scores[stks[1].ID]
+		if err := sserv.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{Score: &score}); err != nil {
+			t.Fatal(err)
+		}
+		if err := sserv.SetSubTaskScores(ctx, sub.ID, scores); err != nil {
+			t.Fatal(err)
+		}
+
+		want := []int{30, 80, 100}[i]
+		if score := sserv.MaxScore(ctx, pb.AuthorID, pb.ID); score != want {
+			t.Errorf("submission %d: wanted score %d, got %d", i+1, want, score)
+		}
+		if scores := sserv.MaxScores(ctx, pb.AuthorID, []int{pb.ID}); scores[pb.ID] != want {
+			t.Errorf("submission %d: wanted score %d from MaxScores, got %v", i+1, want, scores)
+		}
+		solved, err := sserv.SolvedProblems(ctx, pb.AuthorID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want == 100 && !reflect.DeepEqual(solved, []int{pb.ID}) {
+			t.Errorf("submission %d: wanted the problem to be solved, got %v", i+1, solved)
+		} else if want < 100 && len(solved) > 0 {
+			t.Errorf("submission %d: wanted no solved problems, got %v", i+1, solved)
+		}
+	}
+}
+
+func TestSolvedProblems(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	pb := newTestProblem(t, db)
+	sserv := db.SubmissionService()
+
+	// The points of the problem total 50
+	policy := kilonova.ScoringSum
+	if err := db.ProblemService().UpdateProblem(ctx, pb.ID, kilonova.ProblemUpdate{ScoringPolicy: policy}); err != nil {
+		t.Fatal(err)
+	}
+	for vid, score := range []int{20, 30} {
+		if err := db.TestService().CreateTest(ctx, &kilonova.Test{ProblemID: pb.ID, VisibleID: vid + 1, Score: score}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, score := range []int{20, 50} {
+		sub := &kilonova.Submission{UserID: pb.AuthorID, ProblemID: pb.ID, Language: "cpp", Code: "int main() {}"}
+		if err := sserv.CreateSubmission(ctx, sub); err != nil {
+			t.Fatal(err)
+		}
+		score := score
+		if err := sserv.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{Score: &score}); err != nil {
+			t.Fatal(err)
+		}
+		solved, err := sserv.SolvedProblems(ctx, pb.AuthorID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if score == 50 && !reflect.DeepEqual(solved, []int{pb.ID}) {
+			t.Errorf("score %d: wanted the problem to be solved, got %v", score, solved)
+		} else if score < 50 && len(solved) > 0 {
+			t.Errorf("score %d: wanted no solved problems, got %v", score, solved)
+		}
+	}
+}
+
+func TestSubmissionAttempts(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	pb := newTestProblem(t, db)
+	sserv := db.SubmissionService()
+
+	sub := &kilonova.Submission{UserID: pb.AuthorID, ProblemID: pb.ID, Language: "cpp", Code: "int main() {}"}
+	if err := sserv.CreateSubmission(ctx, sub); err != nil {
+		t.Fatal(err)
+	}
+	if err := sserv.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{Status: kilonova.StatusWaiting}); err != nil {
+		t.Fatal(err)
+	}
+	claim := func(want int) {
+		t.Helper()
+		subs, err := sserv.ClaimSubmissions(ctx, kilonova.ClaimRequest{WorkerID: "worker", Limit: 1, Lease: time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(subs) != 1 || subs[0].Attempts != want {
+			t.Fatalf("wanted the submission with %d attempts, got %+v", want, subs)
+		}
+	}
+
+	claim(1)
+	// The lease expires, so the submission is requeued like after a crash
+	time.Sleep(10 * time.Millisecond)
+	if cnt, err := sserv.RequeueExpiredSubmissions(ctx); err != nil || cnt != 1 {
+		t.Fatalf("wanted 1 requeued submission, got %d (%v)", cnt, err)
+	}
+	claim(2)
+	// Releasing the submission doesn't count as an attempt
+	if err := sserv.ReleaseSubmission(ctx, sub.ID, "worker"); err != nil {
+		t.Fatal(err)
+	}
+	claim(2)
+	// A reevaluation starts over
+	if err := sserv.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{Status: kilonova.StatusWaiting}); err != nil {
+		t.Fatal(err)
+	}
+	claim(1)
+}
@@ -75,9 +75,18 @@ func (s *SubTestService) updateQueryMaker(upd *kilonova.SubTestUpdate) ([]string
 	if v := upd.Verdict; v != nil {
 		toUpd, args = append(toUpd, "verdict = ?"), append(args, v)
 	}
+	if v := upd.VerdictCode; v != nil {
+		toUpd, args = append(toUpd, "verdict_code = ?"), append(args, v)
+	}
 	if v := upd.Done; v != nil {
 		toUpd, args = append(toUpd, "done = ?"), append(args, v)
 	}
+	if v := upd.InteractorTime; v != nil {
+		toUpd, args = append(toUpd, "interactor_time = ?"), append(args, v)
+	}
+	if v := upd.InteractorMemory; v != nil {
+		toUpd, args = append(toUpd, "interactor_memory = ?"), append(args, v)
+	}
 
 	return toUpd, args
 }
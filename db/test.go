@@ -15,7 +15,7 @@ type TestService struct {
 }
 
 func (s *TestService) CreateTest(ctx context.Context, test *kilonova.Test) error {
-	if test.ProblemID == 0 || test.Score == 0 {
+	if test.ProblemID == 0 {
 		return kilonova.ErrMissingRequired
 	}
 
@@ -76,6 +76,47 @@ func (s *TestService) OrphanProblemTest(ctx context.Context, problemID int, test
 	return err
 }
 
+func (s *TestService) ReplaceProblemTests(ctx context.Context, problemID int, testIDs []int, subTasks []*kilonova.SubTask) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, s.db.Rebind("UPDATE tests SET orphaned = true WHERE problem_id = ?"), problemID); err != nil {
+		return err
+	}
+	for _, id := range testIDs {
+		if _, err := tx.ExecContext(ctx, s.db.Rebind("UPDATE tests SET orphaned = false WHERE id = ? AND problem_id = ?"), id, problemID); err != nil {
+			return err
+		}
+	}
+	if subTasks != nil {
+		if _, err := tx.ExecContext(ctx, s.db.Rebind("DELETE FROM subtasks WHERE problem_id = ?"), problemID); err != nil {
+			return err
+		}
+		for _, stk := range subTasks {
+			if err := tx.GetContext(ctx, &stk.ID, s.db.Rebind("INSERT INTO subtasks (problem_id, visible_id, score, tests) VALUES (?, ?, ?, ?) RETURNING id"), problemID, stk.VisibleID, stk.Score, kilonova.SerializeIntList(stk.Tests)); err != nil {
+				return err
+			}
+			stk.ProblemID = problemID
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *TestService) DeleteTests(ctx context.Context, ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In("DELETE FROM tests WHERE id IN (?)", ids)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, s.db.Rebind(query), args...)
+	return err
+}
+
 func (s *TestService) BiggestVID(ctx context.Context, problemID int) (int, error) {
 	var id int
 	err := s.db.GetContext(ctx, &id, s.db.Rebind("SELECT visible_id FROM tests WHERE problem_id = ? AND orphaned = false ORDER BY visible_id DESC LIMIT 1;"), problemID)
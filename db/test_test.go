@@ -0,0 +1,103 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/KiloProjects/kilonova"
+)
+
+func newTestDB(t *testing.T) *SQLiteDB {
+	t.Helper()
+	db, err := NewSQLite(context.Background(), filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newTestProblem(t *testing.T, db *SQLiteDB) *kilonova.Problem {
+	t.Helper()
+	ctx := context.Background()
+	user := &kilonova.User{Name: "author", Email: "author@example.com", Password: "hash"}
+	if err := db.UserService().CreateUser(ctx, user); err != nil {
+		t.Fatal(err)
+	}
+	pb := &kilonova.Problem{Name: "problem", AuthorID: user.ID}
+	if err := db.ProblemService().CreateProblem(ctx, pb); err != nil {
+		t.Fatal(err)
+	}
+	return pb
+}
+
+func TestReplaceProblemTests(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	pb := newTestProblem(t, db)
+	tserv, stkserv := db.TestService(), db.SubTaskService()
+
+	old := &kilonova.Test{ProblemID: pb.ID, VisibleID: 1, Score: 100}
+	if err := tserv.CreateTest(ctx, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := stkserv.CreateSubTask(ctx, &kilonova.SubTask{ProblemID: pb.ID, VisibleID: 1, Score: 100, Tests: []int{old.ID}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []int
+	for vid := 1; vid <= 2; vid++ {
+		test := &kilonova.Test{ProblemID: pb.ID, VisibleID: vid, Score: 50, Orphaned: true}
+		if err := tserv.CreateTest(ctx, test); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, test.ID)
+	}
+	// The new tests are not visible before the swap
+	if tests, err := tserv.Tests(ctx, pb.ID); err != nil || len(tests) != 1 || tests[0].ID != old.ID {
+		t.Fatalf("wanted only the old test, got %v (%v)", tests, err)
+	}
+
+	stks := []*kilonova.SubTask{{VisibleID: 1, Score: 40, Tests: ids[:1]}, {VisibleID: 2, Score: 60, Tests: ids[1:]}}
+	if err := tserv.ReplaceProblemTests(ctx, pb.ID, ids, stks); err != nil {
+		t.Fatal(err)
+	}
+	tests, err := tserv.Tests(ctx, pb.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int
+	for _, test := range tests {
+		got = append(got, test.ID)
+	}
+	if !reflect.DeepEqual(got, ids) {
+		t.Errorf("wanted tests %v, got %v", ids, got)
+	}
+	newStks, err := stkserv.SubTasks(ctx, pb.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(newStks) != 2 || newStks[0].ID != stks[0].ID || !reflect.DeepEqual(newStks[1].Tests, ids[1:]) {
+		t.Errorf("wrong subtasks %+v", newStks)
+	}
+
+	// Without subtasks, only the tests are swapped
+	if err := tserv.ReplaceProblemTests(ctx, pb.ID, []int{old.ID}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if newStks, err := stkserv.SubTasks(ctx, pb.ID); err != nil || len(newStks) != 2 {
+		t.Errorf("the subtasks were changed: %v (%v)", newStks, err)
+	}
+
+	if err := tserv.DeleteTests(ctx, ids); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tserv.TestByID(ctx, ids[0]); err == nil {
+		t.Error("the test wasn't deleted")
+	}
+	if _, err := tserv.TestByID(ctx, old.ID); err != nil {
+		t.Errorf("the old test was deleted: %v", err)
+	}
+}
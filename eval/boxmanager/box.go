@@ -3,6 +3,7 @@ package boxmanager
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -13,15 +14,19 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 
 	"github.com/KiloProjects/kilonova"
 	"github.com/KiloProjects/kilonova/eval"
 	"github.com/KiloProjects/kilonova/internal/config"
+	"github.com/KiloProjects/kilonova/internal/metrics"
 	"github.com/davecgh/go-spew/spew"
 )
 
 var _ eval.Sandbox = &Box{}
 
+var isolateFailures = metrics.NewCounter("kilonova_isolate_failures_total", "Number of runs in which isolate itself failed (status XX or no meta file)")
+
 // Env represents a variable-value pair for an environment variable
 type Env struct {
 	Var   string
@@ -46,9 +51,10 @@ func (b *Box) buildRunFlags(c *eval.RunConfig) (res []string) {
 	res = append(res, "--box-id="+strconv.Itoa(b.boxID))
 
 	res = append(res, "--cg", "--cg-timing")
-	/*if c.MemoryLimit != 0 {
+	// The memory limit is enforced on the whole control group, so going over it can be told apart from a runtime error
+	if c.MemoryLimit != 0 {
 		res = append(res, "--cg-mem="+strconv.Itoa(c.MemoryLimit))
-	}*/
+	}
 	for _, dir := range c.Directories {
 		if dir.Removes {
 			res = append(res, "--dir="+dir.In+"=")
@@ -87,12 +93,12 @@ func (b *Box) buildRunFlags(c *eval.RunConfig) (res []string) {
 		res = append(res, "--wall-time="+strconv.FormatFloat(c.WallTimeLimit, 'f', -1, 64))
 	}
 
-	if c.MemoryLimit != 0 {
-		res = append(res, "--mem="+strconv.Itoa(c.MemoryLimit))
-	}
 	if c.StackLimit != 0 {
 		res = append(res, "--stack="+strconv.Itoa(c.StackLimit))
 	}
+	if c.OutputLimit != 0 {
+		res = append(res, "--fsize="+strconv.Itoa(c.OutputLimit))
+	}
 
 	if c.MaxProcs == 0 {
 		res = append(res, "--processes")
@@ -120,6 +126,23 @@ func (b *Box) WriteFile(fpath string, r io.Reader, mode fs.FileMode) error {
 	return writeReader(b.getFilePath(fpath), r, mode)
 }
 
+// LinkFile hard links the file in the box. The program in the box must not be able to change it,
+// so files that are writable by other users are refused
+func (b *Box) LinkFile(src string, fpath string) error {
+	stat, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !stat.Mode().IsRegular() || stat.Mode().Perm()&0022 != 0 {
+		return fmt.Errorf("%s can't be linked read-only", src)
+	}
+	dst := b.getFilePath(fpath)
+	if err := os.Remove(dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return os.Link(src, dst)
+}
+
 func (b *Box) ReadFile(fpath string) (io.ReadSeekCloser, error) {
 	return os.Open(b.getFilePath(fpath))
 }
@@ -178,7 +201,18 @@ func (b *Box) RunCommand(ctx context.Context, command []string, conf *eval.RunCo
 		cmd.Stdout = conf.Stdout
 		cmd.Stderr = conf.Stderr
 	}
-	err := cmd.Run()
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	// The child process has its own copies of the pipes now
+	if conf != nil {
+		for _, f := range conf.CloseAfterStart {
+			f.Close()
+		}
+	}
+
+	err := cmd.Wait()
 	if _, ok := err.(*exec.ExitError); err != nil && !ok {
 		spew.Dump(err)
 		return nil, err
@@ -188,10 +222,38 @@ func (b *Box) RunCommand(ctx context.Context, command []string, conf *eval.RunCo
 	f, err := os.Open(metaFile)
 	if err != nil {
 		fmt.Println("Couldn't open meta file, wtf", err)
+		isolateFailures.Inc()
 		return nil, nil
 	}
 	defer f.Close()
-	return parseMetaFile(f), nil
+	stats := parseMetaFile(f)
+	if stats.Status == "XX" {
+		isolateFailures.Inc()
+	}
+	// Writing past the file size limit kills the program with SIGXFSZ.
+	// Runtimes that catch the signal or get EFBIG fail with an error instead, after the output reached the limit
+	if conf != nil && conf.OutputLimit != 0 {
+		failed := stats.ExitCode != 0 || stats.ExitSignal != 0
+		if stats.ExitSignal == int(syscall.SIGXFSZ) || (failed && b.reachedOutputLimit(conf)) {
+			stats.OutputLimitExceeded = true
+		}
+	}
+	return stats, nil
+}
+
+// reachedOutputLimit reports if one of the output files of the program is as large as the output limit
+func (b *Box) reachedOutputLimit(conf *eval.RunConfig) bool {
+	files := conf.OutputFiles
+	if conf.OutputPath != "" {
+		files = append([]string{conf.OutputPath}, files...)
+	}
+	for _, file := range files {
+		stat, err := os.Stat(b.getFilePath(file))
+		if err == nil && stat.Size() >= int64(conf.OutputLimit)*1024 {
+			return true
+		}
+	}
+	return false
 }
 
 // newBox returns a new box instance from the specified ID
@@ -265,6 +327,8 @@ func parseMetaFile(r io.Reader) *eval.RunStats {
 			file.ExitSignal, _ = strconv.Atoi(l[1])
 		case "killed":
 			file.Killed = true
+		case "cg-oom-killed":
+			file.MemoryLimitExceeded = true
 		case "message":
 			file.Message = l[1]
 		case "status":
@@ -2,21 +2,21 @@ package boxmanager
 
 import (
 	"context"
+	"fmt"
 	"log"
 
 	"github.com/KiloProjects/kilonova"
 	"github.com/KiloProjects/kilonova/eval"
-	"golang.org/x/sync/semaphore"
 )
 
-var _ eval.Runner = &BoxManager{}
+var _ eval.StatsRunner = &BoxManager{}
 
 // BoxManager manages a box with eval-based submissions
 type BoxManager struct {
 	dm kilonova.GraderStore
 
 	numConcurrent int
-	sem           *semaphore.Weighted
+	sem           *prioritySemaphore
 
 	availableIDs chan int
 
@@ -30,16 +30,52 @@ func (b *BoxManager) ToggleDebug() {
 	b.debug = !b.debug
 }
 
+// RunTask runs the task in a new sandbox. The sandboxes are given by the priority set in the context (see eval.WithPriority)
 func (b *BoxManager) RunTask(ctx context.Context, task eval.Task) error {
-	box, err := b.getSandbox(ctx)
+	prio := eval.PriorityFromContext(ctx)
+	box, err := b.getSandbox(ctx, prio)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
-	defer b.ReleaseSandbox(box)
+	defer b.ReleaseSandbox(box, prio)
 	return task.Execute(ctx, box)
 }
 
+// RunMultiboxTask acquires all the sandboxes needed by the task at once, so they can run simultaneously
+func (b *BoxManager) RunMultiboxTask(ctx context.Context, task eval.MultiboxTask) error {
+	n := task.NumSandboxes()
+	if n > b.numConcurrent {
+		return fmt.Errorf("Task needs %d sandboxes, but only %d can run concurrently", n, b.numConcurrent)
+	}
+	prio := eval.PriorityFromContext(ctx)
+	if err := b.sem.Acquire(ctx, prio, n); err != nil {
+		return err
+	}
+
+	boxes := make([]eval.Sandbox, 0, n)
+	defer func() {
+		for _, box := range boxes {
+			if err := box.Close(); err != nil {
+				log.Printf("Could not release sandbox %d: %v\n", box.GetID(), err)
+			}
+			b.availableIDs <- box.GetID()
+		}
+		b.sem.Release(prio, n)
+	}()
+
+	for i := 0; i < n; i++ {
+		box, err := b.newSandbox()
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		boxes = append(boxes, box)
+	}
+
+	return task.ExecuteMultibox(ctx, boxes)
+}
+
 func (b *BoxManager) newSandbox() (*Box, error) {
 	box, err := newBox(<-b.availableIDs)
 	if err != nil {
@@ -49,24 +85,35 @@ func (b *BoxManager) newSandbox() (*Box, error) {
 	return box, nil
 }
 
-func (b *BoxManager) getSandbox(ctx context.Context) (eval.Sandbox, error) {
-	if err := b.sem.Acquire(ctx, 1); err != nil {
+func (b *BoxManager) getSandbox(ctx context.Context, prio kilonova.Priority) (eval.Sandbox, error) {
+	if err := b.sem.Acquire(ctx, prio, 1); err != nil {
+		return nil, err
+	}
+	box, err := b.newSandbox()
+	if err != nil {
+		b.sem.Release(prio, 1)
 		return nil, err
 	}
-	return b.newSandbox()
+	return box, nil
 }
 
-func (b *BoxManager) ReleaseSandbox(sb eval.Sandbox) {
-	b.sem.Release(1)
+func (b *BoxManager) ReleaseSandbox(sb eval.Sandbox, prio kilonova.Priority) {
+	b.sem.Release(prio, 1)
 	if err := sb.Close(); err != nil {
 		log.Printf("Could not release sandbox %d: %v\n", sb.GetID(), err)
 	}
 	b.availableIDs <- sb.GetID()
 }
 
+// Stats returns the current usage of the sandboxes
+func (b *BoxManager) Stats() eval.RunnerStats {
+	busy, waiting := b.sem.Stats()
+	return eval.RunnerStats{Slots: b.numConcurrent, Busy: busy, Waiting: waiting, IsolateVersions: map[string]string{"local": eval.IsolateVersion()}}
+}
+
 // Close waits for all boxes to finish running
 func (b *BoxManager) Close(ctx context.Context) error {
-	b.sem.Acquire(ctx, int64(b.numConcurrent))
+	b.sem.Acquire(ctx, kilonova.PriorityLive, b.numConcurrent)
 	close(b.availableIDs)
 	return nil
 }
@@ -74,7 +121,7 @@ func (b *BoxManager) Close(ctx context.Context) error {
 // New creates a new box manager
 func New(count int, dm kilonova.GraderStore) (*BoxManager, error) {
 
-	sem := semaphore.NewWeighted(int64(count))
+	sem := newPrioritySemaphore(count, eval.ReservedSlots(count))
 
 	availableIDs := make(chan int, 3*count)
 	for i := 1; i <= 2*count; i++ {
@@ -0,0 +1,152 @@
+package boxmanager
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"github.com/KiloProjects/kilonova"
+)
+
+// prioritySemaphore hands out the sandbox slots to the waiting tasks with the highest priority.
+// When a priority has waiting tasks and uses less than its reserved slots, it is served first, so no priority is fully starved
+type prioritySemaphore struct {
+	mu       sync.Mutex
+	free     int
+	inUse    map[kilonova.Priority]int
+	reserved map[kilonova.Priority]int
+	waiters  map[kilonova.Priority]*list.List
+}
+
+type semWaiter struct {
+	n     int
+	ready chan struct{}
+}
+
+func newPrioritySemaphore(total int, reserved map[kilonova.Priority]int) *prioritySemaphore {
+	s := &prioritySemaphore{
+		free:     total,
+		inUse:    make(map[kilonova.Priority]int),
+		reserved: reserved,
+		waiters:  make(map[kilonova.Priority]*list.List),
+	}
+	for _, p := range kilonova.Priorities {
+		s.waiters[p] = list.New()
+	}
+	return s
+}
+
+// Acquire waits until n slots are given to the specified priority or the context is canceled
+func (s *prioritySemaphore) Acquire(ctx context.Context, p kilonova.Priority, n int) error {
+	p = normalizePriority(p)
+
+	s.mu.Lock()
+	if s.free >= n && !s.hasWaiters() {
+		s.free -= n
+		s.inUse[p] += n
+		s.mu.Unlock()
+		return nil
+	}
+
+	w := &semWaiter{n: n, ready: make(chan struct{})}
+	elem := s.waiters[p].PushBack(w)
+	s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-w.ready:
+			// The slots were given right before the cancellation
+			s.mu.Unlock()
+			s.Release(p, n)
+		default:
+			s.waiters[p].Remove(elem)
+			// The removed waiter might have blocked the others
+			s.dispatch()
+			s.mu.Unlock()
+		}
+		return ctx.Err()
+	}
+}
+
+// Release gives back n slots acquired with the specified priority
+func (s *prioritySemaphore) Release(p kilonova.Priority, n int) {
+	p = normalizePriority(p)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.free += n
+	s.inUse[p] -= n
+	s.dispatch()
+}
+
+// dispatch wakes up the waiters that can be served. s.mu must be held
+func (s *prioritySemaphore) dispatch() {
+	for {
+		p, ok := s.next()
+		if !ok {
+			return
+		}
+		elem := s.waiters[p].Front()
+		w := elem.Value.(*semWaiter)
+		if s.free < w.n {
+			return
+		}
+		s.free -= w.n
+		s.inUse[p] += w.n
+		s.waiters[p].Remove(elem)
+		close(w.ready)
+	}
+}
+
+// next returns the priority that should be served next. s.mu must be held
+func (s *prioritySemaphore) next() (kilonova.Priority, bool) {
+	for _, p := range kilonova.Priorities {
+		if s.waiters[p].Len() > 0 && s.inUse[p] < s.reserved[p] {
+			return p, true
+		}
+	}
+	for _, p := range kilonova.Priorities {
+		if s.waiters[p].Len() > 0 {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+// Stats returns the slots used and the slots waited for, by every priority
+func (s *prioritySemaphore) Stats() (inUse map[kilonova.Priority]int, waiting map[kilonova.Priority]int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inUse = make(map[kilonova.Priority]int)
+	waiting = make(map[kilonova.Priority]int)
+	for _, p := range kilonova.Priorities {
+		inUse[p] = s.inUse[p]
+		for e := s.waiters[p].Front(); e != nil; e = e.Next() {
+			waiting[p] += e.Value.(*semWaiter).n
+		}
+	}
+	return inUse, waiting
+}
+
+func (s *prioritySemaphore) hasWaiters() bool {
+	for _, l := range s.waiters {
+		if l.Len() > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizePriority(p kilonova.Priority) kilonova.Priority {
+	if p > kilonova.PriorityLive {
+		return kilonova.PriorityLive
+	}
+	if p < kilonova.PriorityBackground {
+		return kilonova.PriorityBackground
+	}
+	return p
+}
@@ -0,0 +1,140 @@
+package checkers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/eval/tasks"
+	"github.com/KiloProjects/kilonova/internal/config"
+)
+
+// The compiled checkers are cached in CompilePath/checkers, as <problem ID>-<hash of the language and code>.bin.
+// A problem's lock is held while its checker is compiled, so concurrent submissions compile it only once
+var (
+	cacheMu    sync.Mutex
+	cacheLocks = make(map[int]*sync.Mutex)
+)
+
+func problemLock(pbID int) *sync.Mutex {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if _, ok := cacheLocks[pbID]; !ok {
+		cacheLocks[pbID] = &sync.Mutex{}
+	}
+	return cacheLocks[pbID]
+}
+
+func cacheDir() string {
+	return path.Join(config.Eval.CompilePath, "checkers")
+}
+
+func cachePath(pb *kilonova.Problem) string {
+	sum := sha256.Sum256([]byte(pb.HelperCodeLang + "\x00" + pb.HelperCode))
+	return path.Join(cacheDir(), fmt.Sprintf("%d-%s.bin", pb.ID, hex.EncodeToString(sum[:8])))
+}
+
+func binaryPath(id int) string {
+	return path.Join(config.Eval.CompilePath, fmt.Sprintf("%d.bin", id))
+}
+
+// prepareCached makes the compiled helper code of the problem available as the compilation with the specified ID.
+// The helper code is compiled only if it is not in the cache.
+// If the compilation fails, the compiler output is returned alongside the error
+func prepareCached(ctx context.Context, mgr eval.Runner, pb *kilonova.Problem, id int) (string, error) {
+	lock := problemLock(pb.ID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	cached := cachePath(pb)
+	if _, err := os.Stat(cached); err != nil {
+		if out, err := compileToCache(ctx, mgr, pb, id, cached); err != nil {
+			return out, err
+		}
+	}
+
+	os.Remove(binaryPath(id))
+	if err := os.Link(cached, binaryPath(id)); err == nil {
+		return "", nil
+	}
+	if err := copyFile(cached, binaryPath(id)); err != nil {
+		return "Couldn't copy checker", err
+	}
+	return "", nil
+}
+
+func compileToCache(ctx context.Context, mgr eval.Runner, pb *kilonova.Problem, id int, cached string) (string, error) {
+	if err := os.MkdirAll(cacheDir(), 0777); err != nil {
+		return "Couldn't create checker cache", err
+	}
+
+	job := &tasks.CompileTask{
+		Req: &eval.CompileRequest{
+			ID:   id,
+			Code: []byte(pb.HelperCode),
+			Lang: pb.HelperCodeLang,
+		},
+	}
+
+	if err := mgr.RunTask(ctx, job); err != nil {
+		return "Couldn't compile checker", err
+	}
+
+	if !job.Resp.Success {
+		return fmt.Sprintf("Output:\n%s\nOther:\n%s", job.Resp.Output, job.Resp.Other), &kilonova.Error{Code: kilonova.EINVALID, Message: "Invalid helper code"}
+	}
+
+	if err := os.Rename(binaryPath(id), cached); err != nil {
+		return "Couldn't cache checker", err
+	}
+	return "", nil
+}
+
+// InvalidateCache removes the compiled checkers of the problem. It should be called when any of the checker settings change
+func InvalidateCache(pbID int) error {
+	lock := problemLock(pbID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	matches, err := filepath.Glob(path.Join(cacheDir(), fmt.Sprintf("%d-*.bin", pbID)))
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		if err := os.Remove(match); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	stat, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, stat.Mode())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
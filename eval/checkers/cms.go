@@ -0,0 +1,40 @@
+package checkers
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+)
+
+// cmsMessages are the standard messages of CMS checkers, which CMS translates. They are replaced by the verdict
+var cmsMessages = map[string]bool{
+	"translate:success": true,
+	"translate:wrong":   true,
+	"translate:partial": true,
+}
+
+// cmsResult returns the verdict and the score of a test from the output of a CMS checker.
+// The score is a fraction between 0 and 1, printed to stdout, while the message is printed to stderr
+func cmsResult(stats *eval.RunStats, stdout, stderr string) (kilonova.VerdictCode, string, int) {
+	if stats.Killed || stats.ExitSignal != 0 || stats.ExitCode != 0 {
+		return kilonova.VerdictCheckerFail, withMessage(CheckerFailOut, stderr, ""), 0
+	}
+
+	fields := strings.Fields(stdout)
+	if len(fields) == 0 {
+		return kilonova.VerdictCheckerFail, "Wrong checker output", 0
+	}
+	points, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil || math.IsNaN(points) {
+		return kilonova.VerdictCheckerFail, "Wrong checker output", 0
+	}
+
+	msg := strings.TrimSpace(stderr)
+	if cmsMessages[msg] {
+		msg = ""
+	}
+	return partialResult(int(math.Round(points*100)), msg)
+}
@@ -0,0 +1,29 @@
+package checkers
+
+import (
+	"testing"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+)
+
+func TestCMSResult(t *testing.T) {
+	var tests = []struct {
+		stats          eval.RunStats
+		stdout, stderr string
+		verdict        kilonova.VerdictCode
+		score          int
+	}{
+		{eval.RunStats{}, "1.0\n", "translate:success\n", kilonova.VerdictAccepted, 100},
+		{eval.RunStats{}, "0\n", "translate:wrong", kilonova.VerdictWrongAnswer, 0},
+		{eval.RunStats{}, "0.25", "Too many moves", kilonova.VerdictPartial, 25},
+		{eval.RunStats{}, "", "", kilonova.VerdictCheckerFail, 0},
+		{eval.RunStats{ExitCode: 1}, "1.0", "", kilonova.VerdictCheckerFail, 0},
+	}
+	for _, test := range tests {
+		verdict, out, score := cmsResult(&test.stats, test.stdout, test.stderr)
+		if verdict != test.verdict || score != test.score {
+			t.Errorf("%q %q: got %s (%q) with score %d", test.stdout, test.stderr, verdict, out, score)
+		}
+	}
+}
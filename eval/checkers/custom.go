@@ -5,16 +5,14 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"path"
 
 	"github.com/KiloProjects/kilonova"
 	"github.com/KiloProjects/kilonova/eval"
-	"github.com/KiloProjects/kilonova/eval/tasks"
 	"github.com/KiloProjects/kilonova/internal/config"
 )
 
 var _ eval.Checker = &CustomChecker{}
-var _ eval.Task = &customCheckerTask{}
+var _ eval.Task = &CustomCheckerTask{}
 
 type CustomChecker struct {
 	mgr eval.Runner
@@ -22,78 +20,73 @@ type CustomChecker struct {
 	sub *kilonova.Submission
 }
 
-// Prepare compiles the grader
+// Prepare makes the compiled checker available to the submission, compiling it only if it isn't cached
 func (c *CustomChecker) Prepare(ctx context.Context) (string, error) {
-	job := &tasks.CompileTask{
-		Req: &eval.CompileRequest{
-			ID:   -c.sub.ID,
-			Code: []byte(c.pb.HelperCode),
-			Lang: c.pb.HelperCodeLang,
-		},
-	}
-
-	err := c.mgr.RunTask(ctx, job)
-	if err != nil {
-		return "Couldn't compile checker", err
-	}
-
-	if !job.Resp.Success {
-		return fmt.Sprintf("Output:\n%s\nOther:\n%s", job.Resp.Output, job.Resp.Other), &kilonova.Error{Code: kilonova.EINVALID, Message: "Invalid helper code"}
-	}
-
-	return "", nil
+	return prepareCached(ctx, c.mgr, c.pb, -c.sub.ID)
 }
 
-type customCheckerTask struct {
-	c    *CustomChecker
-	pOut io.Reader
-	cIn  io.Reader
-	cOut io.Reader
+// CustomCheckerTask runs the compiled checker on the program output
+type CustomCheckerTask struct {
+	// CheckerID is the compilation ID of the checker
+	CheckerID int
+	Lang      string
+	Protocol  kilonova.CheckerProtocol
+
+	POut io.Reader
+	CIn  io.Reader
+	COut io.Reader
 
 	// filled by Execute
-	score  int
-	output string
+	Verdict kilonova.VerdictCode
+	Score   int
+	Output  string
 }
 
 var customTaskErr = kilonova.Error{Code: kilonova.EINTERNAL, Message: ErrOut}
 
-func (job *customCheckerTask) Execute(ctx context.Context, box eval.Sandbox) error {
-	lang, ok := config.Languages[job.c.pb.HelperCodeLang]
+func (job *CustomCheckerTask) Execute(ctx context.Context, box eval.Sandbox) error {
+	job.Verdict, job.Output, job.Score = job.run(ctx, box)
+	return nil
+}
+
+func (job *CustomCheckerTask) run(ctx context.Context, box eval.Sandbox) (kilonova.VerdictCode, string, int) {
+	lang, ok := config.Languages[job.Lang]
 	if !ok {
-		job.output = ErrOut
-		return nil
+		return kilonova.VerdictSystemError, ErrOut, 0
 	}
 
-	if err := box.WriteFile("/box/program.out", job.pOut, 0644); err != nil {
-		job.output = ErrOut
-		return nil
+	if err := eval.ReaderInBox(box, "/box/program.out", job.POut); err != nil {
+		return kilonova.VerdictSystemError, ErrOut, 0
 	}
-	if err := box.WriteFile("/box/correct.in", job.cIn, 0644); err != nil {
-		job.output = ErrOut
-		return nil
+	if err := eval.ReaderInBox(box, "/box/correct.in", job.CIn); err != nil {
+		return kilonova.VerdictSystemError, ErrOut, 0
 	}
-	if err := box.WriteFile("/box/correct.out", job.cOut, 0644); err != nil {
-		job.output = ErrOut
-		return nil
+	if err := eval.ReaderInBox(box, "/box/correct.out", job.COut); err != nil {
+		return kilonova.VerdictSystemError, ErrOut, 0
 	}
-	if err := eval.CopyInBox(box, path.Join(config.Eval.CompilePath, fmt.Sprintf("%d.bin", -job.c.sub.ID)), lang.CompiledName); err != nil {
-		job.output = ErrOut
-		return nil
+	if err := eval.CopyInBox(box, binaryPath(job.CheckerID), lang.CompiledName); err != nil {
+		return kilonova.VerdictSystemError, ErrOut, 0
 	}
 
 	goodCmd, err := eval.MakeGoodCommand(lang.RunCommand)
 	if err != nil {
-		job.output = ErrOut
-		return nil
+		return kilonova.VerdictSystemError, ErrOut, 0
 	}
 	// TODO: Make sure all supported languages can have this
-	// Add the program output, correct output and max score parameters
-	goodCmd = append(goodCmd, "/box/program.out", "/box/correct.out", "/box/program.in")
+	switch job.Protocol {
+	case kilonova.CheckerProtocolTestlib:
+		goodCmd = append(goodCmd, "/box/correct.in", "/box/program.out", "/box/correct.out")
+	case kilonova.CheckerProtocolCMS:
+		goodCmd = append(goodCmd, "/box/correct.in", "/box/correct.out", "/box/program.out")
+	default:
+		goodCmd = append(goodCmd, "/box/program.out", "/box/correct.out", "/box/correct.in")
+	}
 
-	var out bytes.Buffer
+	var out, stderr bytes.Buffer
 
 	conf := &eval.RunConfig{
 		Stdout: &out,
+		Stderr: &stderr,
 
 		MemoryLimit: 64 * 1024,
 		StackLimit:  32 * 1024,
@@ -101,35 +94,57 @@ func (job *customCheckerTask) Execute(ctx context.Context, box eval.Sandbox) err
 		MaxProcs: 2,
 	}
 
-	if _, err := box.RunCommand(ctx, goodCmd, conf); err != nil {
-		job.output = ErrOut
-		return nil
+	stats, err := box.RunCommand(ctx, goodCmd, conf)
+	if err != nil {
+		return kilonova.VerdictSystemError, ErrOut, 0
+	}
+	if stats == nil {
+		return kilonova.VerdictCheckerFail, CheckerFailOut, 0
 	}
 
-	if _, err := fmt.Fscanf(&out, "%d ", &job.score); err != nil {
-		job.output = "Wrong checker output"
-		return nil
+	switch job.Protocol {
+	case kilonova.CheckerProtocolTestlib:
+		return testlibResult(stats, stderr.String())
+	case kilonova.CheckerProtocolCMS:
+		return cmsResult(stats, out.String(), stderr.String())
 	}
 
-	job.output = out.String()
-	return nil
+	if stats.Killed || stats.ExitSignal != 0 {
+		return kilonova.VerdictCheckerFail, CheckerFailOut, 0
+	}
+
+	var score int
+	if _, err := fmt.Fscanf(&out, "%d ", &score); err != nil {
+		return kilonova.VerdictCheckerFail, "Wrong checker output", 0
+	}
+	if score < 0 {
+		score = 0
+	}
+	if score > 100 {
+		score = 100
+	}
+
+	return kilonova.ScoreVerdict(score), out.String(), score
 }
 
-func (c *CustomChecker) RunChecker(ctx context.Context, pOut, cIn, cOut io.Reader) (string, int) {
-	task := &customCheckerTask{
-		c:    c,
-		pOut: pOut,
-		cIn:  cIn,
-		cOut: cOut,
+func (c *CustomChecker) RunChecker(ctx context.Context, pOut, cIn, cOut io.Reader) (kilonova.VerdictCode, string, int) {
+	task := &CustomCheckerTask{
+		CheckerID: -c.sub.ID,
+		Lang:      c.pb.HelperCodeLang,
+		Protocol:  c.pb.CheckerProtocol,
+		POut:      pOut,
+		CIn:       cIn,
+		COut:      cOut,
 	}
 
 	if err := c.mgr.RunTask(ctx, task); err != nil {
-		return ErrOut, 0
+		return kilonova.VerdictSystemError, ErrOut, 0
 	}
 
-	return task.output, task.score
+	return task.Verdict, task.Output, task.Score
 }
 
+// Cleanup removes the submission's copy of the checker, the cached one is kept
 func (c *CustomChecker) Cleanup(_ context.Context) error {
 	return eval.CleanCompilation(-c.sub.ID)
 }
@@ -6,13 +6,18 @@ import (
 	"os"
 	"os/exec"
 
+	"github.com/KiloProjects/kilonova"
 	"github.com/KiloProjects/kilonova/eval"
 )
 
 const (
-	ErrOut     = "Internal checker error"
-	CorrectOut = "Correct"
-	WrongOut   = "Wrong Answer"
+	ErrOut          = "Internal checker error"
+	CorrectOut      = "Correct"
+	WrongOut        = "Wrong Answer"
+	PresentationOut = "Presentation Error"
+	PartialOut      = "Partially Correct"
+	// CheckerFailOut is the verdict given when the checker crashes or reports its own failure
+	CheckerFailOut = "Checker failure"
 )
 
 var _ eval.Checker = &DiffChecker{}
@@ -23,37 +28,53 @@ func (d *DiffChecker) Prepare(_ context.Context) (string, error) { return "", ni
 
 func (d *DiffChecker) Cleanup(_ context.Context) error { return nil }
 
-func (d *DiffChecker) RunChecker(ctx context.Context, pOut, cIn, cOut io.Reader) (string, int) {
-	tf, err := os.CreateTemp("", "prog-out-*")
+func (d *DiffChecker) RunChecker(ctx context.Context, pOut, cIn, cOut io.Reader) (kilonova.VerdictCode, string, int) {
+	tf, cleanup, err := diskFile(pOut, "prog-out-*")
 	if err != nil {
-		return ErrOut, 0
+		return kilonova.VerdictSystemError, ErrOut, 0
 	}
-	defer tf.Close()
-	cf, err := os.CreateTemp("", "correct-out-*")
+	defer cleanup()
+	cf, cleanup, err := diskFile(cOut, "correct-out-*")
 	if err != nil {
-		return ErrOut, 0
+		return kilonova.VerdictSystemError, ErrOut, 0
 	}
-	defer cf.Close()
+	defer cleanup()
 
-	if _, err := io.Copy(tf, pOut); err != nil {
-		return ErrOut, 0
-	}
-	if _, err := io.Copy(cf, cOut); err != nil {
-		return ErrOut, 0
-	}
-
-	cmd := exec.CommandContext(ctx, "diff", "-qBbEa", tf.Name(), cf.Name())
+	cmd := exec.CommandContext(ctx, "diff", "-qBbEa", tf, cf)
 	if err := cmd.Run(); err != nil {
 		if err, ok := err.(*exec.ExitError); ok {
 			if err.ExitCode() == 0 {
-				return CorrectOut, 100
+				return kilonova.VerdictAccepted, CorrectOut, 100
 			}
 
-			return WrongOut, 0
+			return kilonova.VerdictWrongAnswer, WrongOut, 0
 		}
 
-		return WrongOut, 0
+		return kilonova.VerdictWrongAnswer, WrongOut, 0
+	}
+
+	return kilonova.VerdictAccepted, CorrectOut, 100
+}
+
+// diskFile returns the path of a file with the contents of the reader.
+// Files on disk are used in place, the other readers are copied to a temporary file, which is removed by cleanup
+func diskFile(r io.Reader, pattern string) (string, func(), error) {
+	if f, ok := r.(*os.File); ok {
+		return f.Name(), func() {}, nil
 	}
 
-	return CorrectOut, 100
+	f, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", nil, err
+	}
+	cleanup := func() { os.Remove(f.Name()) }
+	_, err = io.Copy(f, r)
+	if err1 := f.Close(); err1 != nil && err == nil {
+		err = err1
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return f.Name(), cleanup, nil
 }
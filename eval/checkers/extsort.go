@@ -0,0 +1,167 @@
+package checkers
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"context"
+	"io"
+	"os"
+	"sort"
+)
+
+// maxRunSize is the size of the items that are sorted in memory. Larger outputs are sorted in runs, which are written to temporary files and merged
+var maxRunSize = 32 * 1024 * 1024
+
+// sortedItems returns the items of an output in sorted order
+type sortedItems struct {
+	runs  runHeap
+	files []*os.File
+	// last is the run of the item returned by next, which is advanced on the next call
+	last *itemRun
+}
+
+// itemRun is a sorted run of items, kept either in memory or in a temporary file
+type itemRun struct {
+	mem  [][]byte
+	scan *bufio.Scanner
+	cur  []byte
+}
+
+func (r *itemRun) advance() (bool, error) {
+	if r.scan == nil {
+		if len(r.mem) == 0 {
+			return false, nil
+		}
+		r.cur, r.mem = r.mem[0], r.mem[1:]
+		return true, nil
+	}
+	if !r.scan.Scan() {
+		return false, r.scan.Err()
+	}
+	r.cur = r.scan.Bytes()
+	return true, nil
+}
+
+type runHeap []*itemRun
+
+func (h runHeap) Len() int            { return len(h) }
+func (h runHeap) Less(i, j int) bool  { return bytes.Compare(h[i].cur, h[j].cur) < 0 }
+func (h runHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(*itemRun)) }
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// sortItems reads the tokens of r, or its lines if lines is set, and sorts them.
+// Whitespace inside a line is normalized and empty lines are ignored
+func sortItems(ctx context.Context, r io.Reader, lines bool) (*sortedItems, error) {
+	s := &sortedItems{}
+	var sc *bufio.Scanner
+	if lines {
+		sc = bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 0, 64*1024), maxTokenSize)
+	} else {
+		sc = newTokenScanner(r)
+	}
+
+	var chunk [][]byte
+	size := 0
+	for i := 0; sc.Scan(); i++ {
+		if i%(1<<16) == 0 && ctx.Err() != nil {
+			s.Close()
+			return nil, ctx.Err()
+		}
+		var item []byte
+		if lines {
+			if item = bytes.Join(bytes.Fields(sc.Bytes()), []byte{' '}); len(item) == 0 {
+				continue
+			}
+		} else {
+			item = append([]byte(nil), sc.Bytes()...)
+		}
+		chunk = append(chunk, item)
+		if size += len(item); size >= maxRunSize {
+			if err := s.spill(chunk); err != nil {
+				s.Close()
+				return nil, err
+			}
+			chunk, size = nil, 0
+		}
+	}
+	if err := sc.Err(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	sortChunk(chunk)
+	if err := s.addRun(&itemRun{mem: chunk}); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func sortChunk(chunk [][]byte) {
+	sort.Slice(chunk, func(i, j int) bool { return bytes.Compare(chunk[i], chunk[j]) < 0 })
+}
+
+// spill sorts the chunk and writes it to a temporary file. Items never contain newlines, so they are written one per line
+func (s *sortedItems) spill(chunk [][]byte) error {
+	sortChunk(chunk)
+	f, err := os.CreateTemp("", "kn-unordered-*")
+	if err != nil {
+		return err
+	}
+	s.files = append(s.files, f)
+
+	w := bufio.NewWriter(f)
+	for _, item := range chunk {
+		w.Write(item)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), maxTokenSize+1)
+	return s.addRun(&itemRun{scan: sc})
+}
+
+func (s *sortedItems) addRun(r *itemRun) error {
+	ok, err := r.advance()
+	if ok {
+		heap.Push(&s.runs, r)
+	}
+	return err
+}
+
+// next returns the smallest item that wasn't returned yet. It is valid until the next call
+func (s *sortedItems) next() ([]byte, bool, error) {
+	if s.last != nil {
+		if err := s.addRun(s.last); err != nil {
+			return nil, false, err
+		}
+		s.last = nil
+	}
+	if len(s.runs) == 0 {
+		return nil, false, nil
+	}
+	s.last = heap.Pop(&s.runs).(*itemRun)
+	return s.last.cur, true, nil
+}
+
+// Close removes the temporary files
+func (s *sortedItems) Close() error {
+	for _, f := range s.files {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	s.files = nil
+	return nil
+}
@@ -0,0 +1,95 @@
+package checkers
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+)
+
+// Exit codes used by testlib checkers
+const (
+	testlibOK          = 0
+	testlibWA          = 1
+	testlibPE          = 2
+	testlibFail        = 3
+	testlibDirt        = 4
+	testlibPoints      = 7
+	testlibUnexpectEOF = 8
+	// testlibPartial is the exit code of _pc(0), _pc(score) exits with testlibPartial+score
+	testlibPartial = 16
+)
+
+// maxCheckerMessage is the maximum length of the checker message that is shown in the verdict
+const maxCheckerMessage = 256
+
+// testlibResult returns the verdict and the score of a test from the exit status and the stderr of a testlib checker.
+// Partial scores (_pc) are percentages of the test score, while quitp points are fractions between 0 and 1
+func testlibResult(stats *eval.RunStats, stderr string) (kilonova.VerdictCode, string, int) {
+	if stats.Killed || stats.ExitSignal != 0 {
+		return kilonova.VerdictCheckerFail, CheckerFailOut, 0
+	}
+
+	switch code := stats.ExitCode; {
+	case code == testlibOK:
+		return kilonova.VerdictAccepted, withMessage(CorrectOut, stderr, "ok"), 100
+	case code == testlibWA:
+		return kilonova.VerdictWrongAnswer, withMessage(WrongOut, stderr, "wrong answer"), 0
+	case code == testlibPE, code == testlibDirt, code == testlibUnexpectEOF:
+		return kilonova.VerdictWrongAnswer, withMessage(PresentationOut, stderr, "wrong output format"), 0
+	case code == testlibPoints:
+		msg := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(stderr), "points"))
+		fields := strings.SplitN(msg, " ", 2)
+		points, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil || math.IsNaN(points) {
+			return kilonova.VerdictCheckerFail, CheckerFailOut, 0
+		}
+		msg = ""
+		if len(fields) > 1 {
+			msg = fields[1]
+		}
+		return partialResult(int(math.Round(points*100)), msg)
+	case code >= testlibPartial:
+		msg := strings.TrimPrefix(strings.TrimSpace(stderr), "partially correct")
+		return partialResult(code-testlibPartial, msg)
+	default:
+		return kilonova.VerdictCheckerFail, withMessage(CheckerFailOut, stderr, "FAIL"), 0
+	}
+}
+
+func partialResult(score int, msg string) (kilonova.VerdictCode, string, int) {
+	if score < 0 {
+		score = 0
+	}
+	if score > 100 {
+		score = 100
+	}
+	verdict := PartialOut
+	switch score {
+	case 0:
+		verdict = WrongOut
+	case 100:
+		verdict = CorrectOut
+	}
+	return kilonova.ScoreVerdict(score), withMessage(verdict, msg, ""), score
+}
+
+// withMessage appends the checker message to the verdict, without the testlib outcome prefix
+func withMessage(verdict, msg, prefix string) string {
+	msg = strings.TrimSpace(msg)
+	if prefix != "" {
+		msg = strings.TrimSpace(strings.TrimPrefix(msg, prefix))
+	}
+	if i := strings.IndexByte(msg, '\n'); i >= 0 {
+		msg = msg[:i]
+	}
+	if len(msg) > maxCheckerMessage {
+		msg = msg[:maxCheckerMessage] + "..."
+	}
+	if msg == "" {
+		return verdict
+	}
+	return verdict + ": " + msg
+}
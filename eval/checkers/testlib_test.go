@@ -0,0 +1,36 @@
+package checkers
+
+import (
+	"testing"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+)
+
+func TestTestlibResult(t *testing.T) {
+	var tests = []struct {
+		stats   eval.RunStats
+		stderr  string
+		verdict kilonova.VerdictCode
+		out     string
+		score   int
+	}{
+		{eval.RunStats{ExitCode: 0}, "ok 3 numbers\n", kilonova.VerdictAccepted, CorrectOut + ": 3 numbers", 100},
+		{eval.RunStats{ExitCode: 1}, "wrong answer expected 3, found 4", kilonova.VerdictWrongAnswer, WrongOut + ": expected 3, found 4", 0},
+		{eval.RunStats{ExitCode: 2}, "wrong output format Unexpected end of file", kilonova.VerdictWrongAnswer, PresentationOut + ": Unexpected end of file", 0},
+		{eval.RunStats{ExitCode: 3}, "FAIL answer is wrong", kilonova.VerdictCheckerFail, CheckerFailOut + ": answer is wrong", 0},
+		{eval.RunStats{ExitCode: 7}, "points 0.5 half of the queries", kilonova.VerdictPartial, PartialOut + ": half of the queries", 50},
+		{eval.RunStats{ExitCode: 7}, "0.25", kilonova.VerdictPartial, PartialOut, 25},
+		{eval.RunStats{ExitCode: 7}, "points 1", kilonova.VerdictAccepted, CorrectOut, 100},
+		{eval.RunStats{ExitCode: 7}, "points many", kilonova.VerdictCheckerFail, CheckerFailOut, 0},
+		{eval.RunStats{ExitCode: 16 + 40}, "partially correct 2 of 5", kilonova.VerdictPartial, PartialOut + ": 2 of 5", 40},
+		{eval.RunStats{ExitCode: 5}, "", kilonova.VerdictCheckerFail, CheckerFailOut, 0},
+		{eval.RunStats{Killed: true}, "ok", kilonova.VerdictCheckerFail, CheckerFailOut, 0},
+	}
+	for _, test := range tests {
+		verdict, out, score := testlibResult(&test.stats, test.stderr)
+		if verdict != test.verdict || out != test.out || score != test.score {
+			t.Errorf("exit code %d, %q: got %s (%q) with score %d", test.stats.ExitCode, test.stderr, verdict, out, score)
+		}
+	}
+}
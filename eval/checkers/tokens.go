@@ -0,0 +1,152 @@
+package checkers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+)
+
+// maxTokenSize is the size of the longest token accepted by TokenChecker
+const maxTokenSize = 16 * 1024 * 1024
+
+var _ eval.Checker = &TokenChecker{}
+var _ eval.Checker = &UnorderedChecker{}
+
+// NewComparator returns the built-in checker selected by the problem
+func NewComparator(pb *kilonova.Problem) eval.Checker {
+	switch pb.Comparator {
+	case kilonova.ComparatorTokens:
+		return &TokenChecker{}
+	case kilonova.ComparatorFloat:
+		return &TokenChecker{Equal: FloatEqual(pb.ComparatorEpsilon)}
+	case kilonova.ComparatorCaseInsensitive:
+		return &TokenChecker{Equal: bytes.EqualFold}
+	case kilonova.ComparatorUnorderedLines:
+		return &UnorderedChecker{Lines: true}
+	case kilonova.ComparatorUnorderedTokens:
+		return &UnorderedChecker{}
+	default:
+		return &DiffChecker{}
+	}
+}
+
+// TokenChecker compares the outputs token by token, reading both of them at the same time.
+// Tokens are separated by any amount of whitespace
+type TokenChecker struct {
+	// Equal reports if a token of the program output matches the one of the correct output.
+	// If it is nil, the tokens must be identical
+	Equal func(pTok, cTok []byte) bool
+}
+
+func (t *TokenChecker) Prepare(_ context.Context) (string, error) { return "", nil }
+
+func (t *TokenChecker) Cleanup(_ context.Context) error { return nil }
+
+func (t *TokenChecker) RunChecker(ctx context.Context, pOut, cIn, cOut io.Reader) (kilonova.VerdictCode, string, int) {
+	equal := t.Equal
+	if equal == nil {
+		equal = bytes.Equal
+	}
+
+	ps, cs := newTokenScanner(pOut), newTokenScanner(cOut)
+	for {
+		if err := ctx.Err(); err != nil {
+			return kilonova.VerdictSystemError, ErrOut, 0
+		}
+
+		pOk, cOk := ps.Scan(), cs.Scan()
+		if !cOk {
+			if cs.Err() != nil {
+				return kilonova.VerdictSystemError, ErrOut, 0
+			}
+			if pOk || ps.Err() != nil {
+				return kilonova.VerdictWrongAnswer, WrongOut, 0
+			}
+			return kilonova.VerdictAccepted, CorrectOut, 100
+		}
+		if !pOk || !equal(ps.Bytes(), cs.Bytes()) {
+			return kilonova.VerdictWrongAnswer, WrongOut, 0
+		}
+	}
+}
+
+func newTokenScanner(r io.Reader) *bufio.Scanner {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), maxTokenSize)
+	s.Split(bufio.ScanWords)
+	return s
+}
+
+// FloatEqual returns a token comparison function that accepts numbers whose absolute or relative difference is at most eps.
+// Tokens of the correct output that are not numbers must be matched exactly
+func FloatEqual(eps float64) func(pTok, cTok []byte) bool {
+	return func(pTok, cTok []byte) bool {
+		if bytes.Equal(pTok, cTok) {
+			return true
+		}
+		c, err := strconv.ParseFloat(string(cTok), 64)
+		if err != nil || math.IsNaN(c) || math.IsInf(c, 0) {
+			return false
+		}
+		p, err := strconv.ParseFloat(string(pTok), 64)
+		if err != nil || math.IsNaN(p) {
+			return false
+		}
+		diff := math.Abs(p - c)
+		return diff <= eps || diff <= eps*math.Abs(c)
+	}
+}
+
+// UnorderedChecker accepts any permutation of the tokens (or lines) of the correct output.
+// Both outputs are sorted and compared exactly, large outputs are sorted on disk
+type UnorderedChecker struct {
+	// Lines makes the checker permute whole lines instead of tokens.
+	// Whitespace inside a line is normalized and empty lines are ignored
+	Lines bool
+}
+
+func (u *UnorderedChecker) Prepare(_ context.Context) (string, error) { return "", nil }
+
+func (u *UnorderedChecker) Cleanup(_ context.Context) error { return nil }
+
+func (u *UnorderedChecker) RunChecker(ctx context.Context, pOut, cIn, cOut io.Reader) (kilonova.VerdictCode, string, int) {
+	cs, err := sortItems(ctx, cOut, u.Lines)
+	if err != nil {
+		return kilonova.VerdictSystemError, ErrOut, 0
+	}
+	defer cs.Close()
+	ps, err := sortItems(ctx, pOut, u.Lines)
+	if err != nil {
+		if ctx.Err() != nil {
+			return kilonova.VerdictSystemError, ErrOut, 0
+		}
+		return kilonova.VerdictWrongAnswer, WrongOut, 0
+	}
+	defer ps.Close()
+
+	for i := 0; ; i++ {
+		if i%(1<<16) == 0 && ctx.Err() != nil {
+			return kilonova.VerdictSystemError, ErrOut, 0
+		}
+		pItem, pOk, pErr := ps.next()
+		cItem, cOk, cErr := cs.next()
+		if pErr != nil || cErr != nil {
+			return kilonova.VerdictSystemError, ErrOut, 0
+		}
+		if !pOk || !cOk {
+			if pOk != cOk {
+				return kilonova.VerdictWrongAnswer, WrongOut, 0
+			}
+			return kilonova.VerdictAccepted, CorrectOut, 100
+		}
+		if !bytes.Equal(pItem, cItem) {
+			return kilonova.VerdictWrongAnswer, WrongOut, 0
+		}
+	}
+}
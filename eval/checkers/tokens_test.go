@@ -0,0 +1,65 @@
+package checkers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/KiloProjects/kilonova"
+)
+
+func TestComparators(t *testing.T) {
+	var tests = []struct {
+		comparator kilonova.Comparator
+		pOut, cOut string
+		correct    bool
+	}{
+		{kilonova.ComparatorTokens, "1 2\n3\n", "1\n2 3", true},
+		{kilonova.ComparatorTokens, "1 2 3 4", "1 2 3", false},
+		{kilonova.ComparatorTokens, "1 2", "1 2 3", false},
+		{kilonova.ComparatorFloat, "0.3333334 YES", "0.333333 YES", true},
+		{kilonova.ComparatorFloat, "1000000.5", "1000000", true}, // relative difference
+		{kilonova.ComparatorFloat, "0.34", "0.333333", false},
+		{kilonova.ComparatorFloat, "0.333333 NO", "0.333333 YES", false},
+		{kilonova.ComparatorCaseInsensitive, "yes\nNo", "YES NO", true},
+		{kilonova.ComparatorCaseInsensitive, "yes", "yep", false},
+		{kilonova.ComparatorUnorderedLines, "3 4\n\n1   2  \n", "1 2\n3 4\n", true},
+		{kilonova.ComparatorUnorderedLines, "1 3\n2 4\n", "1 2\n3 4\n", false},
+		{kilonova.ComparatorUnorderedLines, "1 2\n1 2\n3 4\n", "1 2\n3 4\n", false},
+		{kilonova.ComparatorUnorderedTokens, "4 3\n2 1", "1 2 3 4", true},
+		{kilonova.ComparatorUnorderedTokens, "4 3 2 2", "1 2 3 4", false},
+	}
+
+	for _, test := range tests {
+		pb := &kilonova.Problem{Comparator: test.comparator, ComparatorEpsilon: 1e-6}
+		verdict, out, score := NewComparator(pb).RunChecker(context.Background(), strings.NewReader(test.pOut), strings.NewReader(""), strings.NewReader(test.cOut))
+		if (score == 100) != test.correct || (verdict == kilonova.VerdictAccepted) != test.correct {
+			t.Errorf("%s: got %q for %q (correct output %q)", test.comparator, out, test.pOut, test.cOut)
+		}
+	}
+}
+
+func TestUnorderedCheckerRuns(t *testing.T) {
+	// Sort the outputs in many small runs, like large outputs are
+	defer func(size int) { maxRunSize = size }(maxRunSize)
+	maxRunSize = 8
+
+	var cOut, pOut, wrong strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&cOut, "%d %d\n", i, i*i)
+		fmt.Fprintf(&pOut, "%d  %d\n", 999-i, (999-i)*(999-i))
+		if i == 500 {
+			wrong.WriteString("500 250001\n")
+			continue
+		}
+		fmt.Fprintf(&wrong, "%d %d\n", i, i*i)
+	}
+	checker := &UnorderedChecker{Lines: true}
+	if verdict, _, _ := checker.RunChecker(context.Background(), strings.NewReader(pOut.String()), strings.NewReader(""), strings.NewReader(cOut.String())); verdict != kilonova.VerdictAccepted {
+		t.Errorf("the permuted output got %s", verdict)
+	}
+	if verdict, _, _ := checker.RunChecker(context.Background(), strings.NewReader(wrong.String()), strings.NewReader(""), strings.NewReader(cOut.String())); verdict != kilonova.VerdictWrongAnswer {
+		t.Errorf("the wrong output got %s", verdict)
+	}
+}
@@ -5,12 +5,16 @@ import (
 	"io"
 	"io/fs"
 
+	"github.com/KiloProjects/kilonova"
 	"github.com/KiloProjects/kilonova/internal/config"
 )
 
 type Sandbox interface {
 	ReadFile(path string) (io.ReadSeekCloser, error)
 	WriteFile(path string, r io.Reader, mode fs.FileMode) error
+	// LinkFile makes the file from the host available at the path inside the box, without copying it.
+	// It fails if the file can't be shared safely with the box, then it must be written with WriteFile
+	LinkFile(src string, path string) error
 	RemoveFile(path string) error
 	FileExists(path string) bool
 
@@ -27,23 +31,56 @@ type Checker interface {
 	Prepare(context.Context) (string, error)
 	Cleanup(context.Context) error
 
-	// RunChecker returns a comment and a number [0, 100] signifying the percentage of correctness of the subtest
-	RunChecker(ctx context.Context, programOut, correctInput, correctOut io.Reader) (string, int)
+	// RunChecker returns the verdict, a comment and a number [0, 100] signifying the percentage of correctness of the subtest
+	RunChecker(ctx context.Context, programOut, correctInput, correctOut io.Reader) (kilonova.VerdictCode, string, int)
 }
 
 type Runner interface {
 	RunTask(context.Context, Task) error
+	// RunMultiboxTask runs a task that needs more than one sandbox at the same time (ex: interactive problems)
+	RunMultiboxTask(context.Context, MultiboxTask) error
 	Close(context.Context) error
 }
 
+// RunnerStats is a snapshot of the sandbox usage of a runner
+type RunnerStats struct {
+	// Slots is the number of tasks that can run at the same time
+	Slots int `json:"slots"`
+	// Busy holds the number of slots used by the running tasks, Waiting the number of slots needed by the waiting tasks.
+	// Both are split by priority
+	Busy    map[kilonova.Priority]int `json:"busy"`
+	Waiting map[kilonova.Priority]int `json:"waiting"`
+	// IsolateVersions holds the isolate version of every machine that runs the tasks, by worker address ("local" for this machine)
+	IsolateVersions map[string]string `json:"isolate_versions"`
+}
+
+// StatsRunner is a runner that can report its sandbox usage
+type StatsRunner interface {
+	Runner
+	Stats() RunnerStats
+}
+
 type Task interface {
 	Execute(context.Context, Sandbox) error
 }
 
+// MultiboxTask is a task that needs multiple sandboxes running at the same time
+type MultiboxTask interface {
+	// NumSandboxes returns the number of sandboxes that must be acquired before running the task
+	NumSandboxes() int
+	ExecuteMultibox(context.Context, []Sandbox) error
+}
+
 type CompileRequest struct {
 	ID   int
 	Code []byte
 	Lang string
+
+	// SourceName replaces the name of the language's source file, it has no extension
+	SourceName string
+	// ExtraFiles are written next to the source file, by name.
+	// The ones with one of the language's extensions are compiled together with the source
+	ExtraFiles map[string][]byte
 }
 
 type CompileResponse struct {
@@ -60,14 +97,100 @@ type ExecRequest struct {
 	StackLimit  int
 	MemoryLimit int
 	TimeLimit   float64
+	// OutputLimit is the maximum size of the output file, in kilobytes
+	OutputLimit int
 	Lang        string
+	// Adjustment is applied to the time and memory limits, it depends on the language
+	Adjustment kilonova.LimitAdjustment
 }
 
 type ExecResponse struct {
 	Time       float64
 	Memory     int
 	ExitStatus int
-	Comments   string
+	// Verdict is set only if the execution failed, otherwise the output must be checked
+	Verdict  kilonova.VerdictCode
+	Comments string
+}
+
+// CustomRunRequest runs a compiled program on an input given by the user, outside of any submission
+type CustomRunRequest struct {
+	// ID is the compilation ID of the program
+	ID    int
+	Lang  string
+	Input []byte
+
+	StackLimit  int
+	MemoryLimit int
+	TimeLimit   float64
+	OutputLimit int
+	// Adjustment is applied to the time and memory limits, it depends on the language
+	Adjustment kilonova.LimitAdjustment
+}
+
+// CustomRunResponse holds the output of a custom run. Stdout and Stderr are cut to a maximum size
+type CustomRunResponse struct {
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+	// Truncated is set if stdout or stderr were too long to be returned entirely
+	Truncated bool `json:"truncated"`
+
+	Time       float64 `json:"time"`
+	Memory     int     `json:"memory"`
+	ExitCode   int     `json:"exit_code"`
+	ExitSignal int     `json:"exit_signal"`
+
+	// Verdict is set only if the execution failed
+	Verdict  kilonova.VerdictCode `json:"verdict"`
+	Comments string               `json:"comments"`
+}
+
+// ProgramRequest runs a compiled helper program of a problem, like a test generator or a validator
+type ProgramRequest struct {
+	// ID is the compilation ID of the program
+	ID   int
+	Lang string
+	Args []string
+
+	// InputID is the test whose input is given to the program on stdin. If it is 0, stdin is empty
+	InputID int
+	// OutputID is the subtest where stdout is saved. If it is 0, stdout is discarded
+	OutputID int
+
+	MemoryLimit int
+	TimeLimit   float64
+	// OutputLimit is in kilobytes
+	OutputLimit int
+}
+
+// ProgramResponse holds the result of a ProgramRequest. Stderr is cut to a maximum size
+type ProgramResponse struct {
+	Stderr   string  `json:"stderr"`
+	Time     float64 `json:"time"`
+	Memory   int     `json:"memory"`
+	ExitCode int     `json:"exit_code"`
+
+	// Verdict is set only if the execution failed
+	Verdict  kilonova.VerdictCode `json:"verdict"`
+	Comments string               `json:"comments"`
+}
+
+// InteractiveRequest is an ExecRequest that also runs an interactor
+type InteractiveRequest struct {
+	ExecRequest
+	// InteractorID is the compilation ID of the interactor
+	InteractorID   int
+	InteractorLang string
+}
+
+// InteractiveResponse stores the stats for both the submission and the interactor
+type InteractiveResponse struct {
+	ExecResponse
+	// Score is the percentage given by the interactor
+	Score int
+
+	InteractorTime   float64
+	InteractorMemory int
 }
 
 type RunConfig struct {
@@ -77,9 +200,17 @@ type RunConfig struct {
 
 	InputPath  string
 	OutputPath string
+	// OutputFiles are the files the program writes its output to, other than OutputPath. They are checked for the output limit
+	OutputFiles []string
+
+	// CloseAfterStart holds the files that must be closed after the command has been started.
+	// It is useful when Stdin/Stdout are pipes shared with other sandboxes
+	CloseAfterStart []io.Closer
 
 	MemoryLimit int
 	StackLimit  int
+	// OutputLimit is the maximum size of the files created by the program, in kilobytes
+	OutputLimit int
 
 	TimeLimit     float64
 	WallTimeLimit float64
@@ -100,6 +231,12 @@ type RunStats struct {
 	ExitSignal int  `json:"exit_signal"`
 	Killed     bool `json:"killed"`
 
+	// MemoryLimitExceeded is set if the program was killed by the cgroup memory limit
+	MemoryLimitExceeded bool `json:"memory_limit_exceeded"`
+	// OutputLimitExceeded is set if the program was killed for writing more than the output limit,
+	// or if it failed after its output reached the limit
+	OutputLimitExceeded bool `json:"output_limit_exceeded"`
+
 	Message string `json:"message"`
 	Status  string `json:"status"`
 
@@ -114,4 +251,37 @@ type Limits struct {
 	// kilobytes
 	StackLimit  int
 	MemoryLimit int
+	// kilobytes, 0 means no limit
+	OutputLimit int
+}
+
+// Adjust returns the limits with the time and memory limits changed by the adjustment
+func (l Limits) Adjust(adj kilonova.LimitAdjustment) Limits {
+	l.TimeLimit, l.MemoryLimit = adj.Apply(l.TimeLimit, l.MemoryLimit)
+	return l
+}
+
+// LanguageAdjustment returns the limit adjustment of the language for the problem.
+// The problem's override is used if it has one, otherwise the one from the language config
+func LanguageAdjustment(pb *kilonova.Problem, lang string) kilonova.LimitAdjustment {
+	if adj, ok := pb.LanguageLimits[lang]; ok {
+		return adj
+	}
+	conf := config.Languages[lang]
+	return kilonova.LimitAdjustment{
+		TimeMultiplier:   conf.TimeMultiplier,
+		TimeOffset:       conf.TimeOffset,
+		MemoryMultiplier: conf.MemoryMultiplier,
+		MemoryOffset:     conf.MemoryOffset,
+	}
+}
+
+// ProblemLimits returns the limits of the problem for the language, with the language adjustment applied
+func ProblemLimits(pb *kilonova.Problem, lang string) Limits {
+	return Limits{
+		TimeLimit:   pb.TimeLimit,
+		StackLimit:  pb.StackLimit,
+		MemoryLimit: pb.MemoryLimit,
+		OutputLimit: pb.OutputSizeLimit,
+	}.Adjust(LanguageAdjustment(pb, lang))
 }
@@ -0,0 +1,99 @@
+package grader
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync/atomic"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/eval/tasks"
+)
+
+// customIDBase is the first compilation ID used by custom runs.
+// It is far below the IDs of submissions (positive) and of their checkers (negated submission IDs)
+const customIDBase = math.MinInt32
+
+// CustomRunResult is the outcome of running a program on an input given by the user
+type CustomRunResult struct {
+	CompileError   bool   `json:"compile_error"`
+	CompileMessage string `json:"compile_message"`
+
+	*eval.CustomRunResponse
+}
+
+func (h *Handler) setRunner(runner eval.Runner) {
+	h.runnerMu.Lock()
+	defer h.runnerMu.Unlock()
+	h.runner = runner
+}
+
+func (h *Handler) getRunner() eval.Runner {
+	h.runnerMu.Lock()
+	defer h.runnerMu.Unlock()
+	return h.runner
+}
+
+// newCustomID returns a compilation ID that is not used by any submission
+func (h *Handler) newCustomID() int {
+	return customIDBase + int(atomic.AddInt64(&h.lastCustomID, 1)%math.MaxInt32)
+}
+
+// CustomRun compiles the code and runs it on the input, with the limits of the problem.
+// Nothing is saved, the compiled program is removed after it ran
+func (h *Handler) CustomRun(ctx context.Context, pb *kilonova.Problem, code, lang string, input []byte) (*CustomRunResult, error) {
+	runner := h.getRunner()
+	if runner == nil {
+		return nil, &kilonova.Error{Code: kilonova.EINTERNAL, Message: "The grader is not running"}
+	}
+	ctx = eval.WithPriority(ctx, kilonova.PriorityLive)
+
+	id := h.newCustomID()
+	req, err := h.compileRequest(ctx, pb, id, code, lang)
+	if err != nil {
+		return nil, err
+	}
+	compile := &tasks.CompileTask{Req: req, Debug: h.debug}
+	if err := runner.RunTask(ctx, compile); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := eval.CleanCompilation(id); err != nil && h.debug {
+			log.Println("Couldn't clean custom run:", err)
+		}
+	}()
+
+	res := &CustomRunResult{CompileMessage: compile.Resp.Output}
+	if !compile.Resp.Success {
+		res.CompileError = true
+		return res, nil
+	}
+
+	task := &tasks.CustomRunTask{
+		Req: &eval.CustomRunRequest{
+			ID:          id,
+			Lang:        lang,
+			Input:       input,
+			StackLimit:  pb.StackLimit,
+			MemoryLimit: pb.MemoryLimit,
+			TimeLimit:   pb.TimeLimit,
+			OutputLimit: pb.OutputSizeLimit,
+			Adjustment:  eval.LanguageAdjustment(pb, lang),
+		},
+		Resp:  &eval.CustomRunResponse{},
+		Debug: h.debug,
+	}
+	if err := runner.RunTask(ctx, task); err != nil {
+		return nil, err
+	}
+	lim := eval.ProblemLimits(pb, lang)
+	if task.Resp.Verdict == kilonova.VerdictNone && task.Resp.Time > lim.TimeLimit {
+		task.Resp.Verdict, task.Resp.Comments = kilonova.VerdictTimeLimit, "TLE"
+	}
+	if task.Resp.Verdict == kilonova.VerdictNone && task.Resp.Memory > lim.MemoryLimit {
+		task.Resp.Verdict, task.Resp.Comments = kilonova.VerdictMemoryLimit, "Memory Limit Exceeded"
+	}
+	res.CustomRunResponse = task.Resp
+	return res, nil
+}
@@ -0,0 +1,31 @@
+package grader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/internal/config"
+)
+
+// CheckerBinary compiles the helper code of the problem and returns the compiled program, for the exports that need a checker executable
+func (h *Handler) CheckerBinary(ctx context.Context, pb *kilonova.Problem) ([]byte, error) {
+	if lang, ok := config.Languages[pb.HelperCodeLang]; !ok || !lang.IsCompiled {
+		return nil, &kilonova.Error{Code: kilonova.EINVALID, Message: "The checker must be written in a compiled language"}
+	}
+	runner := h.getRunner()
+	if runner == nil {
+		return nil, &kilonova.Error{Code: kilonova.EINTERNAL, Message: "The grader is not running"}
+	}
+	ctx = eval.WithPriority(ctx, kilonova.PriorityLive)
+
+	id, err := h.compileTool(ctx, runner, "checker", &eval.CompileRequest{Code: []byte(pb.HelperCode), Lang: pb.HelperCodeLang})
+	if err != nil {
+		return nil, err
+	}
+	defer eval.CleanCompilation(id)
+	return os.ReadFile(path.Join(config.Eval.CompilePath, fmt.Sprintf("%d.bin", id)))
+}
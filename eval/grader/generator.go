@@ -0,0 +1,258 @@
+package grader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/eval/tasks"
+	"github.com/KiloProjects/kilonova/internal/logic"
+)
+
+// The limits of the generator and the validator, which are more generous than the ones of the problem
+const (
+	toolTimeLimit = 10.0
+	// toolMemoryLimit is in kilobytes
+	toolMemoryLimit = 512 * 1024
+	// toolOutputLimit is the maximum size of a generated input, in kilobytes
+	toolOutputLimit = 256 * 1024
+	// maxToolStderr is the number of bytes of stderr that are shown when a tool fails
+	maxToolStderr = 500
+)
+
+// RegenerateResult is the outcome of a successful test generation
+type RegenerateResult struct {
+	Tests    int `json:"tests"`
+	SubTasks int `json:"subtasks"`
+}
+
+// RegenerateTests runs the generator of the problem with the arguments from its test script, checks every input with the validator
+// and creates the outputs with the reference solution. If all tests were generated, they replace the tests and subtasks of the problem
+func (h *Handler) RegenerateTests(ctx context.Context, pb *kilonova.Problem, ref *kilonova.ProblemSolution) (*RegenerateResult, error) {
+	switch pb.Type {
+	case kilonova.ProblemTypeInteractive:
+		return nil, &kilonova.Error{Code: kilonova.EINVALID, Message: "The tests of interactive problems can't be generated"}
+	case kilonova.ProblemTypeOutputOnly:
+		return nil, &kilonova.Error{Code: kilonova.EINVALID, Message: "The tests of output-only problems can't be generated"}
+	}
+	if pb.GeneratorCode == "" {
+		return nil, &kilonova.Error{Code: kilonova.EINVALID, Message: "The problem has no generator"}
+	}
+	if ref == nil {
+		return nil, &kilonova.Error{Code: kilonova.EINVALID, Message: "A reference solution is needed to create the test outputs"}
+	}
+	script, err := kilonova.ParseTestScript(pb.TestScript)
+	if err != nil {
+		return nil, err
+	}
+	runner := h.getRunner()
+	if runner == nil {
+		return nil, &kilonova.Error{Code: kilonova.EINTERNAL, Message: "The grader is not running"}
+	}
+	ctx = eval.WithPriority(ctx, kilonova.PriorityLive)
+
+	genID, err := h.compileTool(ctx, runner, "generator", &eval.CompileRequest{Code: []byte(pb.GeneratorCode), Lang: pb.GeneratorLang})
+	if err != nil {
+		return nil, err
+	}
+	defer eval.CleanCompilation(genID)
+	valID := 0
+	if pb.ValidatorCode != "" {
+		valID, err = h.compileTool(ctx, runner, "validator", &eval.CompileRequest{Code: []byte(pb.ValidatorCode), Lang: pb.ValidatorLang})
+		if err != nil {
+			return nil, err
+		}
+		defer eval.CleanCompilation(valID)
+	}
+	req, err := h.compileRequest(ctx, pb, 0, ref.Code, ref.Language)
+	if err != nil {
+		return nil, err
+	}
+	refID, err := h.compileTool(ctx, runner, "reference solution", req)
+	if err != nil {
+		return nil, err
+	}
+	defer eval.CleanCompilation(refID)
+
+	store, err := newTempStore(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer store.cleanup()
+
+	// Stop the other tests after the first failure
+	genCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errs := make([]error, len(script.Tests))
+	var wg sync.WaitGroup
+	for i, test := range script.Tests {
+		i, test := i, test
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = h.generateTest(genCtx, runner, pb, ref, store, test, 2*i+1, 2*i+2, genID, valID, refID)
+			if errs[i] != nil {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+	// The failure that canceled the context is more useful than the errors it caused
+	var firstErr error
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	if err := h.replaceTests(ctx, pb, script, store); err != nil {
+		return nil, err
+	}
+	return &RegenerateResult{Tests: len(script.Tests), SubTasks: len(script.SubTasks)}, nil
+}
+
+// compileTool compiles a program with a new custom ID, which is returned. Compilation errors are returned as EINVALID errors
+func (h *Handler) compileTool(ctx context.Context, runner eval.Runner, name string, req *eval.CompileRequest) (int, error) {
+	req.ID = h.newCustomID()
+	compile := &tasks.CompileTask{Req: req, Debug: h.debug}
+	if err := runner.RunTask(ctx, compile); err != nil {
+		return 0, err
+	}
+	if !compile.Resp.Success {
+		eval.CleanCompilation(req.ID)
+		return 0, &kilonova.Error{Code: kilonova.EINVALID, Message: fmt.Sprintf("The %s doesn't compile:\n%s", name, compile.Resp.Output)}
+	}
+	return req.ID, nil
+}
+
+// generateTest saves the input of the test with inID and its output with outID in the store
+func (h *Handler) generateTest(ctx context.Context, runner eval.Runner, pb *kilonova.Problem, ref *kilonova.ProblemSolution, store kilonova.GraderStore, test kilonova.ScriptTest, inID, outID, genID, valID, refID int) error {
+	failed := func(format string, args ...interface{}) error {
+		return &kilonova.Error{Code: kilonova.EINVALID, Message: fmt.Sprintf("Test %d (line %d): %s", test.VisibleID, test.Line, fmt.Sprintf(format, args...))}
+	}
+
+	gen := &tasks.ProgramTask{
+		Req: &eval.ProgramRequest{
+			ID:          genID,
+			Lang:        pb.GeneratorLang,
+			Args:        test.Args,
+			OutputID:    inID,
+			MemoryLimit: toolMemoryLimit,
+			TimeLimit:   toolTimeLimit,
+			OutputLimit: toolOutputLimit,
+		},
+		Resp:  &eval.ProgramResponse{},
+		DM:    store,
+		Debug: h.debug,
+	}
+	if err := runner.RunTask(ctx, gen); err != nil {
+		return err
+	}
+	if gen.Resp.Verdict != kilonova.VerdictNone {
+		return failed("the generator failed: %s", toolFailure(gen.Resp))
+	}
+
+	if valID != 0 {
+		val := &tasks.ProgramTask{
+			Req: &eval.ProgramRequest{
+				ID:          valID,
+				Lang:        pb.ValidatorLang,
+				InputID:     inID,
+				MemoryLimit: toolMemoryLimit,
+				TimeLimit:   toolTimeLimit,
+			},
+			Resp:  &eval.ProgramResponse{},
+			DM:    store,
+			Debug: h.debug,
+		}
+		if err := runner.RunTask(ctx, val); err != nil {
+			return err
+		}
+		if val.Resp.Verdict != kilonova.VerdictNone {
+			return failed("the input is not valid: %s", toolFailure(val.Resp))
+		}
+	}
+
+	execRequest := &eval.ExecRequest{
+		SubID:       refID,
+		SubtestID:   outID,
+		TestID:      inID,
+		Filename:    pb.TestName,
+		StackLimit:  pb.StackLimit,
+		MemoryLimit: pb.MemoryLimit,
+		TimeLimit:   pb.TimeLimit,
+		OutputLimit: pb.OutputSizeLimit,
+		Lang:        ref.Language,
+		Adjustment:  eval.LanguageAdjustment(pb, ref.Language),
+	}
+	if pb.ConsoleInput {
+		execRequest.Filename = "stdin"
+	}
+	exec := &tasks.ExecuteTask{Req: execRequest, Resp: &eval.ExecResponse{}, DM: store, Debug: h.debug}
+	if err := runner.RunTask(ctx, exec); err != nil {
+		return err
+	}
+	lim := eval.ProblemLimits(pb, ref.Language)
+	resp := h.rerunNearLimit(ctx, runner, store, exec, lim.TimeLimit)
+	if resp.Verdict == kilonova.VerdictNone && resp.Time > lim.TimeLimit {
+		resp.Verdict, resp.Comments = kilonova.VerdictTimeLimit, "TLE"
+	}
+	if resp.Verdict == kilonova.VerdictNone && resp.Memory > lim.MemoryLimit {
+		resp.Verdict, resp.Comments = kilonova.VerdictMemoryLimit, "Memory Limit Exceeded"
+	}
+	if resp.Verdict != kilonova.VerdictNone {
+		return failed("the reference solution got %s (%s)", resp.Verdict, resp.Comments)
+	}
+	return nil
+}
+
+// replaceTests puts the tests from the script, with the data from the store, and the subtasks of the script in place of the old ones
+func (h *Handler) replaceTests(ctx context.Context, pb *kilonova.Problem, script *kilonova.TestScript, store *tempStore) error {
+	tests := make([]logic.NewTest, 0, len(script.Tests))
+	for i, st := range script.Tests {
+		inID, outID := 2*i+1, 2*i+2
+		tests = append(tests, logic.NewTest{
+			VisibleID: st.VisibleID,
+			Score:     st.Score,
+			Input:     func() (io.ReadCloser, error) { return store.TestInput(inID) },
+			Output:    func() (io.ReadCloser, error) { return store.TestOutput(outID) },
+		})
+	}
+	subTasks := make([]logic.ManifestSubTask, 0, len(script.SubTasks))
+	for _, sst := range script.SubTasks {
+		subTasks = append(subTasks, logic.ManifestSubTask{VisibleID: sst.VisibleID, Score: sst.Score, Tests: sst.Tests})
+	}
+	if err := h.kn.ReplaceTests(ctx, pb.ID, tests, subTasks); err != nil {
+		return err
+	}
+	log.Printf("Generated %d tests for problem %d\n", len(script.Tests), pb.ID)
+	return nil
+}
+
+// toolFailure describes why a generator or validator failed, using the end of its stderr
+func toolFailure(resp *eval.ProgramResponse) string {
+	msg := resp.Comments
+	if resp.ExitCode != 0 {
+		msg = fmt.Sprintf("exit code %d", resp.ExitCode)
+	}
+	stderr := strings.TrimSpace(resp.Stderr)
+	if len(stderr) > maxToolStderr {
+		stderr = "..." + stderr[len(stderr)-maxToolStderr:]
+	}
+	if stderr != "" {
+		msg += "\n" + stderr
+	}
+	return msg
+}
@@ -5,8 +5,9 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io/fs"
 	"log"
-	"math"
+	"os"
 	"sync"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/KiloProjects/kilonova/eval"
 	"github.com/KiloProjects/kilonova/eval/boxmanager"
 	"github.com/KiloProjects/kilonova/eval/checkers"
+	"github.com/KiloProjects/kilonova/eval/remote"
 	"github.com/KiloProjects/kilonova/eval/tasks"
 	"github.com/KiloProjects/kilonova/internal/config"
 	"github.com/KiloProjects/kilonova/internal/logic"
@@ -21,9 +23,17 @@ import (
 )
 
 var (
-	True          = true
-	waitingSubs   = kilonova.SubmissionFilter{Status: kilonova.StatusWaiting}
-	workingUpdate = kilonova.SubmissionUpdate{Status: kilonova.StatusWorking}
+	True = true
+)
+
+const (
+	// submissionLease is how long a claimed submission is held before another grader can take it
+	submissionLease = 2 * time.Minute
+	// leaseRenewInterval must be way smaller than submissionLease, so the leases don't expire while the grader is alive
+	leaseRenewInterval = 30 * time.Second
+	// maxSubmissionAttempts is the number of times a submission is claimed before the grader gives up on it.
+	// A submission that keeps failing (or crashing the grader) would otherwise be requeued forever
+	maxSubmissionAttempts = 3
 )
 
 type Handler struct {
@@ -38,47 +48,216 @@ type Handler struct {
 	stserv  kilonova.SubTestService
 	tserv   kilonova.TestService
 	stkserv kilonova.SubTaskService
+	aserv   kilonova.AttachmentService
+	solserv kilonova.ProblemSolutionService
+
+	// workerID identifies the grader in the submission queue
+	workerID string
+	// held stores the IDs and priorities of the claimed submissions, whose leases must be renewed
+	held   map[int]kilonova.Priority
+	heldMu sync.Mutex
+	// maxHeld is the maximum number of submissions that are claimed at the same time
+	maxHeld int
+	// freed is signaled when a submission is done, so the feeder can claim another one
+	freed chan struct{}
+
+	// runner is set while the grader is running, it is also used for custom runs
+	runner   eval.Runner
+	runnerMu sync.Mutex
+	// lastCustomID is used to give unique compilation IDs to custom runs
+	lastCustomID int64
+
+	// solTimers holds the scheduled solution checks and solLocks the locks of the running or waiting checks, by problem ID
+	solMu     sync.Mutex
+	solTimers map[int]*time.Timer
+	solLocks  map[int]*solutionLock
 }
 
 func NewHandler(ctx context.Context, kn *logic.Kilonova, db kilonova.TypeServicer) *Handler {
-	ch := make(chan *kilonova.Submission, 5)
-	return &Handler{ctx, ch, kn, kn.DM, kn.Debug,
-		db.SubmissionService(), db.ProblemService(), db.SubTestService(), db.TestService(), db.SubTaskService()}
+	maxHeld := config.Eval.NumConcurrent
+	if maxHeld < 1 {
+		maxHeld = 1
+	}
+	ch := make(chan *kilonova.Submission, maxHeld)
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "grader"
+	}
+	h := &Handler{
+		ctx:   ctx,
+		sChan: ch,
+		kn:    kn,
+		dm:    kn.DM,
+		debug: kn.Debug,
+
+		sserv:   db.SubmissionService(),
+		pserv:   db.ProblemService(),
+		stserv:  db.SubTestService(),
+		tserv:   db.TestService(),
+		stkserv: db.SubTaskService(),
+		aserv:   db.AttachmentService(),
+		solserv: db.ProblemSolutionService(),
+
+		workerID: fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), kilonova.RandomString(6)),
+		held:     make(map[int]kilonova.Priority),
+		maxHeld:  maxHeld,
+		freed:    make(chan struct{}, 1),
+
+		solTimers: make(map[int]*time.Timer),
+		solLocks:  make(map[int]*solutionLock),
+	}
+	h.registerMetrics()
+	return h
 }
 
 // chFeeder "feeds" tChan with relevant data
+// It claims submissions from the queue when they are added or, if the notification is lost, every d
 func (h *Handler) chFeeder(d time.Duration) {
 	ticker := time.NewTicker(d)
+	defer ticker.Stop()
 	for {
+		// Request the notification channel before claiming, so no submission added in the meantime is missed
+		notify := h.sserv.QueueNotify()
+
+		if cnt, err := h.sserv.RequeueExpiredSubmissions(h.ctx); err != nil {
+			log.Println("Error requeueing expired submissions:", err)
+		} else if cnt > 0 {
+			log.Printf("Requeued %d submissions with expired leases\n", cnt)
+		}
+
+		h.claim()
+
 		select {
 		case <-ticker.C:
-			subs, err := h.sserv.Submissions(h.ctx, waitingSubs)
-			if err != nil {
-				log.Println("Error fetching submissions:", err)
-				continue
-			}
-			if subs != nil {
-				if config.Common.Debug {
-					log.Printf("Found %d submissions\n", len(subs))
-				}
+		case <-notify:
+		case <-h.freed:
+		case <-h.ctx.Done():
+			return
+		}
+	}
+}
+
+// claim takes submissions from the queue until maxHeld submissions are held.
+// Every priority first gets its reserved share, the rest goes to the highest priorities
+func (h *Handler) claim() {
+	counts, total := h.heldCounts()
+	free := h.maxHeld - total
+	if free <= 0 {
+		return
+	}
+
+	reserved := eval.ReservedSlots(h.maxHeld)
+	for _, prio := range kilonova.Priorities {
+		want := reserved[prio] - counts[prio]
+		if want > free {
+			want = free
+		}
+		if want <= 0 {
+			continue
+		}
+		prio := prio
+		free -= h.claimSubmissions(kilonova.ClaimRequest{WorkerID: h.workerID, Limit: want, Lease: submissionLease, Priority: &prio})
+	}
+
+	if free > 0 {
+		h.claimSubmissions(kilonova.ClaimRequest{WorkerID: h.workerID, Limit: free, Lease: submissionLease})
+	}
+}
 
-				for _, sub := range subs {
-					//if err := sub.SetStatus(kilonova.StatusWorking, 0); err != nil {
-					if err := h.sserv.UpdateSubmission(h.ctx, sub.ID, workingUpdate); err != nil {
-						log.Println(err)
-						continue
-					}
-					h.sChan <- sub
+// claimSubmissions sends the claimed submissions to the handler and returns their count
+func (h *Handler) claimSubmissions(req kilonova.ClaimRequest) int {
+	subs, err := h.sserv.ClaimSubmissions(h.ctx, req)
+	if err != nil {
+		log.Println("Error claiming submissions:", err)
+		return 0
+	}
+	if len(subs) > 0 && config.Common.Debug {
+		log.Printf("Claimed %d submissions\n", len(subs))
+	}
+
+	for _, sub := range subs {
+		h.hold(sub.ID, sub.Priority)
+		h.sChan <- sub
+	}
+	return len(subs)
+}
+
+// leaseRenewer keeps the leases of the held submissions alive
+func (h *Handler) leaseRenewer() {
+	ticker := time.NewTicker(leaseRenewInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, id := range h.heldIDs() {
+				if err := h.sserv.RenewSubmissionLease(h.ctx, id, h.workerID, submissionLease); err != nil {
+					log.Printf("Could not renew lease of submission %d: %v\n", id, err)
 				}
 			}
 		case <-h.ctx.Done():
-			ticker.Stop()
 			return
 		}
 	}
 }
 
+func (h *Handler) hold(id int, prio kilonova.Priority) {
+	h.heldMu.Lock()
+	defer h.heldMu.Unlock()
+	h.held[id] = prio
+}
+
+func (h *Handler) unhold(id int) {
+	h.heldMu.Lock()
+	delete(h.held, id)
+	h.heldMu.Unlock()
+
+	select {
+	case h.freed <- struct{}{}:
+	default:
+	}
+}
+
+// heldCounts returns the number of held submissions for every priority, and their total
+func (h *Handler) heldCounts() (map[kilonova.Priority]int, int) {
+	h.heldMu.Lock()
+	defer h.heldMu.Unlock()
+	counts := make(map[kilonova.Priority]int)
+	for _, prio := range h.held {
+		counts[prio]++
+	}
+	return counts, len(h.held)
+}
+
+func (h *Handler) heldIDs() []int {
+	h.heldMu.Lock()
+	defer h.heldMu.Unlock()
+	ids := make([]int, 0, len(h.held))
+	for id := range h.held {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// releaseHeld puts the unfinished submissions back in the queue, so other graders can take them
+func (h *Handler) releaseHeld() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, id := range h.heldIDs() {
+		if err := h.sserv.ReleaseSubmission(ctx, id, h.workerID); err != nil {
+			log.Printf("Could not release submission %d: %v\n", id, err)
+		}
+		h.unhold(id)
+	}
+}
+
+// handle evaluates the claimed submissions concurrently. The runner gives the sandboxes by the priority of every submission
 func (h *Handler) handle(ctx context.Context, runner eval.Runner) error {
+	var wg sync.WaitGroup
+	defer func() {
+		wg.Wait()
+		h.releaseHeld()
+	}()
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -88,88 +267,279 @@ func (h *Handler) handle(ctx context.Context, runner eval.Runner) error {
 				return nil
 			}
 
-			task := &tasks.CompileTask{
-				Req:   &eval.CompileRequest{ID: sub.ID, Code: []byte(sub.Code), Lang: sub.Language},
-				Debug: h.debug,
-			}
-			err := runner.RunTask(ctx, task)
-			if err != nil {
-				log.Println("Error from eval:", err)
-				continue
-			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				h.handleSubmission(eval.WithPriority(ctx, sub.Priority), runner, sub)
+				// If the grader is stopping, the submission might not be finished, so releaseHeld must put it back in the queue.
+				// Otherwise, if something failed, the lease will expire and the submission will be evaluated again
+				if ctx.Err() == nil {
+					h.unhold(sub.ID)
+				}
+			}()
+		}
+	}
+}
 
-			resp := task.Resp
-			if h.debug {
-				old := resp.Output
-				resp.Output = "<output stripped>"
-				spew.Dump(resp)
-				resp.Output = old
-			}
+func (h *Handler) handleSubmission(ctx context.Context, runner eval.Runner, sub *kilonova.Submission) {
+	if sub.Attempts > maxSubmissionAttempts {
+		log.Printf("Submission %d couldn't be evaluated after %d attempts\n", sub.ID, maxSubmissionAttempts)
+		h.finishSubTests(ctx, sub.ID, kilonova.VerdictSystemError, "System error")
+		evaluatedSubmissions.Inc(string(kilonova.VerdictSystemError))
+		score := 0
+		if err := h.sserv.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{Status: kilonova.StatusFinished, Score: &score}); err != nil {
+			log.Println("Error during update of failed submission:", err)
+		}
+		return
+	}
 
-			compileError := !resp.Success
-			if err := h.sserv.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{CompileError: &compileError, CompileMessage: &resp.Output}); err != nil {
-				log.Println("Error during update of compile information:", err)
-				continue
-			}
+	problem, err := h.pserv.ProblemByID(ctx, sub.ProblemID)
+	if err != nil {
+		log.Println("Error during submission problem getting:", err)
+		return
+	}
 
-			problem, err := h.pserv.ProblemByID(ctx, sub.ProblemID)
-			if err != nil {
-				log.Println("Error during submission problem getting:", err)
-				continue
-			}
+	outputOnly := problem.Type == kilonova.ProblemTypeOutputOnly
+	// Output-only submissions have nothing to compile, their outputs were saved when they were uploaded
+	resp := eval.CompileResponse{Success: true}
+	if !outputOnly {
+		req, err := h.compileRequest(ctx, problem, sub.ID, sub.Code, sub.Language)
+		if err != nil {
+			log.Println("Couldn't get grader files:", err)
+			return
+		}
+		task := &tasks.CompileTask{Req: req, Debug: h.debug}
+		start := time.Now()
+		err = runner.RunTask(ctx, task)
+		observeDuration(compileDuration, start, sub.Language)
+		if err != nil {
+			log.Println("Error from eval:", err)
+			return
+		}
+		resp = task.Resp
+	}
 
-			checker, err := getAppropriateChecker(runner, sub, problem)
-			if err != nil {
-				log.Println("Could not get checker:", err)
-				continue
-			}
+	if h.debug {
+		old := resp.Output
+		resp.Output = "<output stripped>"
+		spew.Dump(resp)
+		resp.Output = old
+	}
+
+	compileError := !resp.Success
+	if err := h.sserv.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{CompileError: &compileError, CompileMessage: &resp.Output}); err != nil {
+		log.Println("Error during update of compile information:", err)
+		return
+	}
+
+	checker, err := getAppropriateChecker(runner, sub, problem)
+	if err != nil {
+		log.Println("Could not get checker:", err)
+		return
+	}
+
+	if info, err := checker.Prepare(ctx); err != nil {
+		log.Println("Checker prepare error:", err)
+		h.finishSubTests(ctx, sub.ID, kilonova.VerdictCheckerFail, "Checker compilation error")
+		evaluatedSubmissions.Inc(string(kilonova.VerdictCheckerFail))
+		t := true
+		if err := h.sserv.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{Status: kilonova.StatusFinished, Score: &problem.DefaultPoints, CompileError: &t, CompileMessage: &info}); err != nil {
+			log.Println("Error during update of compile information:", err)
+		}
+		return
+	}
 
-			if info, err := checker.Prepare(ctx); err != nil {
-				log.Println("Checker prepare error:", err)
-				t := true
-				if err := h.sserv.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{Status: kilonova.StatusFinished, Score: &problem.DefaultPoints, CompileError: &t, CompileMessage: &info}); err != nil {
-					log.Println("Error during update of compile information:", err)
+	subTests, err := h.stserv.SubTestsBySubID(ctx, sub.ID)
+	if resp.Success == false || err != nil {
+		if resp.Success == false {
+			h.finishSubTests(ctx, sub.ID, kilonova.VerdictCompileError, "Compilation error")
+			evaluatedSubmissions.Inc(string(kilonova.VerdictCompileError))
+		}
+		if err := h.sserv.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{Status: kilonova.StatusFinished, Score: &problem.DefaultPoints}); err != nil {
+			log.Println(err)
+		}
+		return
+	}
+
+	if problem.ShortCircuit {
+		h.handleShortCircuit(ctx, runner, checker, sub, problem, subTests)
+	} else {
+		var wg sync.WaitGroup
+
+		for _, subTest := range subTests {
+			subTest := subTest
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+				if err := h.HandleSubTest(ctx, runner, checker, sub, problem, subTest); err != nil {
+					log.Println("Error handling subTest:", err)
 				}
+			}()
+		}
+
+		wg.Wait()
+	}
+
+	if err := h.ScoreTests(ctx, sub, problem); err != nil {
+		log.Printf("Couldn't score test: %s\n", err)
+	}
+
+	if !outputOnly {
+		if err := eval.CleanCompilation(sub.ID); err != nil {
+			log.Printf("Couldn't clean task: %s\n", err)
+		}
+	}
+
+	if err := checker.Cleanup(ctx); err != nil {
+		log.Printf("Couldn't clean checker: %s\n", err)
+	}
+}
+
+// handleShortCircuit evaluates the subtasks in order. After a test gets no points, the remaining tests of its subtask
+// are not run, unless a later subtask that didn't fail yet also contains them. The tests that are never run are marked as skipped
+func (h *Handler) handleShortCircuit(ctx context.Context, runner eval.Runner, checker eval.Checker, sub *kilonova.Submission, problem *kilonova.Problem, subTests []*kilonova.SubTest) {
+	subTasks, err := h.stkserv.SubTasks(ctx, problem.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Println("Couldn't get subtasks, evaluating all tests:", err)
+		subTasks = nil
+	}
+
+	byTest := make(map[int]*kilonova.SubTest)
+	for _, st := range subTests {
+		byTest[st.TestID] = st
+	}
+	res := &scResults{attempted: make(map[int]bool), scores: make(map[int]int)}
+
+	inSubTask := make(map[int]bool)
+	for _, stk := range subTasks {
+		var toRun []*kilonova.SubTest
+		failed := false
+		for _, id := range stk.Tests {
+			st, ok := byTest[id]
+			if !ok {
 				continue
 			}
-
-			subTests, err := h.stserv.SubTestsBySubID(ctx, sub.ID)
-			if resp.Success == false || err != nil {
-				if err := h.sserv.UpdateSubmission(ctx, sub.ID, kilonova.SubmissionUpdate{Status: kilonova.StatusFinished, Score: &problem.DefaultPoints}); err != nil {
-					log.Println(err)
+			inSubTask[st.ID] = true
+			if res.attempted[st.ID] {
+				// Shared with an earlier subtask
+				if score, ok := res.scores[st.ID]; ok && score == 0 {
+					failed = true
 				}
 				continue
 			}
+			toRun = append(toRun, st)
+		}
+		if failed {
+			continue
+		}
+		h.runSubTestsInOrder(ctx, runner, checker, sub, problem, toRun, res, true)
+	}
 
-			var wg sync.WaitGroup
+	// The tests that are not part of any subtask are always run
+	var rest []*kilonova.SubTest
+	for _, st := range subTests {
+		if !inSubTask[st.ID] {
+			rest = append(rest, st)
+		}
+	}
+	h.runSubTestsInOrder(ctx, runner, checker, sub, problem, rest, res, false)
 
-			for _, subTest := range subTests {
-				subTest := subTest
-				wg.Add(1)
+	score, msg, verdict := 0, "Skipped", kilonova.VerdictSkipped
+	for _, st := range subTests {
+		if res.attempted[st.ID] {
+			continue
+		}
+		if err := h.stserv.UpdateSubTest(ctx, st.ID, kilonova.SubTestUpdate{Score: &score, Verdict: &msg, VerdictCode: &verdict, Done: &True}); err != nil {
+			log.Println("Couldn't mark subtest as skipped:", err)
+		}
+	}
+}
 
-				go func() {
-					defer wg.Done()
-					if err := h.HandleSubTest(ctx, runner, checker, sub, problem, subTest); err != nil {
-						log.Println("Error handling subTest:", err)
-					}
-				}()
-			}
+// scResults holds the subtests that were run by handleShortCircuit and the scores of those that finished
+type scResults struct {
+	mu        sync.Mutex
+	attempted map[int]bool
+	scores    map[int]int
+}
 
-			wg.Wait()
+// runSubTestsInOrder runs the subtests, at most NumConcurrent at a time, and saves their scores in res.
+// If stopOnFail is set, no subtest is started after one of them gets no points
+func (h *Handler) runSubTestsInOrder(ctx context.Context, runner eval.Runner, checker eval.Checker, sub *kilonova.Submission, problem *kilonova.Problem, subTests []*kilonova.SubTest, res *scResults, stopOnFail bool) {
+	numWorkers := config.Eval.NumConcurrent
+	if numWorkers < 1 {
+		numWorkers = 1
+	}
 
-			if err := h.ScoreTests(ctx, sub, problem); err != nil {
-				log.Printf("Couldn't score test: %s\n", err)
-			}
+	var failed bool
+	ch := make(chan *kilonova.SubTest)
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for st := range ch {
+				res.mu.Lock()
+				stop := stopOnFail && failed
+				if !stop {
+					res.attempted[st.ID] = true
+				}
+				res.mu.Unlock()
+				if stop {
+					continue
+				}
 
-			if err := eval.CleanCompilation(sub.ID); err != nil {
-				log.Printf("Couldn't clean task: %s\n", err)
-			}
+				if err := h.HandleSubTest(ctx, runner, checker, sub, problem, st); err != nil {
+					log.Println("Error handling subTest:", err)
+					continue
+				}
+				upd, err := h.stserv.SubTest(ctx, st.ID)
+				if err != nil {
+					log.Println("Couldn't get subtest score:", err)
+					continue
+				}
 
-			if err := checker.Cleanup(ctx); err != nil {
-				log.Printf("Couldn't clean checker: %s\n", err)
+				res.mu.Lock()
+				res.scores[st.ID] = upd.Score
+				if upd.Score == 0 {
+					failed = true
+				}
+				res.mu.Unlock()
 			}
+		}()
+	}
+
+	for _, st := range subTests {
+		ch <- st
+	}
+	close(ch)
+	wg.Wait()
+}
+
+// compileRequest returns the request for compiling the code for the problem, along with the grader files for the language
+func (h *Handler) compileRequest(ctx context.Context, problem *kilonova.Problem, id int, code, lang string) (*eval.CompileRequest, error) {
+	req := &eval.CompileRequest{ID: id, Code: []byte(code), Lang: lang, SourceName: problem.SourceFileName}
+
+	atts, err := h.aserv.Attachments(ctx, true, kilonova.AttachmentFilter{ProblemID: &problem.ID, GraderLang: &lang})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if len(atts) > 0 {
+		req.ExtraFiles = make(map[string][]byte)
+		for _, att := range atts {
+			req.ExtraFiles[att.Name] = att.Data
 		}
 	}
+	return req, nil
+}
+
+// finishSubTests marks all subtests of the submission as done, with the specified verdict and no score
+func (h *Handler) finishSubTests(ctx context.Context, subID int, verdict kilonova.VerdictCode, msg string) {
+	score := 0
+	upd := kilonova.SubTestUpdate{Score: &score, Verdict: &msg, VerdictCode: &verdict, Done: &True}
+	if err := h.stserv.UpdateSubmissionSubTests(ctx, subID, upd); err != nil {
+		log.Println("Couldn't update subtests:", err)
+	}
 }
 
 func (h *Handler) HandleSubTest(ctx context.Context, runner eval.Runner, checker eval.Checker, sub *kilonova.Submission, problem *kilonova.Problem, subTest *kilonova.SubTest) error {
@@ -178,72 +548,187 @@ func (h *Handler) HandleSubTest(ctx context.Context, runner eval.Runner, checker
 		return fmt.Errorf("Error during test getting (0.5): %w", err)
 	}
 
+	if problem.Type == kilonova.ProblemTypeOutputOnly {
+		return h.handleOutputOnlySubTest(ctx, checker, pbTest, subTest)
+	}
+
+	res, err := h.runTest(ctx, runner, checker, h.dm, problem, pbTest, sub.ID, subTest.ID, sub.Language)
+	if err != nil {
+		return err
+	}
+
+	upd := kilonova.SubTestUpdate{Memory: &res.Memory, Score: &res.Score, Time: &res.Time, Verdict: &res.Message, VerdictCode: &res.Verdict, Done: &True}
+	if problem.Type == kilonova.ProblemTypeInteractive {
+		upd.InteractorTime, upd.InteractorMemory = &res.InteractorTime, &res.InteractorMemory
+	}
+	if err := h.stserv.UpdateSubTest(ctx, subTest.ID, upd); err != nil {
+		return fmt.Errorf("Error during evaltest updating: %w", err)
+	}
+	return nil
+}
+
+// testResult is the outcome of running a program on a test
+type testResult struct {
+	Verdict kilonova.VerdictCode
+	Message string
+	Score   int
+	Time    float64
+	Memory  int
+
+	InteractorTime   float64
+	InteractorMemory int
+}
+
+// runTest runs the compiled program with the specified ID on the test and checks its output.
+// The output is saved in the store, under outputID
+func (h *Handler) runTest(ctx context.Context, runner eval.Runner, checker eval.Checker, store kilonova.GraderStore, problem *kilonova.Problem, pbTest *kilonova.Test, id, outputID int, lang string) (*testResult, error) {
 	execRequest := &eval.ExecRequest{
-		SubID:       sub.ID,
-		SubtestID:   subTest.ID,
+		SubID:       id,
+		SubtestID:   outputID,
 		TestID:      pbTest.ID,
 		Filename:    problem.TestName,
 		StackLimit:  problem.StackLimit,
 		MemoryLimit: problem.MemoryLimit,
 		TimeLimit:   problem.TimeLimit,
-		Lang:        sub.Language,
+		OutputLimit: problem.OutputSizeLimit,
+		Lang:        lang,
+		Adjustment:  eval.LanguageAdjustment(problem, lang),
 	}
 	if problem.ConsoleInput {
 		execRequest.Filename = "stdin"
 	}
 
+	if problem.Type == kilonova.ProblemTypeInteractive {
+		return h.runInteractiveTest(ctx, runner, problem, store, execRequest)
+	}
+
 	task := &tasks.ExecuteTask{
 		Req:   execRequest,
 		Resp:  &eval.ExecResponse{},
 		Debug: h.debug,
-		DM:    h.dm,
+		DM:    store,
 	}
 
-	err = runner.RunTask(ctx, task)
-	if err != nil {
-		return fmt.Errorf("Error executing test: %w", err)
+	if err := runner.RunTask(ctx, task); err != nil {
+		return nil, fmt.Errorf("Error executing test: %w", err)
 	}
 
-	resp := task.Resp
+	lim := eval.ProblemLimits(problem, lang)
+	resp := h.rerunNearLimit(ctx, runner, store, task, lim.TimeLimit)
 	var testScore int
 
 	// Make sure TLEs are fully handled
-	if resp.Time > problem.TimeLimit {
-		resp.Comments = "TLE"
+	if resp.Time > lim.TimeLimit {
+		resp.Verdict, resp.Comments = kilonova.VerdictTimeLimit, "TLE"
+	}
+	// The cgroup might not have killed the program if it went over the limit right at the end
+	if resp.Verdict == kilonova.VerdictNone && resp.Memory > lim.MemoryLimit {
+		resp.Verdict, resp.Comments = kilonova.VerdictMemoryLimit, "Memory Limit Exceeded"
 	}
 
-	if resp.Comments == "" {
+	if resp.Verdict == kilonova.VerdictNone {
 		var skipped bool
-		tin, err := h.dm.TestInput(pbTest.ID)
+		tin, err := store.TestInput(pbTest.ID)
 		if err != nil {
-			resp.Comments = "Internal grader error"
+			resp.Verdict, resp.Comments = kilonova.VerdictSystemError, "Internal grader error"
 			skipped = true
 		}
 		defer tin.Close()
-		tout, err := h.dm.TestOutput(pbTest.ID)
+		tout, err := store.TestOutput(pbTest.ID)
 		if err != nil {
-			resp.Comments = "Internal grader error"
+			resp.Verdict, resp.Comments = kilonova.VerdictSystemError, "Internal grader error"
 			skipped = true
 		}
 		defer tout.Close()
-		sout, err := h.dm.SubtestReader(subTest.ID)
+		sout, err := store.SubtestReader(outputID)
 		if err != nil {
-			resp.Comments = "Internal grader error"
+			resp.Verdict, resp.Comments = kilonova.VerdictSystemError, "Internal grader error"
 			skipped = true
 		}
 		defer sout.Close()
 
 		if !skipped {
-			resp.Comments, testScore = checker.RunChecker(ctx, sout, tin, tout)
+			resp.Verdict, resp.Comments, testScore = checker.RunChecker(ctx, sout, tin, tout)
 		}
 	}
 
-	if err := h.stserv.UpdateSubTest(ctx, subTest.ID, kilonova.SubTestUpdate{Memory: &resp.Memory, Score: &testScore, Time: &resp.Time, Verdict: &resp.Comments, Done: &True}); err != nil {
+	return &testResult{Verdict: resp.Verdict, Message: resp.Comments, Score: testScore, Time: resp.Time, Memory: resp.Memory}, nil
+}
+
+// handleOutputOnlySubTest checks the output that was uploaded for the test. A missing output gets no points
+func (h *Handler) handleOutputOnlySubTest(ctx context.Context, checker eval.Checker, pbTest *kilonova.Test, subTest *kilonova.SubTest) error {
+	verdict, msg, testScore := h.checkUploadedOutput(ctx, checker, pbTest, subTest)
+
+	var zero float64
+	var noMem int
+	if err := h.stserv.UpdateSubTest(ctx, subTest.ID, kilonova.SubTestUpdate{Memory: &noMem, Score: &testScore, Time: &zero, Verdict: &msg, VerdictCode: &verdict, Done: &True}); err != nil {
 		return fmt.Errorf("Error during evaltest updating: %w", err)
 	}
 	return nil
 }
 
+func (h *Handler) checkUploadedOutput(ctx context.Context, checker eval.Checker, pbTest *kilonova.Test, subTest *kilonova.SubTest) (kilonova.VerdictCode, string, int) {
+	sout, err := h.dm.SubtestReader(subTest.ID)
+	if errors.Is(err, fs.ErrNotExist) {
+		return kilonova.VerdictWrongAnswer, "Missing output file", 0
+	}
+	if err != nil {
+		return kilonova.VerdictSystemError, "Internal grader error", 0
+	}
+	defer sout.Close()
+
+	tin, err := h.dm.TestInput(pbTest.ID)
+	if err != nil {
+		return kilonova.VerdictSystemError, "Internal grader error", 0
+	}
+	defer tin.Close()
+	tout, err := h.dm.TestOutput(pbTest.ID)
+	if err != nil {
+		return kilonova.VerdictSystemError, "Internal grader error", 0
+	}
+	defer tout.Close()
+
+	return checker.RunChecker(ctx, sout, tin, tout)
+}
+
+// runInteractiveTest runs the program alongside the interactor, which also gives the score and verdict
+func (h *Handler) runInteractiveTest(ctx context.Context, runner eval.Runner, problem *kilonova.Problem, store kilonova.GraderStore, execRequest *eval.ExecRequest) (*testResult, error) {
+	task := &tasks.InteractiveTask{
+		Req: &eval.InteractiveRequest{
+			ExecRequest: *execRequest,
+			// The interactor is compiled by the custom checker's Prepare
+			InteractorID:   -execRequest.SubID,
+			InteractorLang: problem.HelperCodeLang,
+		},
+		Resp:  &eval.InteractiveResponse{},
+		Debug: h.debug,
+		DM:    store,
+	}
+
+	if err := runner.RunMultiboxTask(ctx, task); err != nil {
+		return nil, fmt.Errorf("Error executing interactive test: %w", err)
+	}
+
+	resp := task.Resp
+	testScore := resp.Score
+
+	// Make sure TLEs are fully handled
+	if resp.Time > eval.ProblemLimits(problem, execRequest.Lang).TimeLimit {
+		resp.Verdict, resp.Comments = kilonova.VerdictTimeLimit, "TLE"
+		testScore = 0
+	}
+
+	return &testResult{
+		Verdict:          resp.Verdict,
+		Message:          resp.Comments,
+		Score:            testScore,
+		Time:             resp.Time,
+		Memory:           resp.Memory,
+		InteractorTime:   resp.InteractorTime,
+		InteractorMemory: resp.InteractorMemory,
+	}, nil
+}
+
 func (h *Handler) ScoreTests(ctx context.Context, sub *kilonova.Submission, problem *kilonova.Problem) error {
 	subtests, err := h.stserv.SubTestsBySubID(ctx, sub.ID)
 	if err != nil {
@@ -255,41 +740,26 @@ func (h *Handler) ScoreTests(ctx context.Context, sub *kilonova.Submission, prob
 		return err
 	}
 
-	var score = problem.DefaultPoints
+	if h.debug {
+		log.Printf("Evaluating with the %q scoring policy\n", problem.ScoringPolicy)
+	}
 
-	if subTasks != nil && len(subTasks) > 0 {
-		if h.debug {
-			log.Println("Evaluating by subtasks")
-		}
-		subMap := make(map[int]*kilonova.SubTest)
-		for _, st := range subtests {
-			subMap[st.TestID] = st
-		}
-		for _, stk := range subTasks {
-			percentage := 100
-			for _, id := range stk.Tests {
-				st, ok := subMap[id]
-				if !ok {
-					log.Printf("Warning: couldn't find a subtest for subtask %d\n", stk.VisibleID)
-					continue
-				}
-				if st.Score < percentage {
-					percentage = st.Score
-				}
-			}
-			score += int(math.Round(float64(stk.Score) * float64(percentage) / 100.0))
-		}
-	} else {
-		if h.debug {
-			log.Println("Evaluating by addition")
+	results := make([]kilonova.TestResult, 0, len(subtests))
+	for _, subtest := range subtests {
+		pbTest, err := h.tserv.TestByID(ctx, subtest.TestID)
+		if err != nil {
+			log.Println("Couldn't get test (0xasdf):", err)
+			continue
 		}
-		for _, subtest := range subtests {
-			pbTest, err := h.tserv.TestByID(ctx, subtest.TestID)
-			if err != nil {
-				log.Println("Couldn't get test (0xasdf):", err)
-				continue
-			}
-			score += int(math.Round(float64(pbTest.Score) * float64(subtest.Score) / 100.0))
+		results = append(results, kilonova.TestResult{TestID: subtest.TestID, MaxScore: pbTest.Score, Percentage: subtest.Score})
+	}
+
+	evaluatedSubmissions.Inc(string(submissionVerdict(subtests)))
+
+	score, stkScores := kilonova.ComputeScore(problem.ScoringPolicy, problem.DefaultPoints, results, subTasks)
+	if stkScores != nil {
+		if err := h.sserv.SetSubTaskScores(ctx, sub.ID, stkScores); err != nil {
+			log.Println("Couldn't save subtask scores:", err)
 		}
 	}
 
@@ -303,10 +773,14 @@ func (h *Handler) Start() error {
 	}
 
 	go h.chFeeder(4 * time.Second)
+	go h.leaseRenewer()
+
+	h.setRunner(runner)
 
 	eCh := make(chan error, 1)
 	go func() {
 		defer runner.Close(h.ctx)
+		defer h.setRunner(nil)
 		log.Println("Connected to eval")
 
 		err := h.handle(h.ctx, runner)
@@ -330,28 +804,36 @@ func (h *Handler) getLocalRunner() (eval.Runner, error) {
 }
 
 func (h *Handler) getAppropriateRunner() (eval.Runner, error) {
+	if len(config.Eval.RemoteWorkers) > 0 {
+		log.Println("Connecting to remote workers")
+		client, err := remote.NewClient(h.ctx, config.Eval.RemoteWorkers, config.Eval.Token)
+		if err != nil {
+			return nil, err
+		}
+		return instrumentedRunner{client}, nil
+	}
 	if boxmanager.CheckCanRun() {
 		runner, err := h.getLocalRunner()
 		if err == nil {
-			return runner, nil
+			return instrumentedRunner{runner}, nil
 		}
+		log.Println("Could not spin up local grader:", err)
 	}
-	log.Fatalln("Remote grader has been disabled because it can't run problems with custom checker")
-	return nil, nil
-	/*
-		Disabled until it fully works
-		return nil, nil
-		log.Println("Could not spin up local grader, trying to contact remote")
-		return newGrpcRunner(config.Eval.Address)
-	*/
+	return nil, &kilonova.Error{Code: kilonova.EINTERNAL, Message: "Could not start the local grader and no remote workers are configured"}
 }
 
 func getAppropriateChecker(runner eval.Runner, sub *kilonova.Submission, pb *kilonova.Problem) (eval.Checker, error) {
 	switch pb.Type {
 	case kilonova.ProblemTypeClassic:
-		return &checkers.DiffChecker{}, nil
-	case kilonova.ProblemTypeCustomChecker:
+		return checkers.NewComparator(pb), nil
+	case kilonova.ProblemTypeCustomChecker, kilonova.ProblemTypeInteractive:
+		// For interactive problems, the checker only compiles (and cleans up) the interactor
 		return checkers.NewCustomChecker(runner, pb, sub)
+	case kilonova.ProblemTypeOutputOnly:
+		if pb.HelperCode != "" {
+			return checkers.NewCustomChecker(runner, pb, sub)
+		}
+		return checkers.NewComparator(pb), nil
 	default:
 		log.Println("Unknown problem type", pb.Type)
 		return nil, &kilonova.Error{Code: kilonova.EINTERNAL, Message: "Unknown problem type"}
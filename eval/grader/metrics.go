@@ -0,0 +1,99 @@
+package grader
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/internal/metrics"
+)
+
+var (
+	taskDuration = metrics.NewHistogram("kilonova_task_duration_seconds",
+		"Time spent running grader tasks, including the wait for a sandbox", metrics.DefBuckets, "task")
+	compileDuration = metrics.NewHistogram("kilonova_compile_duration_seconds",
+		"Time spent compiling submissions, including the wait for a sandbox", metrics.DefBuckets, "lang")
+	evaluatedSubmissions = metrics.NewCounter("kilonova_submissions_total",
+		"Number of evaluated submissions, by verdict", "verdict")
+)
+
+// Stats is a snapshot of the state of the grader
+type Stats struct {
+	// Runner is nil if the grader is not running or its runner can't report its usage
+	Runner *eval.RunnerStats `json:"runner"`
+	// Held holds the number of claimed submissions that are not finished yet, by priority
+	Held map[kilonova.Priority]int `json:"held"`
+}
+
+// Stats returns the current state of the grader
+func (h *Handler) Stats() Stats {
+	var stats Stats
+	if r, ok := h.getRunner().(eval.StatsRunner); ok {
+		rs := r.Stats()
+		stats.Runner = &rs
+	}
+	stats.Held, _ = h.heldCounts()
+	return stats
+}
+
+func (h *Handler) registerMetrics() {
+	eval.RegisterRunnerMetrics(func() eval.StatsRunner {
+		r, _ := h.getRunner().(eval.StatsRunner)
+		return r
+	})
+	metrics.NewGaugeFunc("kilonova_grader_held_submissions", "Number of submissions claimed by the grader that are not finished yet, by priority", func() []metrics.Sample {
+		held, _ := h.heldCounts()
+		return eval.PrioritySamples(held)
+	}, "priority")
+	metrics.NewGaugeFunc("kilonova_submissions_waiting", "Number of submissions waiting to be evaluated", func() []metrics.Sample {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		cnt, err := h.sserv.CountSubmissions(ctx, kilonova.SubmissionFilter{Status: kilonova.StatusWaiting})
+		if err != nil {
+			log.Println("Couldn't count waiting submissions:", err)
+			return nil
+		}
+		return []metrics.Sample{{Value: float64(cnt)}}
+	})
+}
+
+// submissionVerdict is the verdict of the first test that was not accepted, or AC if all of them were
+func submissionVerdict(subtests []*kilonova.SubTest) kilonova.VerdictCode {
+	for _, st := range subtests {
+		if st.VerdictCode != kilonova.VerdictAccepted && st.VerdictCode != kilonova.VerdictSkipped {
+			return st.VerdictCode
+		}
+	}
+	return kilonova.VerdictAccepted
+}
+
+var _ eval.StatsRunner = instrumentedRunner{}
+
+// instrumentedRunner measures the duration of the tasks run by the grader
+type instrumentedRunner struct {
+	eval.Runner
+}
+
+func (r instrumentedRunner) RunTask(ctx context.Context, task eval.Task) error {
+	defer observeDuration(taskDuration, time.Now(), eval.TaskName(task))
+	return r.Runner.RunTask(ctx, task)
+}
+
+func (r instrumentedRunner) RunMultiboxTask(ctx context.Context, task eval.MultiboxTask) error {
+	defer observeDuration(taskDuration, time.Now(), eval.TaskName(task))
+	return r.Runner.RunMultiboxTask(ctx, task)
+}
+
+// Stats returns the usage of the wrapped runner, it is empty if the runner can't report it
+func (r instrumentedRunner) Stats() eval.RunnerStats {
+	if sr, ok := r.Runner.(eval.StatsRunner); ok {
+		return sr.Stats()
+	}
+	return eval.RunnerStats{}
+}
+
+func observeDuration(h *metrics.Histogram, start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
@@ -0,0 +1,269 @@
+package grader
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/eval/tasks"
+)
+
+const (
+	// solutionCheckDelay is how long an automatic check waits after the last change of the problem,
+	// so a series of edits triggers a single check
+	solutionCheckDelay = 30 * time.Second
+	// solutionCheckTimeout is the maximum duration of an automatic check
+	solutionCheckTimeout = 30 * time.Minute
+)
+
+// CheckSolutions judges all solutions of the problem like submissions and compares their outcomes with the expected ones.
+// All tests are run, even if the problem is short-circuited. The results are saved with the solutions, which are returned
+func (h *Handler) CheckSolutions(ctx context.Context, pb *kilonova.Problem) ([]*kilonova.ProblemSolution, error) {
+	return h.checkSolutions(eval.WithPriority(ctx, kilonova.PriorityLive), pb)
+}
+
+// ScheduleSolutionCheck marks the solutions of the problem as pending and checks them in the background, after solutionCheckDelay.
+// Calling it again before the check starts delays the check
+func (h *Handler) ScheduleSolutionCheck(pbID int) {
+	sols, err := h.solserv.ProblemSolutions(h.ctx, pbID)
+	if err != nil {
+		log.Println("Couldn't get problem solutions:", err)
+		return
+	}
+	if len(sols) == 0 {
+		return
+	}
+	pending := kilonova.SolutionPending
+	if err := h.solserv.UpdateProblemSolutions(h.ctx, pbID, kilonova.ProblemSolutionUpdate{CheckStatus: &pending}); err != nil {
+		log.Println("Couldn't mark problem solutions as pending:", err)
+	}
+
+	h.solMu.Lock()
+	defer h.solMu.Unlock()
+	if t, ok := h.solTimers[pbID]; ok {
+		t.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(solutionCheckDelay, func() {
+		h.solMu.Lock()
+		if h.solTimers[pbID] == timer {
+			delete(h.solTimers, pbID)
+		}
+		h.solMu.Unlock()
+
+		ctx, cancel := context.WithTimeout(h.ctx, solutionCheckTimeout)
+		defer cancel()
+		pb, err := h.pserv.ProblemByID(ctx, pbID)
+		if err != nil {
+			log.Printf("Couldn't get problem %d for checking its solutions: %v\n", pbID, err)
+			h.failSolutions(pbID, nil, err)
+			return
+		}
+		if _, err := h.checkSolutions(eval.WithPriority(ctx, kilonova.PriorityBackground), pb); err != nil {
+			log.Printf("Couldn't check the solutions of problem %d: %v\n", pbID, err)
+		}
+	})
+	h.solTimers[pbID] = timer
+}
+
+// solutionLock is held while the solutions of a problem are checked.
+// refs counts the checks that hold or wait for it, so it can be removed after the last one
+type solutionLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lockSolutions waits for the other checks of the problem to finish
+func (h *Handler) lockSolutions(pbID int) {
+	h.solMu.Lock()
+	lock, ok := h.solLocks[pbID]
+	if !ok {
+		lock = &solutionLock{}
+		h.solLocks[pbID] = lock
+	}
+	lock.refs++
+	h.solMu.Unlock()
+
+	lock.Lock()
+}
+
+func (h *Handler) unlockSolutions(pbID int) {
+	h.solMu.Lock()
+	defer h.solMu.Unlock()
+	lock := h.solLocks[pbID]
+	lock.Unlock()
+	if lock.refs--; lock.refs == 0 {
+		delete(h.solLocks, pbID)
+	}
+}
+
+func (h *Handler) checkSolutions(ctx context.Context, pb *kilonova.Problem) ([]*kilonova.ProblemSolution, error) {
+	h.lockSolutions(pb.ID)
+	defer h.unlockSolutions(pb.ID)
+
+	sols, err := h.solserv.ProblemSolutions(ctx, pb.ID)
+	if err != nil {
+		h.failSolutions(pb.ID, nil, err)
+		return nil, err
+	}
+	if len(sols) == 0 {
+		return sols, nil
+	}
+	if checked, err := h.checkAllSolutions(ctx, pb, sols); err != nil {
+		h.failSolutions(pb.ID, sols[checked:], err)
+		return nil, err
+	}
+	return sols, nil
+}
+
+// checkAllSolutions checks and saves the solutions in order. If it fails, it also returns how many were saved
+func (h *Handler) checkAllSolutions(ctx context.Context, pb *kilonova.Problem, sols []*kilonova.ProblemSolution) (int, error) {
+	if pb.Type == kilonova.ProblemTypeOutputOnly {
+		return 0, &kilonova.Error{Code: kilonova.EINVALID, Message: "Output-only problems can't have solutions"}
+	}
+	runner := h.getRunner()
+	if runner == nil {
+		return 0, &kilonova.Error{Code: kilonova.EINTERNAL, Message: "The grader is not running"}
+	}
+
+	pbTests, err := h.tserv.Tests(ctx, pb.ID)
+	if err != nil {
+		return 0, err
+	}
+	subTasks, err := h.stkserv.SubTasks(ctx, pb.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	for i, sol := range sols {
+		status, msg := h.checkSolution(ctx, runner, pb, pbTests, subTasks, sol)
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		now := time.Now()
+		sol.CheckStatus, sol.CheckMessage, sol.CheckedAt = status, msg, &now
+		if err := h.solserv.UpdateProblemSolution(ctx, sol.ID, kilonova.ProblemSolutionUpdate{CheckStatus: &status, CheckMessage: &msg, CheckedAt: &now}); err != nil {
+			return i, err
+		}
+	}
+	return len(sols), nil
+}
+
+// failSolutions saves the error as the outcome of the solutions, so they don't stay pending. If sols is nil, all solutions of the problem are updated
+func (h *Handler) failSolutions(pbID int, sols []*kilonova.ProblemSolution, err error) {
+	status := kilonova.SolutionError
+	msg := err.Error()
+	if errors.Is(err, context.DeadlineExceeded) {
+		msg = "The check took too long"
+	}
+	now := time.Now()
+	upd := kilonova.ProblemSolutionUpdate{CheckStatus: &status, CheckMessage: &msg, CheckedAt: &now}
+
+	// The context of the check may have expired already
+	ctx := context.Background()
+	if sols == nil {
+		if err := h.solserv.UpdateProblemSolutions(ctx, pbID, upd); err != nil {
+			log.Printf("Couldn't save the failed check of problem %d: %v\n", pbID, err)
+		}
+		return
+	}
+	for _, sol := range sols {
+		sol.CheckStatus, sol.CheckMessage, sol.CheckedAt = status, msg, &now
+		if err := h.solserv.UpdateProblemSolution(ctx, sol.ID, upd); err != nil {
+			log.Printf("Couldn't save the failed check of solution %d: %v\n", sol.ID, err)
+		}
+	}
+}
+
+// checkSolution returns the status of the solution and a description of its outcome
+func (h *Handler) checkSolution(ctx context.Context, runner eval.Runner, pb *kilonova.Problem, pbTests []*kilonova.Test, subTasks []*kilonova.SubTask, sol *kilonova.ProblemSolution) (kilonova.SolutionStatus, string) {
+	expected, err := kilonova.ParseExpectedOutcome(sol.Expected)
+	if err != nil {
+		return kilonova.SolutionError, err.Error()
+	}
+	outcome, err := h.judgeSolution(ctx, runner, pb, pbTests, subTasks, sol)
+	if err != nil {
+		log.Printf("Couldn't judge solution %d: %v\n", sol.ID, err)
+		return kilonova.SolutionError, err.Error()
+	}
+	if mismatches := expected.Mismatches(outcome); len(mismatches) > 0 {
+		return kilonova.SolutionMismatch, fmt.Sprintf("Got %s: %s", outcome, strings.Join(mismatches, "; "))
+	}
+	return kilonova.SolutionOK, outcome.String()
+}
+
+// judgeSolution compiles the solution and runs it on all tests, like a submission. Nothing is saved in the database
+func (h *Handler) judgeSolution(ctx context.Context, runner eval.Runner, pb *kilonova.Problem, pbTests []*kilonova.Test, subTasks []*kilonova.SubTask, sol *kilonova.ProblemSolution) (*kilonova.SolutionOutcome, error) {
+	id := h.newCustomID()
+	req, err := h.compileRequest(ctx, pb, id, sol.Code, sol.Language)
+	if err != nil {
+		return nil, err
+	}
+	compile := &tasks.CompileTask{Req: req, Debug: h.debug}
+	if err := runner.RunTask(ctx, compile); err != nil {
+		return nil, err
+	}
+	defer eval.CleanCompilation(id)
+	if !compile.Resp.Success {
+		return &kilonova.SolutionOutcome{CompileError: true}, nil
+	}
+
+	// The checker only needs the compilation ID of the program
+	checker, err := getAppropriateChecker(runner, &kilonova.Submission{ID: id, ProblemID: pb.ID, Language: sol.Language}, pb)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := checker.Prepare(ctx); err != nil {
+		return nil, fmt.Errorf("Checker compilation error: %w", err)
+	}
+	defer checker.Cleanup(ctx)
+
+	store, err := newTempStore(h.dm)
+	if err != nil {
+		return nil, err
+	}
+	defer store.cleanup()
+
+	results := make([]*testResult, len(pbTests))
+	errs := make([]error, len(pbTests))
+	var wg sync.WaitGroup
+	for i, test := range pbTests {
+		i, test := i, test
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = h.runTest(ctx, runner, checker, store, pb, test, id, test.ID, sol.Language)
+		}()
+	}
+	wg.Wait()
+
+	outcome := &kilonova.SolutionOutcome{Verdicts: make(map[int]kilonova.VerdictCode), SubTasks: make(map[int][]int)}
+	scores := make([]kilonova.TestResult, 0, len(pbTests))
+	visibleIDs := make(map[int]int)
+	for i, test := range pbTests {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		outcome.Verdicts[test.VisibleID] = results[i].Verdict
+		scores = append(scores, kilonova.TestResult{TestID: test.ID, MaxScore: test.Score, Percentage: results[i].Score})
+		visibleIDs[test.ID] = test.VisibleID
+	}
+	for _, stk := range subTasks {
+		ids := make([]int, 0, len(stk.Tests))
+		for _, testID := range stk.Tests {
+			if vid, ok := visibleIDs[testID]; ok {
+				ids = append(ids, vid)
+			}
+		}
+		outcome.SubTasks[stk.VisibleID] = ids
+	}
+	outcome.Score, _ = kilonova.ComputeScore(pb.ScoringPolicy, pb.DefaultPoints, scores, subTasks)
+	return outcome, nil
+}
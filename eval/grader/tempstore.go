@@ -0,0 +1,112 @@
+package grader
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/KiloProjects/kilonova"
+)
+
+// tempStore keeps the subtest outputs in a temporary directory, by ID. The tests are read from dm or, if it is nil,
+// they are kept in the directory too, in which case the input, the output and the subtest output with the same ID are the same file
+type tempStore struct {
+	dm  kilonova.GraderStore
+	dir string
+}
+
+var _ kilonova.GraderStore = &tempStore{}
+
+func newTempStore(dm kilonova.GraderStore) (*tempStore, error) {
+	dir, err := os.MkdirTemp("", "kn-grader-")
+	if err != nil {
+		return nil, err
+	}
+	return &tempStore{dm, dir}, nil
+}
+
+func (s *tempStore) path(id int) string {
+	return filepath.Join(s.dir, strconv.Itoa(id))
+}
+
+func (s *tempStore) save(id int, r io.Reader) error {
+	f, err := os.Create(s.path(id))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *tempStore) TestInput(id int) (io.ReadCloser, error) {
+	if s.dm != nil {
+		return s.dm.TestInput(id)
+	}
+	return os.Open(s.path(id))
+}
+
+func (s *tempStore) TestOutput(id int) (io.ReadCloser, error) {
+	if s.dm != nil {
+		return s.dm.TestOutput(id)
+	}
+	return os.Open(s.path(id))
+}
+
+func (s *tempStore) SaveTestInput(id int, r io.Reader) error {
+	if s.dm != nil {
+		return s.dm.SaveTestInput(id, r)
+	}
+	return s.save(id, r)
+}
+
+func (s *tempStore) SaveTestOutput(id int, r io.Reader) error {
+	if s.dm != nil {
+		return s.dm.SaveTestOutput(id, r)
+	}
+	return s.save(id, r)
+}
+
+func (s *tempStore) SubtestWriter(id int) (io.WriteCloser, error) {
+	return os.Create(s.path(id))
+}
+
+func (s *tempStore) SubtestReader(id int) (io.ReadCloser, error) {
+	return os.Open(s.path(id))
+}
+
+func (s *tempStore) RemoveSubtestData(id int) error {
+	return os.Remove(s.path(id))
+}
+
+// keep replaces the output of the subtest in dm with the one from the store
+func (s *tempStore) keep(subtest int) error {
+	f, err := os.Open(s.path(subtest))
+	if errors.Is(err, fs.ErrNotExist) {
+		// The run had no output, the one of the previous run must not be checked
+		return s.dm.RemoveSubtestData(subtest)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := s.dm.SubtestWriter(subtest)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, f); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+func (s *tempStore) cleanup() {
+	os.RemoveAll(s.dir)
+}
@@ -0,0 +1,189 @@
+package grader
+
+import (
+	"context"
+	"io"
+	"log"
+	"math"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/eval/tasks"
+	"github.com/KiloProjects/kilonova/internal/config"
+)
+
+// calibrationTimeLimit is the time limit of the reference solutions, it should be way over any sane time limit
+const calibrationTimeLimit = 10
+
+// nearTimeLimit reports if the run was too close to the time limit to trust a single measurement
+func nearTimeLimit(resp *eval.ExecResponse, timeLimit float64) bool {
+	if resp.Verdict != kilonova.VerdictNone && resp.Verdict != kilonova.VerdictTimeLimit {
+		return false
+	}
+	return resp.Time >= timeLimit*(1-config.Eval.RerunThreshold/100)
+}
+
+// rerunNearLimit runs the test again, at most MaxReruns times, while the fastest run is close to the time limit.
+// The response of the fastest run is returned and its output is the one kept in dm
+func (h *Handler) rerunNearLimit(ctx context.Context, runner eval.Runner, dm kilonova.GraderStore, task *tasks.ExecuteTask, timeLimit float64) *eval.ExecResponse {
+	best := task.Resp
+	for i := 0; i < config.Eval.MaxReruns && nearTimeLimit(best, timeLimit); i++ {
+		if h.debug {
+			log.Printf("Running subtest %d again, it took %.3fs\n", task.Req.SubtestID, best.Time)
+		}
+
+		// The output is moved to dm only if the rerun is kept
+		store, err := newTempStore(dm)
+		if err != nil {
+			log.Println("Couldn't run subtest again:", err)
+			break
+		}
+		rerun := &tasks.ExecuteTask{Req: task.Req, Resp: &eval.ExecResponse{}, DM: store, Debug: h.debug}
+		err = runner.RunTask(ctx, rerun)
+		if err == nil && rerun.Resp.Time < best.Time {
+			if err = store.keep(task.Req.SubtestID); err == nil {
+				best = rerun.Resp
+			}
+		}
+		store.cleanup()
+		if err != nil {
+			log.Println("Couldn't run subtest again:", err)
+			break
+		}
+	}
+	return best
+}
+
+// discardOutputStore throws away the output of the program, it is used when only the running time matters
+type discardOutputStore struct {
+	kilonova.GraderStore
+}
+
+func (discardOutputStore) SubtestWriter(_ int) (io.WriteCloser, error) {
+	return nopWriteCloser{io.Discard}, nil
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// Solution is a reference solution of a problem
+type Solution struct {
+	Code string
+	Lang string
+}
+
+// CalibrationRun holds the times measured for a reference solution
+type CalibrationRun struct {
+	Lang           string `json:"lang"`
+	CompileError   bool   `json:"compile_error"`
+	CompileMessage string `json:"compile_message"`
+
+	// MaxTime is the time of the slowest test, SlowestTest is its visible ID
+	MaxTime     float64 `json:"max_time"`
+	SlowestTest int     `json:"slowest_test"`
+	// Failed holds the visible IDs of the tests on which the solution did not run successfully
+	Failed []int `json:"failed"`
+}
+
+// Calibration is the result of running the reference solutions on all tests
+type Calibration struct {
+	Runs []*CalibrationRun `json:"runs"`
+
+	SlowestTime        float64 `json:"slowest_time"`
+	SuggestedTimeLimit float64 `json:"suggested_time_limit"`
+}
+
+// Calibrate runs the reference solutions on all tests of the problem and suggests a time limit,
+// as a multiple of the time of the slowest reference run. Every test is run 1+MaxReruns times and the lowest time is kept.
+// The correctness of the outputs is not checked
+func (h *Handler) Calibrate(ctx context.Context, pb *kilonova.Problem, solutions []Solution, multiplier float64) (*Calibration, error) {
+	runner := h.getRunner()
+	if runner == nil {
+		return nil, &kilonova.Error{Code: kilonova.EINTERNAL, Message: "The grader is not running"}
+	}
+	ctx = eval.WithPriority(ctx, kilonova.PriorityLive)
+
+	pbTests, err := h.tserv.Tests(ctx, pb.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	calib := &Calibration{}
+	for _, sol := range solutions {
+		run, err := h.calibrateSolution(ctx, runner, pb, pbTests, sol)
+		if err != nil {
+			return nil, err
+		}
+		calib.Runs = append(calib.Runs, run)
+		if run.MaxTime > calib.SlowestTime {
+			calib.SlowestTime = run.MaxTime
+		}
+	}
+
+	// Round up to a tenth of a second
+	calib.SuggestedTimeLimit = math.Ceil(calib.SlowestTime*multiplier*10) / 10
+	if calib.SuggestedTimeLimit < 0.1 {
+		calib.SuggestedTimeLimit = 0.1
+	}
+	return calib, nil
+}
+
+func (h *Handler) calibrateSolution(ctx context.Context, runner eval.Runner, pb *kilonova.Problem, pbTests []*kilonova.Test, sol Solution) (*CalibrationRun, error) {
+	id := h.newCustomID()
+	creq, err := h.compileRequest(ctx, pb, id, sol.Code, sol.Lang)
+	if err != nil {
+		return nil, err
+	}
+	compile := &tasks.CompileTask{Req: creq, Debug: h.debug}
+	if err := runner.RunTask(ctx, compile); err != nil {
+		return nil, err
+	}
+	defer eval.CleanCompilation(id)
+
+	run := &CalibrationRun{Lang: sol.Lang, CompileMessage: compile.Resp.Output, Failed: []int{}}
+	if !compile.Resp.Success {
+		run.CompileError = true
+		return run, nil
+	}
+
+	req := &eval.ExecRequest{
+		SubID:       id,
+		Filename:    pb.TestName,
+		StackLimit:  pb.StackLimit,
+		MemoryLimit: pb.MemoryLimit,
+		TimeLimit:   calibrationTimeLimit,
+		OutputLimit: pb.OutputSizeLimit,
+		Lang:        sol.Lang,
+	}
+	// The times are measured without the language's time adjustment, only the memory one is kept
+	req.Adjustment = eval.LanguageAdjustment(pb, sol.Lang)
+	req.Adjustment.TimeMultiplier, req.Adjustment.TimeOffset = 0, 0
+	if pb.ConsoleInput {
+		req.Filename = "stdin"
+	}
+
+	for _, test := range pbTests {
+		req.TestID = test.ID
+		best, failed := math.Inf(1), false
+		for i := 0; i <= config.Eval.MaxReruns && !failed; i++ {
+			task := &tasks.ExecuteTask{Req: req, Resp: &eval.ExecResponse{}, DM: discardOutputStore{h.dm}, Debug: h.debug}
+			if err := runner.RunTask(ctx, task); err != nil {
+				return nil, err
+			}
+			failed = task.Resp.Verdict != kilonova.VerdictNone
+			best = math.Min(best, task.Resp.Time)
+		}
+		if failed {
+			run.Failed = append(run.Failed, test.VisibleID)
+			continue
+		}
+		if best > run.MaxTime {
+			run.MaxTime = best
+			run.SlowestTest = test.VisibleID
+		}
+	}
+	return run, nil
+}
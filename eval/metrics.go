@@ -0,0 +1,69 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/internal/metrics"
+)
+
+// RegisterRunnerMetrics exposes the sandbox usage of the runner returned by get.
+// get may return nil while there is no runner, then no values are reported
+func RegisterRunnerMetrics(get func() StatsRunner) {
+	stats := func() *RunnerStats {
+		r := get()
+		if r == nil {
+			return nil
+		}
+		s := r.Stats()
+		return &s
+	}
+
+	metrics.NewGaugeFunc("kilonova_sandboxes", "Number of sandboxes that are busy or idle", func() []metrics.Sample {
+		s := stats()
+		if s == nil {
+			return nil
+		}
+		busy := 0
+		for _, n := range s.Busy {
+			busy += n
+		}
+		return []metrics.Sample{
+			{LabelValues: []string{"busy"}, Value: float64(busy)},
+			{LabelValues: []string{"idle"}, Value: float64(s.Slots - busy)},
+		}
+	}, "state")
+
+	byPriority := func(pick func(*RunnerStats) map[kilonova.Priority]int) func() []metrics.Sample {
+		return func() []metrics.Sample {
+			s := stats()
+			if s == nil {
+				return nil
+			}
+			return PrioritySamples(pick(s))
+		}
+	}
+	metrics.NewGaugeFunc("kilonova_sandboxes_busy", "Number of sandboxes used by the running tasks, by priority",
+		byPriority(func(s *RunnerStats) map[kilonova.Priority]int { return s.Busy }), "priority")
+	metrics.NewGaugeFunc("kilonova_sandboxes_waiting", "Number of sandboxes needed by the tasks waiting for one, by priority",
+		byPriority(func(s *RunnerStats) map[kilonova.Priority]int { return s.Waiting }), "priority")
+}
+
+// PrioritySamples returns a sample for every priority, labeled with the name of the priority
+func PrioritySamples(m map[kilonova.Priority]int) []metrics.Sample {
+	samples := make([]metrics.Sample, 0, len(kilonova.Priorities))
+	for _, p := range kilonova.Priorities {
+		samples = append(samples, metrics.Sample{LabelValues: []string{p.String()}, Value: float64(m[p])})
+	}
+	return samples
+}
+
+// TaskName returns the name used for the task in the metrics, ex: "compile" for a *tasks.CompileTask
+func TaskName(task interface{}) string {
+	name := fmt.Sprintf("%T", task)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.ToLower(strings.TrimSuffix(name, "Task"))
+}
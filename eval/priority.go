@@ -0,0 +1,52 @@
+package eval
+
+import (
+	"context"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/internal/config"
+)
+
+type priorityKey struct{}
+
+// defaultShares is used for the priorities missing from the config
+var defaultShares = map[kilonova.Priority]int{
+	kilonova.PriorityLive:       40,
+	kilonova.PriorityContest:    30,
+	kilonova.PriorityRejudge:    20,
+	kilonova.PriorityBackground: 10,
+}
+
+// WithPriority returns a context that makes the runner schedule the tasks with the specified priority
+func WithPriority(ctx context.Context, p kilonova.Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFromContext returns the priority set with WithPriority. Tasks without a priority are considered live
+func PriorityFromContext(ctx context.Context) kilonova.Priority {
+	p, ok := ctx.Value(priorityKey{}).(kilonova.Priority)
+	if !ok {
+		return kilonova.PriorityLive
+	}
+	return p
+}
+
+// ReservedSlots returns, for every priority, how many of the total slots are reserved for it when all slots are contended.
+// Every priority with a non-zero share gets at least one slot, so it is never fully starved
+func ReservedSlots(total int) map[kilonova.Priority]int {
+	reserved := make(map[kilonova.Priority]int)
+	for _, p := range kilonova.Priorities {
+		share, ok := config.Eval.PriorityShares[p.String()]
+		if !ok {
+			share = defaultShares[p]
+		}
+		if share <= 0 {
+			continue
+		}
+		reserved[p] = total * share / 100
+		if reserved[p] < 1 {
+			reserved[p] = 1
+		}
+	}
+	return reserved
+}
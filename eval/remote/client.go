@@ -0,0 +1,450 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/eval/checkers"
+	"github.com/KiloProjects/kilonova/eval/tasks"
+	"github.com/KiloProjects/kilonova/internal/config"
+)
+
+var _ eval.StatsRunner = &Client{}
+
+const (
+	dialTimeout    = 10 * time.Second
+	reconnectDelay = 5 * time.Second
+)
+
+// Client is a runner that sends the tasks to remote workers.
+// Every worker gets as many connections as the number of tasks it can run concurrently,
+// and all connections are put in a shared pool, so a task is run by the first free worker
+type Client struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	token  string
+
+	// slots holds the idle connections
+	slots    chan *conn
+	numSlots int
+
+	// busy and waiting count the tasks that hold a connection and the ones that wait for one, by priority
+	statsMu sync.Mutex
+	busy    map[kilonova.Priority]int
+	waiting map[kilonova.Priority]int
+	// versions holds the isolate version reported by every worker
+	versions map[string]string
+}
+
+// NewClient connects to the specified workers. It fails only if no worker could be contacted
+func NewClient(ctx context.Context, addrs []string, token string) (*Client, error) {
+	if token == "" {
+		return nil, errors.New("remote: an eval token must be set to use remote workers")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	c := &Client{ctx: ctx, cancel: cancel, token: token, busy: make(map[kilonova.Priority]int), waiting: make(map[kilonova.Priority]int), versions: make(map[string]string)}
+
+	var conns []*conn
+	for _, addr := range addrs {
+		cs, err := c.connectWorker(addr)
+		if err != nil {
+			log.Printf("Could not connect to worker %q: %v\n", addr, err)
+			continue
+		}
+		log.Printf("Connected to worker %q (%d slots)\n", addr, len(cs))
+		conns = append(conns, cs...)
+	}
+
+	if len(conns) == 0 {
+		cancel()
+		return nil, errors.New("remote: could not connect to any worker")
+	}
+
+	c.numSlots = len(conns)
+	c.slots = make(chan *conn, len(conns))
+	for _, conn := range conns {
+		c.slots <- conn
+	}
+
+	return c, nil
+}
+
+// connectWorker opens all the connections to a worker
+func (c *Client) connectWorker(addr string) ([]*conn, error) {
+	first, n, err := c.dial(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	conns := []*conn{first}
+	for i := 1; i < n; i++ {
+		conn, _, err := c.dial(addr)
+		if err != nil {
+			log.Printf("Could only open %d connections to worker %q: %v\n", len(conns), addr, err)
+			break
+		}
+		conns = append(conns, conn)
+	}
+	return conns, nil
+}
+
+// dial opens a connection and does the handshake. It also returns the number of tasks the worker can run concurrently
+func (c *Client) dial(addr string) (*conn, int, error) {
+	var d net.Dialer
+	dctx, cancel := context.WithTimeout(c.ctx, dialTimeout)
+	defer cancel()
+
+	nc, err := d.DialContext(dctx, "tcp", addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	conn := newConn(nc)
+	conn.addr = addr
+
+	nc.SetDeadline(time.Now().Add(dialTimeout))
+	if err := conn.writeJSON(hello{Version: protocolVersion, Token: c.token}); err != nil {
+		conn.Close()
+		return nil, 0, err
+	}
+	var resp helloResponse
+	if err := conn.readJSON(&resp); err != nil {
+		conn.Close()
+		return nil, 0, err
+	}
+	if resp.Error != "" {
+		conn.Close()
+		return nil, 0, &remoteError{resp.Error}
+	}
+	nc.SetDeadline(time.Time{})
+
+	if resp.NumConcurrent < 1 {
+		resp.NumConcurrent = 1
+	}
+	c.statsMu.Lock()
+	c.versions[addr] = resp.IsolateVersion
+	c.statsMu.Unlock()
+	return conn, resp.NumConcurrent, nil
+}
+
+// reconnect replaces a broken connection with a new one to the same worker, retrying until the client is closed
+func (c *Client) reconnect(addr string) {
+	for {
+		select {
+		case <-c.ctx.Done():
+			// mark the slot as dead for Close
+			c.slots <- nil
+			return
+		case <-time.After(reconnectDelay):
+		}
+
+		conn, _, err := c.dial(addr)
+		if err != nil {
+			log.Printf("Could not reconnect to worker %q: %v\n", addr, err)
+			continue
+		}
+		c.slots <- conn
+		return
+	}
+}
+
+// withConn runs f using an idle connection. If the connection breaks, it is replaced in the background
+func (c *Client) withConn(ctx context.Context, f func(*conn) error) error {
+	prio := eval.PriorityFromContext(ctx)
+	c.count(c.waiting, prio, 1)
+	var conn *conn
+	select {
+	case <-ctx.Done():
+		c.count(c.waiting, prio, -1)
+		return ctx.Err()
+	case <-c.ctx.Done():
+		c.count(c.waiting, prio, -1)
+		return c.ctx.Err()
+	case conn = <-c.slots:
+	}
+	c.count(c.waiting, prio, -1)
+	c.count(c.busy, prio, 1)
+	defer c.count(c.busy, prio, -1)
+
+	// The only way to stop a blocked read or write is closing the connection
+	stop := make(chan struct{})
+	canceled := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+			canceled <- true
+		case <-stop:
+			canceled <- false
+		}
+	}()
+
+	err := f(conn)
+	close(stop)
+
+	var rerr *remoteError
+	if !<-canceled && (err == nil || errors.As(err, &rerr)) {
+		c.slots <- conn
+		return err
+	}
+
+	if err == nil {
+		err = ctx.Err()
+	}
+	log.Printf("Connection to worker %q closed: %v\n", conn.addr, err)
+	conn.Close()
+	go c.reconnect(conn.addr)
+	return err
+}
+
+func (c *Client) count(m map[kilonova.Priority]int, prio kilonova.Priority, delta int) {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	m[prio] += delta
+}
+
+// Stats returns the usage of the connections to the workers, every connection is a slot
+func (c *Client) Stats() eval.RunnerStats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	stats := eval.RunnerStats{
+		Slots:           c.numSlots,
+		Busy:            make(map[kilonova.Priority]int),
+		Waiting:         make(map[kilonova.Priority]int),
+		IsolateVersions: make(map[string]string),
+	}
+	for _, p := range kilonova.Priorities {
+		stats.Busy[p] = c.busy[p]
+		stats.Waiting[p] = c.waiting[p]
+	}
+	for addr, version := range c.versions {
+		stats.IsolateVersions[addr] = version
+	}
+	return stats
+}
+
+// do sends the request and the streams, and reads the response.
+// The streams produced by the worker are written to the writers returned by open
+func (c *Client) do(ctx context.Context, req *request, streams []func() (io.ReadCloser, error), open func() (io.WriteCloser, error)) (*response, error) {
+	var resp response
+	req.Priority = eval.PriorityFromContext(ctx)
+	err := c.withConn(ctx, func(conn *conn) error {
+		sendErr := make(chan error, 1)
+		go func() {
+			if err := conn.writeJSON(req); err != nil {
+				sendErr <- err
+				return
+			}
+			for _, stream := range streams {
+				if err := conn.sendStream(stream); err != nil {
+					sendErr <- err
+					return
+				}
+			}
+			sendErr <- nil
+		}()
+
+		err := conn.readResponse(open, &resp)
+		if err != nil {
+			// make sure the sender stops
+			conn.Close()
+			<-sendErr
+			return err
+		}
+		if err := <-sendErr; err != nil {
+			return err
+		}
+
+		if resp.Error != "" {
+			return &remoteError{resp.Error}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (c *Client) RunTask(ctx context.Context, task eval.Task) error {
+	switch t := task.(type) {
+	case *tasks.CompileTask:
+		return c.compile(ctx, t)
+	case *tasks.ExecuteTask:
+		return c.execute(ctx, t)
+	case *checkers.CustomCheckerTask:
+		return c.check(ctx, t)
+	case *tasks.CustomRunTask:
+		return c.customRun(ctx, t)
+	case *tasks.ProgramTask:
+		return c.program(ctx, t)
+	default:
+		return fmt.Errorf("remote: unsupported task type %T", task)
+	}
+}
+
+func (c *Client) RunMultiboxTask(ctx context.Context, task eval.MultiboxTask) error {
+	switch t := task.(type) {
+	case *tasks.InteractiveTask:
+		return c.interactive(ctx, t)
+	default:
+		return fmt.Errorf("remote: unsupported multibox task type %T", task)
+	}
+}
+
+func (c *Client) compile(ctx context.Context, t *tasks.CompileTask) error {
+	outName := binaryPath(t.Req.ID)
+	open := func() (io.WriteCloser, error) {
+		return os.OpenFile(outName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+	}
+
+	resp, err := c.do(ctx, &request{Type: taskCompile, Compile: t.Req}, nil, open)
+	if err != nil {
+		return err
+	}
+	if resp.Compile == nil {
+		return fmt.Errorf("%w: missing compile response", errProtocol)
+	}
+	t.Resp = *resp.Compile
+	return nil
+}
+
+func (c *Client) execute(ctx context.Context, t *tasks.ExecuteTask) error {
+	streams := []func() (io.ReadCloser, error){
+		binaryStream(t.Req.SubID),
+		func() (io.ReadCloser, error) { return t.DM.TestInput(t.Req.TestID) },
+	}
+	open := func() (io.WriteCloser, error) {
+		return t.DM.SubtestWriter(t.Req.SubtestID)
+	}
+
+	resp, err := c.do(ctx, &request{Type: taskExecute, Execute: t.Req}, streams, open)
+	if err != nil {
+		return err
+	}
+	if resp.Execute == nil {
+		return fmt.Errorf("%w: missing execute response", errProtocol)
+	}
+	*t.Resp = *resp.Execute
+	return nil
+}
+
+func (c *Client) check(ctx context.Context, t *checkers.CustomCheckerTask) error {
+	streams := []func() (io.ReadCloser, error){
+		binaryStream(t.CheckerID),
+		readerStream(t.POut),
+		readerStream(t.CIn),
+		readerStream(t.COut),
+	}
+
+	req := &request{Type: taskChecker, Checker: &checkerRequest{CheckerID: t.CheckerID, Lang: t.Lang, Protocol: t.Protocol}}
+	resp, err := c.do(ctx, req, streams, nil)
+	if err != nil {
+		return err
+	}
+	if resp.Checker == nil {
+		return fmt.Errorf("%w: missing checker response", errProtocol)
+	}
+	t.Verdict = resp.Checker.Verdict
+	t.Score = resp.Checker.Score
+	t.Output = resp.Checker.Output
+	return nil
+}
+
+func (c *Client) interactive(ctx context.Context, t *tasks.InteractiveTask) error {
+	streams := []func() (io.ReadCloser, error){
+		binaryStream(t.Req.SubID),
+		binaryStream(t.Req.InteractorID),
+		func() (io.ReadCloser, error) { return t.DM.TestInput(t.Req.TestID) },
+		func() (io.ReadCloser, error) { return t.DM.TestOutput(t.Req.TestID) },
+	}
+
+	resp, err := c.do(ctx, &request{Type: taskInteractive, Interactive: t.Req}, streams, nil)
+	if err != nil {
+		return err
+	}
+	if resp.Interactive == nil {
+		return fmt.Errorf("%w: missing interactive response", errProtocol)
+	}
+	*t.Resp = *resp.Interactive
+	return nil
+}
+
+func (c *Client) customRun(ctx context.Context, t *tasks.CustomRunTask) error {
+	streams := []func() (io.ReadCloser, error){binaryStream(t.Req.ID)}
+
+	resp, err := c.do(ctx, &request{Type: taskCustomRun, CustomRun: t.Req}, streams, nil)
+	if err != nil {
+		return err
+	}
+	if resp.CustomRun == nil {
+		return fmt.Errorf("%w: missing custom run response", errProtocol)
+	}
+	*t.Resp = *resp.CustomRun
+	return nil
+}
+
+func (c *Client) program(ctx context.Context, t *tasks.ProgramTask) error {
+	streams := []func() (io.ReadCloser, error){binaryStream(t.Req.ID)}
+	if t.Req.InputID != 0 {
+		streams = append(streams, func() (io.ReadCloser, error) { return t.DM.TestInput(t.Req.InputID) })
+	}
+	var open func() (io.WriteCloser, error)
+	if t.Req.OutputID != 0 {
+		open = func() (io.WriteCloser, error) {
+			return t.DM.SubtestWriter(t.Req.OutputID)
+		}
+	}
+
+	resp, err := c.do(ctx, &request{Type: taskProgram, Program: t.Req}, streams, open)
+	if err != nil {
+		return err
+	}
+	if resp.Program == nil {
+		return fmt.Errorf("%w: missing program response", errProtocol)
+	}
+	*t.Resp = *resp.Program
+	return nil
+}
+
+// Close waits for the running tasks to finish and closes all connections
+func (c *Client) Close(ctx context.Context) error {
+	c.cancel()
+	for i := 0; i < c.numSlots; i++ {
+		select {
+		case conn := <-c.slots:
+			if conn != nil {
+				conn.Close()
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func binaryPath(id int) string {
+	return path.Join(config.Eval.CompilePath, fmt.Sprintf("%d.bin", id))
+}
+
+func binaryStream(id int) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return os.Open(binaryPath(id))
+	}
+}
+
+func readerStream(r io.Reader) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return io.NopCloser(r), nil
+	}
+}
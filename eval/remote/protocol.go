@@ -0,0 +1,399 @@
+// Package remote implements an eval.Runner that sends tasks to worker processes over the network.
+//
+// Every connection starts with a handshake (the client sends a hello message with the shared token, the worker replies with its capacity),
+// after which the connection runs tasks one at a time. A message is a sequence of frames. A frame is made of
+// a kind byte, the payload length as a big endian uint32 and the payload.
+//
+// The client sends a JSON request frame followed by the streams needed by the task (binaries, test input/output).
+// A stream is made of data frames terminated by an end frame, or by an abort frame whose payload holds the error.
+// The worker replies with the streams produced by the task (the program output or the compiled binary),
+// followed by a JSON response frame.
+package remote
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+)
+
+const protocolVersion = 1
+
+const (
+	frameJSON  byte = 'J'
+	frameData  byte = 'D'
+	frameEnd   byte = 'E'
+	frameAbort byte = 'A'
+
+	// chunkSize is the maximum size of a data frame that is sent
+	chunkSize = 32 * 1024
+	// maxFrameSize is the biggest frame that is accepted (JSON frames can contain source code)
+	maxFrameSize = 16 * 1024 * 1024
+)
+
+const (
+	taskCompile     = "compile"
+	taskExecute     = "execute"
+	taskChecker     = "checker"
+	taskInteractive = "interactive"
+	taskCustomRun   = "custom_run"
+	taskProgram     = "program"
+)
+
+var errProtocol = errors.New("remote: protocol error")
+
+type hello struct {
+	Version int    `json:"version"`
+	Token   string `json:"token"`
+}
+
+type helloResponse struct {
+	Error         string `json:"error"`
+	NumConcurrent int    `json:"num_concurrent"`
+	// IsolateVersion is empty for older workers
+	IsolateVersion string `json:"isolate_version,omitempty"`
+}
+
+type checkerRequest struct {
+	CheckerID int                      `json:"checker_id"`
+	Lang      string                   `json:"lang"`
+	Protocol  kilonova.CheckerProtocol `json:"protocol"`
+}
+
+type checkerResponse struct {
+	Verdict kilonova.VerdictCode `json:"verdict"`
+	Score   int                  `json:"score"`
+	Output  string               `json:"output"`
+}
+
+// request is sent by the client for every task. Depending on Type, exactly one of the other fields is set.
+//
+// The streams that follow the request are:
+//   - compile: none
+//   - execute: the submission binary and the test input
+//   - checker: the checker binary, the program output, the test input and the test output
+//   - interactive: the submission binary, the interactor binary, the test input and the test output
+//   - custom_run: the program binary, the input is sent in the request
+//   - program: the program binary and the test input, if the request has an input
+type request struct {
+	Type string `json:"type"`
+	// Priority is used by the worker to schedule the task
+	Priority kilonova.Priority `json:"priority"`
+
+	Compile     *eval.CompileRequest     `json:"compile,omitempty"`
+	Execute     *eval.ExecRequest        `json:"execute,omitempty"`
+	Checker     *checkerRequest          `json:"checker,omitempty"`
+	Interactive *eval.InteractiveRequest `json:"interactive,omitempty"`
+	CustomRun   *eval.CustomRunRequest   `json:"custom_run,omitempty"`
+	Program     *eval.ProgramRequest     `json:"program,omitempty"`
+}
+
+// response is sent by the worker after the task finished.
+//
+// The streams that precede the response are:
+//   - compile: the compiled binary, if the compilation was successful
+//   - execute, program: the program output, if it exists
+//   - checker, interactive, custom_run: none
+type response struct {
+	// Error is set if the task could not be executed
+	Error string `json:"error"`
+
+	Compile     *eval.CompileResponse     `json:"compile,omitempty"`
+	Execute     *eval.ExecResponse        `json:"execute,omitempty"`
+	Checker     *checkerResponse          `json:"checker,omitempty"`
+	Interactive *eval.InteractiveResponse `json:"interactive,omitempty"`
+	CustomRun   *eval.CustomRunResponse   `json:"custom_run,omitempty"`
+	Program     *eval.ProgramResponse     `json:"program,omitempty"`
+}
+
+// remoteError is an error returned by the other side. The connection is still usable after it.
+type remoteError struct {
+	msg string
+}
+
+func (e *remoteError) Error() string {
+	return "remote: " + e.msg
+}
+
+// conn wraps a network connection with the framing logic.
+// Frames must be written from a single goroutine and read from a single goroutine.
+type conn struct {
+	// addr is the address of the worker, only set on the client side
+	addr string
+
+	nc net.Conn
+	r  *bufio.Reader
+	w  *bufio.Writer
+
+	// cur is the stream currently being read
+	cur *streamReader
+}
+
+func newConn(nc net.Conn) *conn {
+	return &conn{nc: nc, r: bufio.NewReaderSize(nc, chunkSize), w: bufio.NewWriterSize(nc, chunkSize)}
+}
+
+func (c *conn) Close() error {
+	return c.nc.Close()
+}
+
+func (c *conn) writeFrame(kind byte, data []byte) error {
+	var header [5]byte
+	header[0] = kind
+	binary.BigEndian.PutUint32(header[1:], uint32(len(data)))
+	if _, err := c.w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := c.w.Write(data)
+	return err
+}
+
+func (c *conn) readFrame() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("%w: frame too big (%d bytes)", errProtocol, size)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return 0, nil, err
+	}
+	return header[0], data, nil
+}
+
+func (c *conn) writeJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := c.writeFrame(frameJSON, data); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// readJSON reads the next JSON frame into v
+func (c *conn) readJSON(v interface{}) error {
+	kind, data, err := c.readFrame()
+	if err != nil {
+		return err
+	}
+	if kind != frameJSON {
+		return fmt.Errorf("%w: expected JSON frame, got %q", errProtocol, kind)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// sendStream sends the contents of the reader returned by open as a stream.
+// If the stream can't be opened or read, an abort frame is sent instead, so the connection stays in sync.
+// The returned error is not nil only if the connection is unusable.
+func (c *conn) sendStream(open func() (io.ReadCloser, error)) error {
+	r, err := open()
+	if err != nil {
+		return c.abortStream(err)
+	}
+	defer r.Close()
+
+	buf := make([]byte, chunkSize)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if err := c.writeFrame(frameData, buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return c.abortStream(err)
+		}
+	}
+
+	if err := c.writeFrame(frameEnd, nil); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+func (c *conn) abortStream(err error) error {
+	if err := c.writeFrame(frameAbort, []byte(err.Error())); err != nil {
+		return err
+	}
+	return c.w.Flush()
+}
+
+// nextStream returns a reader for the next incoming stream, after discarding what was left from the previous one
+func (c *conn) nextStream() (*streamReader, error) {
+	if c.cur != nil {
+		if err := c.cur.drain(); err != nil {
+			return nil, err
+		}
+	}
+	c.cur = &streamReader{c: c}
+	return c.cur, nil
+}
+
+// streamReader reads the data frames of a stream
+type streamReader struct {
+	c    *conn
+	buf  []byte
+	done bool
+	// err is returned after the stream is done, it is io.EOF if the stream ended normally
+	err error
+	// connErr is set if reading the stream broke the connection
+	connErr error
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.buf) == 0 {
+		if s.done {
+			return 0, s.err
+		}
+		kind, data, err := s.c.readFrame()
+		if err != nil {
+			s.done, s.err, s.connErr = true, err, err
+			return 0, err
+		}
+		switch kind {
+		case frameData:
+			s.buf = data
+		case frameEnd:
+			s.done, s.err = true, io.EOF
+		case frameAbort:
+			s.done, s.err = true, &remoteError{string(data)}
+		default:
+			s.done, s.connErr = true, fmt.Errorf("%w: unexpected frame %q in stream", errProtocol, kind)
+			s.err = s.connErr
+		}
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+// Close discards the rest of the stream
+func (s *streamReader) Close() error {
+	return s.drain()
+}
+
+// drain reads the stream until its end. It returns an error only if the connection broke
+func (s *streamReader) drain() error {
+	if _, err := io.Copy(io.Discard, s); err != nil && s.connErr == nil {
+		// the stream was aborted by the other side, the connection is fine
+		return nil
+	}
+	return s.connErr
+}
+
+// recvStream copies the next stream to w
+func (c *conn) recvStream(w io.Writer) error {
+	s, err := c.nextStream()
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, s)
+	if err := s.drain(); err != nil {
+		return err
+	}
+	return err
+}
+
+// streamWriter sends everything written to it as data frames, Close ends the stream
+type streamWriter struct {
+	c      *conn
+	closed bool
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if n > chunkSize {
+			n = chunkSize
+		}
+		if err := s.c.writeFrame(frameData, p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (s *streamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	if err := s.c.writeFrame(frameEnd, nil); err != nil {
+		return err
+	}
+	return s.c.w.Flush()
+}
+
+// readResponse reads the response for a task into resp.
+// The streams sent before the response are written to the writers returned by open.
+func (c *conn) readResponse(open func() (io.WriteCloser, error), resp *response) error {
+	var w io.WriteCloser
+	var inStream bool
+	for {
+		kind, data, err := c.readFrame()
+		if err != nil {
+			return err
+		}
+
+		if kind == frameJSON {
+			if inStream {
+				return fmt.Errorf("%w: response sent before the end of the stream", errProtocol)
+			}
+			return json.Unmarshal(data, resp)
+		}
+
+		if !inStream {
+			inStream = true
+			if open != nil {
+				w, err = open()
+				if err != nil {
+					log.Println("Could not open writer for remote stream:", err)
+					w = nil
+				}
+			}
+		}
+
+		switch kind {
+		case frameData:
+			if w == nil {
+				continue
+			}
+			if _, err := w.Write(data); err != nil {
+				log.Println("Could not write remote stream:", err)
+				w.Close()
+				w = nil
+			}
+		case frameEnd, frameAbort:
+			if kind == frameAbort {
+				log.Println("Remote stream aborted:", string(data))
+			}
+			if w != nil {
+				if err := w.Close(); err != nil {
+					log.Println("Could not close remote stream:", err)
+				}
+				w = nil
+			}
+			inStream = false
+		default:
+			return fmt.Errorf("%w: unexpected frame %q", errProtocol, kind)
+		}
+	}
+}
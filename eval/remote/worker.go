@@ -0,0 +1,390 @@
+package remote
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/eval/checkers"
+	"github.com/KiloProjects/kilonova/eval/tasks"
+)
+
+// Worker runs the tasks received from clients using a local runner
+type Worker struct {
+	runner        eval.Runner
+	numConcurrent int
+	token         string
+	debug         bool
+
+	// lastID is used to give unique compilation IDs to the received binaries, so they don't collide between clients
+	lastID int64
+}
+
+// NewWorker creates a worker that runs the tasks using the runner. numConcurrent is advertised to clients
+func NewWorker(runner eval.Runner, numConcurrent int, token string, debug bool) (*Worker, error) {
+	if token == "" {
+		return nil, errors.New("remote: an eval token must be set to run a worker")
+	}
+	// Start from a big number so the IDs don't collide with the ones of a local grader using the same compile path
+	return &Worker{runner: runner, numConcurrent: numConcurrent, token: token, debug: debug, lastID: 1 << 30}, nil
+}
+
+// Serve accepts connections until the context is canceled
+func (w *Worker) Serve(ctx context.Context, l net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+
+	for {
+		nc, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go w.handleConn(ctx, nc)
+	}
+}
+
+func (w *Worker) handleConn(ctx context.Context, nc net.Conn) {
+	c := newConn(nc)
+	defer c.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+
+	nc.SetDeadline(time.Now().Add(dialTimeout))
+	var h hello
+	if err := c.readJSON(&h); err != nil {
+		log.Printf("Bad handshake from %s: %v\n", nc.RemoteAddr(), err)
+		return
+	}
+	if h.Version != protocolVersion {
+		c.writeJSON(helloResponse{Error: fmt.Sprintf("unsupported protocol version %d", h.Version)})
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(h.Token), []byte(w.token)) != 1 {
+		log.Printf("Invalid token from %s\n", nc.RemoteAddr())
+		c.writeJSON(helloResponse{Error: "invalid token"})
+		return
+	}
+	if err := c.writeJSON(helloResponse{NumConcurrent: w.numConcurrent, IsolateVersion: eval.IsolateVersion()}); err != nil {
+		return
+	}
+	nc.SetDeadline(time.Time{})
+
+	if w.debug {
+		log.Printf("Client %s connected\n", nc.RemoteAddr())
+	}
+
+	for {
+		var req request
+		if err := c.readJSON(&req); err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				log.Printf("Could not read request from %s: %v\n", nc.RemoteAddr(), err)
+			}
+			return
+		}
+
+		if err := w.handleRequest(ctx, c, &req); err != nil {
+			log.Printf("Connection with %s broke: %v\n", nc.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// handleRequest runs the requested task. The returned error is not nil only if the connection is unusable
+func (w *Worker) handleRequest(ctx context.Context, c *conn, req *request) error {
+	ctx = eval.WithPriority(ctx, req.Priority)
+	store := &streamStore{c: c}
+	var resp response
+	var binaries []int
+	defer func() {
+		for _, id := range binaries {
+			eval.CleanCompilation(id)
+		}
+	}()
+
+	// recvBinary saves the next stream as a compiled binary and returns its ID
+	recvBinary := func() (int, error) {
+		id := w.newID()
+		binaries = append(binaries, id)
+		f, err := os.OpenFile(binaryPath(id), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+		if err != nil {
+			// Still read the stream, to keep the connection in sync
+			c.recvStream(io.Discard)
+			return id, err
+		}
+		err = c.recvStream(f)
+		if err1 := f.Close(); err1 != nil && err == nil {
+			err = err1
+		}
+		return id, err
+	}
+
+	var taskErr error
+	switch req.Type {
+	case taskCompile:
+		if req.Compile == nil {
+			taskErr = errors.New("missing compile request")
+			break
+		}
+		id := w.newID()
+		binaries = append(binaries, id)
+
+		creq := *req.Compile
+		creq.ID = id
+		task := &tasks.CompileTask{Req: &creq, Debug: w.debug}
+		if taskErr = w.runner.RunTask(ctx, task); taskErr != nil {
+			break
+		}
+		if task.Resp.Success {
+			if err := c.sendStream(func() (io.ReadCloser, error) { return os.Open(binaryPath(id)) }); err != nil {
+				return err
+			}
+		}
+		resp.Compile = &task.Resp
+	case taskExecute:
+		if req.Execute == nil {
+			taskErr = errors.New("missing execute request")
+			break
+		}
+		store.inputs = 1
+
+		ereq := *req.Execute
+		ereq.SubID, taskErr = recvBinary()
+		if taskErr != nil {
+			break
+		}
+		task := &tasks.ExecuteTask{Req: &ereq, Resp: &eval.ExecResponse{}, DM: store, Debug: w.debug}
+		if taskErr = w.runner.RunTask(ctx, task); taskErr != nil {
+			break
+		}
+		resp.Execute = task.Resp
+	case taskChecker:
+		if req.Checker == nil {
+			taskErr = errors.New("missing checker request")
+			break
+		}
+		store.inputs = 3
+
+		task := &checkers.CustomCheckerTask{Lang: req.Checker.Lang, Protocol: req.Checker.Protocol}
+		task.CheckerID, taskErr = recvBinary()
+		if taskErr != nil {
+			break
+		}
+		// the streams are read in the order they are sent
+		task.POut, task.CIn, task.COut = store.nextInput(), store.nextInput(), store.nextInput()
+		if taskErr = w.runner.RunTask(ctx, task); taskErr != nil {
+			break
+		}
+		resp.Checker = &checkerResponse{Verdict: task.Verdict, Score: task.Score, Output: task.Output}
+	case taskInteractive:
+		if req.Interactive == nil {
+			taskErr = errors.New("missing interactive request")
+			break
+		}
+		store.inputs = 2
+
+		ireq := *req.Interactive
+		ireq.SubID, taskErr = recvBinary()
+		if taskErr != nil {
+			// the interactor binary must still be read
+			recvBinary()
+			break
+		}
+		ireq.InteractorID, taskErr = recvBinary()
+		if taskErr != nil {
+			break
+		}
+		task := &tasks.InteractiveTask{Req: &ireq, Resp: &eval.InteractiveResponse{}, DM: store, Debug: w.debug}
+		if taskErr = w.runner.RunMultiboxTask(ctx, task); taskErr != nil {
+			break
+		}
+		resp.Interactive = task.Resp
+	case taskCustomRun:
+		if req.CustomRun == nil {
+			taskErr = errors.New("missing custom run request")
+			break
+		}
+
+		creq := *req.CustomRun
+		creq.ID, taskErr = recvBinary()
+		if taskErr != nil {
+			break
+		}
+		task := &tasks.CustomRunTask{Req: &creq, Resp: &eval.CustomRunResponse{}, Debug: w.debug}
+		if taskErr = w.runner.RunTask(ctx, task); taskErr != nil {
+			break
+		}
+		resp.CustomRun = task.Resp
+	case taskProgram:
+		if req.Program == nil {
+			taskErr = errors.New("missing program request")
+			break
+		}
+		if req.Program.InputID != 0 {
+			store.inputs = 1
+		}
+
+		preq := *req.Program
+		preq.ID, taskErr = recvBinary()
+		if taskErr != nil {
+			break
+		}
+		task := &tasks.ProgramTask{Req: &preq, Resp: &eval.ProgramResponse{}, DM: store, Debug: w.debug}
+		if taskErr = w.runner.RunTask(ctx, task); taskErr != nil {
+			break
+		}
+		resp.Program = task.Resp
+	default:
+		taskErr = fmt.Errorf("unknown task type %q", req.Type)
+	}
+
+	if err := store.finish(); err != nil {
+		return err
+	}
+
+	if taskErr != nil {
+		if w.debug {
+			log.Printf("Task %q failed: %v\n", req.Type, taskErr)
+		}
+		resp = response{Error: taskErr.Error()}
+	}
+	return c.writeJSON(resp)
+}
+
+func (w *Worker) newID() int {
+	return int(atomic.AddInt64(&w.lastID, 1))
+}
+
+var _ kilonova.GraderStore = &streamStore{}
+
+var errNotSupported = errors.New("remote: operation not supported by the worker")
+
+// streamStore is the GraderStore used by tasks on the worker.
+// Tests are read, in order, from the incoming streams of the connection, and the subtest output is streamed back
+type streamStore struct {
+	c *conn
+
+	// inputs is the number of incoming streams that were not yet given to the task
+	inputs int
+	output *streamWriter
+}
+
+// nextInput returns a reader for the next incoming stream. The stream is opened on the first read,
+// so the readers must be read in the order of the streams, each one after the previous one was fully read
+func (s *streamStore) nextInput() io.ReadCloser {
+	return &lazyStream{s: s}
+}
+
+func (s *streamStore) openInput() io.Reader {
+	if s.inputs <= 0 {
+		return &errReader{errNotSupported}
+	}
+	s.inputs--
+	r, err := s.c.nextStream()
+	if err != nil {
+		return &errReader{err}
+	}
+	return r
+}
+
+func (s *streamStore) TestInput(testID int) (io.ReadCloser, error) {
+	return s.nextInput(), nil
+}
+
+func (s *streamStore) TestOutput(testID int) (io.ReadCloser, error) {
+	return s.nextInput(), nil
+}
+
+func (s *streamStore) SubtestWriter(subtest int) (io.WriteCloser, error) {
+	if s.output != nil {
+		return nil, errNotSupported
+	}
+	s.output = &streamWriter{c: s.c}
+	return s.output, nil
+}
+
+// finish consumes the streams not used by the task. It returns an error if the connection is unusable
+func (s *streamStore) finish() error {
+	for ; s.inputs > 0; s.inputs-- {
+		if err := s.c.recvStream(io.Discard); err != nil && !isRemoteError(err) {
+			return err
+		}
+	}
+	if s.c.cur != nil {
+		if err := s.c.cur.drain(); err != nil {
+			return err
+		}
+		s.c.cur = nil
+	}
+	if s.output != nil && !s.output.closed {
+		return s.output.Close()
+	}
+	return nil
+}
+
+func (s *streamStore) SaveTestInput(testID int, input io.Reader) error {
+	return errNotSupported
+}
+
+func (s *streamStore) SaveTestOutput(testID int, output io.Reader) error {
+	return errNotSupported
+}
+
+func (s *streamStore) SubtestReader(subtest int) (io.ReadCloser, error) {
+	return nil, errNotSupported
+}
+
+func (s *streamStore) RemoveSubtestData(subtest int) error {
+	return errNotSupported
+}
+
+type lazyStream struct {
+	s *streamStore
+	r io.Reader
+}
+
+func (l *lazyStream) Read(p []byte) (int, error) {
+	if l.r == nil {
+		l.r = l.s.openInput()
+	}
+	return l.r.Read(p)
+}
+
+// Close does nothing, the unread data is discarded by streamStore.finish
+func (l *lazyStream) Close() error {
+	return nil
+}
+
+type errReader struct {
+	err error
+}
+
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+func isRemoteError(err error) bool {
+	var rerr *remoteError
+	return errors.As(err, &rerr)
+}
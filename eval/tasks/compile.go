@@ -35,7 +35,7 @@ func (job *CompileTask) Execute(ctx context.Context, box eval.Sandbox) error {
 	job.Resp.Success = true
 
 	if lang.IsCompiled {
-		out, err := eval.CompileFile(ctx, box, job.Req.Code, lang)
+		out, err := eval.CompileFile(ctx, box, job.Req, lang)
 		job.Resp.Output = out
 
 		if err != nil {
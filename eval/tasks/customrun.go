@@ -0,0 +1,117 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"path"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/internal/config"
+)
+
+var _ eval.Task = &CustomRunTask{}
+
+// MaxCustomOutput is the number of bytes of stdout and stderr that are returned by a custom run
+const MaxCustomOutput = 64 * 1024
+
+// CustomRunTask runs a compiled program on the input given in the request and returns its stdout and stderr
+type CustomRunTask struct {
+	Req   *eval.CustomRunRequest
+	Resp  *eval.CustomRunResponse
+	Debug bool
+}
+
+func (job *CustomRunTask) Execute(ctx context.Context, box eval.Sandbox) error {
+	if job.Debug {
+		log.Printf("Executing custom run %d using box %d\n", job.Req.ID, box.GetID())
+	}
+
+	lang, ok := config.Languages[job.Req.Lang]
+	if !ok {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "No language found"
+		return nil
+	}
+
+	if err := box.WriteFile("/box/stdin.in", bytes.NewReader(job.Req.Input), 0644); err != nil {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Sandbox error: Couldn't write input file"
+		return err
+	}
+
+	if err := eval.CopyInBox(box, path.Join(config.Eval.CompilePath, fmt.Sprintf("%d.bin", job.Req.ID)), lang.CompiledName); err != nil {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Couldn't copy executable in box"
+		return err
+	}
+
+	lim := eval.Limits{
+		MemoryLimit: job.Req.MemoryLimit,
+		StackLimit:  job.Req.StackLimit,
+		TimeLimit:   job.Req.TimeLimit,
+		OutputLimit: job.Req.OutputLimit,
+	}.Adjust(job.Req.Adjustment)
+	stderr := &limitedBuffer{max: MaxCustomOutput}
+	runConf := &eval.RunConfig{
+		InputPath:  "/box/stdin.in",
+		OutputPath: "/box/stdin.out",
+		Stderr:     stderr,
+	}
+	meta, err := eval.RunProgram(ctx, box, lang, lim, runConf)
+	if err != nil {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, fmt.Sprintf("Error running program: %v", err)
+		return nil
+	}
+	job.Resp.Time = meta.Time
+	job.Resp.Memory = meta.Memory
+	job.Resp.ExitCode = meta.ExitCode
+	job.Resp.ExitSignal = meta.ExitSignal
+	job.Resp.Verdict, job.Resp.Comments = metaVerdict(meta)
+
+	job.Resp.Stderr = stderr.buf.String()
+	job.Resp.Truncated = stderr.truncated
+
+	if !box.FileExists("/box/stdin.out") {
+		return nil
+	}
+	out, err := box.ReadFile("/box/stdin.out")
+	if err != nil {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Could not read output file"
+		return nil
+	}
+	defer out.Close()
+
+	var stdout bytes.Buffer
+	n, err := io.Copy(&stdout, io.LimitReader(out, MaxCustomOutput+1))
+	if err != nil {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Could not read output file"
+		return nil
+	}
+	if n > MaxCustomOutput {
+		stdout.Truncate(MaxCustomOutput)
+		job.Resp.Truncated = true
+	}
+	job.Resp.Stdout = stdout.String()
+
+	return nil
+}
+
+// limitedBuffer keeps the first max bytes written to it and discards the rest.
+// Writes never fail, so the program isn't blocked when it writes too much
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (l *limitedBuffer) Write(p []byte) (int, error) {
+	if left := l.max - l.buf.Len(); left < len(p) {
+		l.truncated = true
+		if left > 0 {
+			l.buf.Write(p[:left])
+		}
+		return len(p), nil
+	}
+	return l.buf.Write(p)
+}
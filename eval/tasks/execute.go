@@ -31,16 +31,15 @@ func (job *ExecuteTask) Execute(ctx context.Context, box eval.Sandbox) error {
 	}
 	defer in.Close()
 
-	if err := box.WriteFile("/box/"+job.Req.Filename+".in", in, 0644); err != nil {
+	if err := eval.ReaderInBox(box, "/box/"+job.Req.Filename+".in", in); err != nil {
 		fmt.Println("Can't write input file:", err)
-		job.Resp.Comments = "Sandbox error: Couldn't write input file"
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Sandbox error: Couldn't write input file"
 		return err
 	}
-	consoleInput := job.Req.Filename == "stdin"
 
 	lang := config.Languages[job.Req.Lang]
 	if err := eval.CopyInBox(box, path.Join(config.Eval.CompilePath, fmt.Sprintf("%d.bin", job.Req.SubID)), lang.CompiledName); err != nil {
-		job.Resp.Comments = "Couldn't copy executable in box"
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Couldn't copy executable in box"
 		return err
 	}
 
@@ -48,47 +47,67 @@ func (job *ExecuteTask) Execute(ctx context.Context, box eval.Sandbox) error {
 		MemoryLimit: job.Req.MemoryLimit,
 		StackLimit:  job.Req.StackLimit,
 		TimeLimit:   job.Req.TimeLimit,
-	}
-	meta, err := eval.RunSubmission(ctx, box, config.Languages[job.Req.Lang], lim, consoleInput)
+		OutputLimit: job.Req.OutputLimit,
+	}.Adjust(job.Req.Adjustment)
+	meta, err := eval.RunSubmission(ctx, box, config.Languages[job.Req.Lang], lim, job.Req.Filename)
 	if err != nil {
-		job.Resp.Comments = fmt.Sprintf("Error running submission: %v", err)
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, fmt.Sprintf("Error running submission: %v", err)
 		return nil
 	}
 	job.Resp.Time = meta.Time
 	job.Resp.Memory = meta.Memory
 
-	switch meta.Status {
-	case "TO":
-		job.Resp.Comments = "TLE: " + meta.Message
-	case "RE":
-		job.Resp.Comments = "Runtime Error: " + meta.Message
-	case "SG":
-		job.Resp.Comments = meta.Message
-	case "XX":
-		job.Resp.Comments = "Sandbox Error: " + meta.Message
+	job.Resp.Verdict, job.Resp.Comments = metaVerdict(meta)
+	// The output is incomplete and could be huge, don't save it
+	if job.Resp.Verdict == kilonova.VerdictOutputLimit {
+		return nil
 	}
 
 	boxOut := fmt.Sprintf("/box/%s.out", job.Req.Filename)
 	if !box.FileExists(boxOut) {
-		job.Resp.Comments = "No output file found"
+		// A crash explains the missing output better
+		if job.Resp.Verdict == kilonova.VerdictNone {
+			job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictWrongAnswer, "No output file found"
+		}
 		return nil
 	}
 
 	w, err := job.DM.SubtestWriter(job.Req.SubtestID)
 	if err != nil {
-		job.Resp.Comments = "Could not open problem output"
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Could not open problem output"
 		return nil
 	}
 
 	if err := eval.CopyFromBox(box, boxOut, w); err != nil {
-		job.Resp.Comments = "Could not write output file"
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Could not write output file"
 		return nil
 	}
 
 	if err := w.Close(); err != nil {
-		job.Resp.Comments = "Could not close output file"
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Could not close output file"
 		return nil
 	}
 
 	return nil
 }
+
+// metaVerdict returns the verdict for the status in the meta file, or VerdictNone if the program ran successfully
+func metaVerdict(meta *eval.RunStats) (kilonova.VerdictCode, string) {
+	if meta.MemoryLimitExceeded {
+		return kilonova.VerdictMemoryLimit, "Memory Limit Exceeded"
+	}
+	if meta.OutputLimitExceeded {
+		return kilonova.VerdictOutputLimit, "Output Limit Exceeded"
+	}
+	switch meta.Status {
+	case "TO":
+		return kilonova.VerdictTimeLimit, "TLE: " + meta.Message
+	case "RE":
+		return kilonova.VerdictRuntimeError, "Runtime Error: " + meta.Message
+	case "SG":
+		return kilonova.VerdictRuntimeError, meta.Message
+	case "XX":
+		return kilonova.VerdictSystemError, "Sandbox Error: " + meta.Message
+	}
+	return kilonova.VerdictNone, ""
+}
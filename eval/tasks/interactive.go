@@ -0,0 +1,211 @@
+package tasks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/internal/config"
+)
+
+var _ eval.MultiboxTask = &InteractiveTask{}
+
+const interactorErr = "Interactor error"
+
+// minInteractorMemory and minInteractorStack are the smallest limits given to the interactor, in kilobytes
+const (
+	minInteractorMemory = 64 * 1024
+	minInteractorStack  = 32 * 1024
+)
+
+// InteractiveTask runs the submission in a sandbox and the interactor in another one.
+// The standard output of each program is piped to the standard input of the other.
+// The interactor receives the test input and the correct output as arguments and,
+// after the interaction is done, it must print the score (0-100) and a message to stderr
+type InteractiveTask struct {
+	Req   *eval.InteractiveRequest
+	Resp  *eval.InteractiveResponse
+	DM    kilonova.GraderStore
+	Debug bool
+}
+
+func (job *InteractiveTask) NumSandboxes() int {
+	return 2
+}
+
+func (job *InteractiveTask) ExecuteMultibox(ctx context.Context, boxes []eval.Sandbox) error {
+	box, interBox := boxes[0], boxes[1]
+	if job.Debug {
+		log.Printf("Executing interactive test %d using boxes %d and %d\n", job.Req.SubtestID, box.GetID(), interBox.GetID())
+	}
+
+	lang, ok := config.Languages[job.Req.Lang]
+	if !ok {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "No language found"
+		return nil
+	}
+	interLang, ok := config.Languages[job.Req.InteractorLang]
+	if !ok {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictCheckerFail, interactorErr
+		return nil
+	}
+
+	in, err := job.DM.TestInput(job.Req.TestID)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := job.DM.TestOutput(job.Req.TestID)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := eval.ReaderInBox(interBox, "/box/input.in", in); err != nil {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Sandbox error: Couldn't write input file"
+		return err
+	}
+	if err := eval.ReaderInBox(interBox, "/box/correct.out", out); err != nil {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Sandbox error: Couldn't write output file"
+		return err
+	}
+
+	if err := eval.CopyInBox(box, path.Join(config.Eval.CompilePath, fmt.Sprintf("%d.bin", job.Req.SubID)), lang.CompiledName); err != nil {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Couldn't copy executable in box"
+		return err
+	}
+	if err := eval.CopyInBox(interBox, path.Join(config.Eval.CompilePath, fmt.Sprintf("%d.bin", job.Req.InteractorID)), interLang.CompiledName); err != nil {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Couldn't copy interactor in box"
+		return err
+	}
+
+	// subR/subW carry the submission output, interR/interW carry the interactor output
+	subR, subW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	interR, interW, err := os.Pipe()
+	if err != nil {
+		subR.Close()
+		subW.Close()
+		return err
+	}
+	// The ends are closed by RunCommand after the processes start, this only cleans up on early errors
+	defer func() {
+		subR.Close()
+		subW.Close()
+		interR.Close()
+		interW.Close()
+	}()
+
+	var interOut bytes.Buffer
+	subConf := &eval.RunConfig{
+		Stdin:           interR,
+		Stdout:          subW,
+		CloseAfterStart: []io.Closer{interR, subW},
+	}
+	interConf := &eval.RunConfig{
+		Stdin:           subR,
+		Stdout:          interW,
+		Stderr:          &interOut,
+		CloseAfterStart: []io.Closer{subR, interW},
+	}
+
+	lim := eval.Limits{
+		MemoryLimit: job.Req.MemoryLimit,
+		StackLimit:  job.Req.StackLimit,
+		TimeLimit:   job.Req.TimeLimit,
+	}.Adjust(job.Req.Adjustment)
+	// The interactor gets the limits of the problem, without the adjustments for the language of the submission,
+	// but never less than the limits of custom checkers
+	interLim := eval.Limits{
+		MemoryLimit: job.Req.MemoryLimit,
+		StackLimit:  job.Req.StackLimit,
+	}
+	if interLim.MemoryLimit < minInteractorMemory {
+		interLim.MemoryLimit = minInteractorMemory
+	}
+	if interLim.StackLimit < minInteractorStack {
+		interLim.StackLimit = minInteractorStack
+	}
+	// The interactor must not be killed before the submission
+	if lim.TimeLimit > 0 {
+		interLim.TimeLimit = lim.TimeLimit + 1
+	}
+
+	var wg sync.WaitGroup
+	var subMeta, interMeta *eval.RunStats
+	var subErr, interErr error
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		subMeta, subErr = eval.RunProgram(ctx, box, lang, lim, subConf)
+	}()
+	go func() {
+		defer wg.Done()
+		interMeta, interErr = eval.RunProgram(ctx, interBox, interLang, interLim, interConf, "/box/input.in", "/box/correct.out")
+	}()
+	wg.Wait()
+
+	if subErr != nil || subMeta == nil {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, fmt.Sprintf("Error running submission: %v", subErr)
+		return nil
+	}
+	job.Resp.Time = subMeta.Time
+	job.Resp.Memory = subMeta.Memory
+	subVerdict, subComments := metaVerdict(subMeta)
+
+	if interMeta != nil {
+		job.Resp.InteractorTime = interMeta.Time
+		job.Resp.InteractorMemory = interMeta.Memory
+	}
+
+	if subMeta.Status == "TO" {
+		job.Resp.Verdict, job.Resp.Comments = subVerdict, subComments
+		return nil
+	}
+
+	var score int
+	if interErr != nil || interMeta == nil || interMeta.Status != "" {
+		if job.Debug {
+			log.Printf("Interactor failed: %v %#v\n", interErr, interMeta)
+		}
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictCheckerFail, interactorErr
+		// The interactor might have failed because the submission crashed
+		if subVerdict != kilonova.VerdictNone {
+			job.Resp.Verdict, job.Resp.Comments = subVerdict, subComments
+		}
+		return nil
+	}
+
+	if _, err := fmt.Fscanf(&interOut, "%d ", &score); err != nil {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictCheckerFail, "Wrong interactor output"
+		return nil
+	}
+	if score < 0 {
+		score = 0
+	}
+	if score > 100 {
+		score = 100
+	}
+
+	// A crashing submission gets no points, but the interactor might better explain the reason
+	if subVerdict != kilonova.VerdictNone && score > 0 {
+		job.Resp.Verdict, job.Resp.Comments = subVerdict, subComments
+		return nil
+	}
+
+	job.Resp.Verdict = kilonova.ScoreVerdict(score)
+	job.Resp.Score = score
+	job.Resp.Comments = strings.TrimSpace(interOut.String())
+	return nil
+}
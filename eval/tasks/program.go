@@ -0,0 +1,94 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
+	"github.com/KiloProjects/kilonova/internal/config"
+)
+
+var _ eval.Task = &ProgramTask{}
+
+// ProgramTask runs a helper program of a problem, like a test generator or a validator.
+// The input and output are read from and saved in the DM, depending on the request
+type ProgramTask struct {
+	Req   *eval.ProgramRequest
+	Resp  *eval.ProgramResponse
+	DM    kilonova.GraderStore
+	Debug bool
+}
+
+func (job *ProgramTask) Execute(ctx context.Context, box eval.Sandbox) error {
+	if job.Debug {
+		log.Printf("Executing program %d using box %d\n", job.Req.ID, box.GetID())
+	}
+
+	lang, ok := config.Languages[job.Req.Lang]
+	if !ok {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "No language found"
+		return nil
+	}
+
+	runConf := &eval.RunConfig{OutputPath: "/box/program.out"}
+	if job.Req.InputID != 0 {
+		in, err := job.DM.TestInput(job.Req.InputID)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		if err := eval.ReaderInBox(box, "/box/program.in", in); err != nil {
+			job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Sandbox error: Couldn't write input file"
+			return err
+		}
+		runConf.InputPath = "/box/program.in"
+	}
+
+	if err := eval.CopyInBox(box, path.Join(config.Eval.CompilePath, fmt.Sprintf("%d.bin", job.Req.ID)), lang.CompiledName); err != nil {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Couldn't copy executable in box"
+		return err
+	}
+
+	lim := eval.Limits{
+		MemoryLimit: job.Req.MemoryLimit,
+		TimeLimit:   job.Req.TimeLimit,
+		OutputLimit: job.Req.OutputLimit,
+	}
+	stderr := &limitedBuffer{max: MaxCustomOutput}
+	runConf.Stderr = stderr
+	meta, err := eval.RunProgram(ctx, box, lang, lim, runConf, job.Req.Args...)
+	if err != nil {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, fmt.Sprintf("Error running program: %v", err)
+		return nil
+	}
+	job.Resp.Time = meta.Time
+	job.Resp.Memory = meta.Memory
+	job.Resp.ExitCode = meta.ExitCode
+	job.Resp.Verdict, job.Resp.Comments = metaVerdict(meta)
+	job.Resp.Stderr = stderr.buf.String()
+
+	if job.Req.OutputID == 0 || job.Resp.Verdict != kilonova.VerdictNone {
+		return nil
+	}
+	if !box.FileExists("/box/program.out") {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "No output file found"
+		return nil
+	}
+	w, err := job.DM.SubtestWriter(job.Req.OutputID)
+	if err != nil {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Could not open program output"
+		return nil
+	}
+	if err := eval.CopyFromBox(box, "/box/program.out", w); err != nil {
+		w.Close()
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Could not write program output"
+		return nil
+	}
+	if err := w.Close(); err != nil {
+		job.Resp.Verdict, job.Resp.Comments = kilonova.VerdictSystemError, "Could not write program output"
+	}
+	return nil
+}
@@ -3,6 +3,9 @@ package eval
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -11,6 +14,7 @@ import (
 	"os/exec"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/KiloProjects/kilonova/internal/config"
@@ -19,10 +23,13 @@ import (
 const (
 	releasePrefix = "https://github.com/KiloProjects/isolate/releases/latest/download/"
 	configURL     = releasePrefix + "default.cf"
-	configPath    = "/usr/local/etc/isolate"
-	isolateURL    = releasePrefix + "isolate"
+	// configPath is where isolate reads its config from
+	configPath = "/usr/local/etc/isolate"
+	isolateURL = releasePrefix + "isolate"
 )
 
+var isolateVersion string
+
 func CopyFromBox(b Sandbox, p string, w io.Writer) error {
 	f, err := b.ReadFile(p)
 	if err != nil {
@@ -49,12 +56,39 @@ func CopyInBox(b Sandbox, p1 string, p2 string) error {
 	return b.WriteFile(p2, file, stat.Mode())
 }
 
-// RunSubmission runs a program, following the language conventions
-// filenames contains the names for input and output, used if consoleInput is true
-func RunSubmission(ctx context.Context, box Sandbox, language config.Language, constraints Limits, consoleInput bool) (*RunStats, error) {
+// ReaderInBox makes the contents of the reader available at the path in the box.
+// Readers of files on disk are linked in the box, so large tests are not copied, the others are written
+func ReaderInBox(b Sandbox, p string, r io.Reader) error {
+	if f, ok := r.(*os.File); ok {
+		if err := b.LinkFile(f.Name(), p); err == nil {
+			return nil
+		}
+	}
+	return b.WriteFile(p, r, 0644)
+}
 
+// RunSubmission runs a program, following the language conventions
+// filename is the name of the input and output files, without the extension. If it is "stdin", they are used as the standard input and output
+func RunSubmission(ctx context.Context, box Sandbox, language config.Language, constraints Limits, filename string) (*RunStats, error) {
 	var runConf RunConfig
-	runConf.EnvToSet = make(map[string]string)
+
+	if filename == "stdin" {
+		runConf.InputPath = "/box/stdin.in"
+		runConf.OutputPath = "/box/stdin.out"
+	} else {
+		runConf.OutputFiles = []string{"/box/" + filename + ".out"}
+	}
+
+	return RunProgram(ctx, box, language, constraints, &runConf)
+}
+
+// RunProgram runs the program in the box using the specified run configuration,
+// after adding the language environment, mounts and the constraints to it
+// args are appended to the language's run command
+func RunProgram(ctx context.Context, box Sandbox, language config.Language, constraints Limits, runConf *RunConfig, args ...string) (*RunStats, error) {
+	if runConf.EnvToSet == nil {
+		runConf.EnvToSet = make(map[string]string)
+	}
 
 	// if our specified language is not compiled, then it means that
 	// the mounts specified should be added at runtime
@@ -71,31 +105,58 @@ func RunSubmission(ctx context.Context, box Sandbox, language config.Language, c
 
 	runConf.MemoryLimit = constraints.MemoryLimit
 	runConf.StackLimit = constraints.StackLimit
+	runConf.OutputLimit = constraints.OutputLimit
 	runConf.TimeLimit = constraints.TimeLimit
 	runConf.WallTimeLimit = constraints.TimeLimit + 1
 	if constraints.TimeLimit == 0 {
 		runConf.WallTimeLimit = 15
 	}
 
-	if consoleInput {
-		runConf.InputPath = "/box/stdin.in"
-		runConf.OutputPath = "/box/stdin.out"
-	}
-
 	goodCmd, err := MakeGoodCommand(language.RunCommand)
 	if err != nil {
 		log.Printf("WARNING: function makeGoodCommand returned an error: %q. This is not good, so we'll use the command from the config file. The supplied command was %#v", err, language.RunCommand)
 		goodCmd = language.RunCommand
 	}
+	goodCmd = append(goodCmd, args...)
 
-	return box.RunCommand(ctx, goodCmd, &runConf)
+	return box.RunCommand(ctx, goodCmd, runConf)
 }
 
-// CompileFile compiles a file that has the corresponding language
-func CompileFile(ctx context.Context, box Sandbox, SourceCode []byte, language config.Language) (string, error) {
-	if err := box.WriteFile(language.SourceName, bytes.NewReader(SourceCode), 0644); err != nil {
+// CompileFile compiles the code of the request, along with its extra files, in the corresponding language
+func CompileFile(ctx context.Context, box Sandbox, req *CompileRequest, language config.Language) (string, error) {
+	sourceName := language.SourceName
+	if req.SourceName != "" {
+		sourceName = path.Join(path.Dir(language.SourceName), req.SourceName+path.Ext(language.SourceName))
+	}
+	if err := box.WriteFile(sourceName, bytes.NewReader(req.Code), 0644); err != nil {
 		return "", err
 	}
+	written, sources := []string{sourceName}, []string{sourceName}
+
+	names := make([]string, 0, len(req.ExtraFiles))
+	for name := range req.ExtraFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name != path.Base(name) || name == "." || name == ".." {
+			return "", fmt.Errorf("Invalid extra file name %q", name)
+		}
+		fpath := path.Join(path.Dir(language.SourceName), name)
+		if fpath == sourceName {
+			return "", fmt.Errorf("Extra file %q would replace the source file", name)
+		}
+		if err := box.WriteFile(fpath, bytes.NewReader(req.ExtraFiles[name]), 0644); err != nil {
+			return "", err
+		}
+		written = append(written, fpath)
+		for _, ext := range language.Extensions {
+			if path.Ext(name) == ext {
+				sources = append(sources, fpath)
+				break
+			}
+		}
+	}
 
 	var conf RunConfig
 	conf.EnvToSet = make(map[string]string)
@@ -116,6 +177,16 @@ func CompileFile(ctx context.Context, box Sandbox, SourceCode []byte, language c
 		log.Printf("WARNING: function makeGoodCommand returned an error: %q. This is not good, so we'll use the command from the config file. The supplied command was %#v", err, language.CompileCommand)
 		goodCmd = language.CompileCommand
 	}
+	// All the sources are compiled where the language expects its source file
+	var cmd []string
+	for _, arg := range goodCmd {
+		if arg == language.SourceName {
+			cmd = append(cmd, sources...)
+			continue
+		}
+		cmd = append(cmd, arg)
+	}
+	goodCmd = cmd
 
 	var out bytes.Buffer
 	conf.Stdout = &out
@@ -128,7 +199,12 @@ func CompileFile(ctx context.Context, box Sandbox, SourceCode []byte, language c
 		return combinedOut, err
 	}
 
-	return combinedOut, box.RemoveFile(language.SourceName)
+	for _, fpath := range written {
+		if err := box.RemoveFile(fpath); err != nil {
+			return combinedOut, err
+		}
+	}
+	return combinedOut, nil
 }
 
 // makeGoodCommand makes sure it's a full path (with no symlinks) for the command.
@@ -165,68 +241,67 @@ func disableLang(key string) {
 	config.Languages[key] = lang
 }
 
+// CheckLanguage returns why the compiler/interpreter of the language can't be used, or nil if it was found
+func CheckLanguage(lang config.Language) error {
+	var toSearch []string
+	if lang.IsCompiled {
+		toSearch = lang.CompileCommand
+	} else {
+		toSearch = lang.RunCommand
+	}
+	if len(toSearch) == 0 {
+		return errors.New("the command is empty")
+	}
+	cmd, err := exec.LookPath(toSearch[0])
+	if err != nil {
+		return errors.New("the compiler/interpreter was not found in PATH")
+	}
+	cmd, err = filepath.EvalSymlinks(cmd)
+	if err != nil {
+		return errors.New("the compiler/interpreter had a bad symlink")
+	}
+	stat, err := os.Stat(cmd)
+	if err != nil {
+		return errors.New("the compiler/interpreter binary was not found")
+	}
+	if stat.Mode()&0111 == 0 {
+		return errors.New("the compiler/interpreter binary is not executable")
+	}
+	return nil
+}
+
 // checkLanguages disables all languages that are *not* detected by the system in the current configuration
 // It should be run at the start of the execution (and implemented more nicely tbh)
 func checkLanguages() {
 	for k, v := range config.Languages {
-		var toSearch []string
-		if v.IsCompiled {
-			toSearch = v.CompileCommand
-		} else {
-			toSearch = v.RunCommand
-		}
-		if len(toSearch) == 0 {
+		if err := CheckLanguage(v); err != nil {
 			disableLang(k)
-			log.Printf("Language %q was disabled because of empty line\n", k)
-			continue
-		}
-		cmd, err := exec.LookPath(toSearch[0])
-		if err != nil {
-			disableLang(k)
-			log.Printf("Language %q was disabled because the compiler/interpreter was not found in PATH\n", k)
-			continue
-		}
-		cmd, err = filepath.EvalSymlinks(cmd)
-		if err != nil {
-			disableLang(k)
-			log.Printf("Language %q was disabled because the compiler/interpreter had a bad symlink\n", k)
-			continue
+			log.Printf("Language %q was disabled because %v\n", k, err)
 		}
-		stat, err := os.Stat(cmd)
-		if err != nil {
-			disableLang(k)
-			log.Printf("Language %q was disabled because the compiler/interpreter binary was not found\n", k)
-			continue
-		}
-
-		if stat.Mode()&0111 == 0 {
-			disableLang(k)
-			log.Printf("Language %q was disabled because the compiler/interpreter binary is not executable\n", k)
-		}
-
 	}
 }
 
 // Initialize should be called after reading the flags, but before manager.New
 func Initialize() error {
-
-	// Test right now if they exist
-	if _, err := os.Stat(config.Eval.IsolatePath); os.IsNotExist(err) {
-		// download isolate
-		fmt.Println("Downloading isolate binary")
-		if err := downloadFile(isolateURL, config.Eval.IsolatePath, 0744); err != nil {
-			return err
-		}
-		fmt.Println("Isolate binary downloaded")
+	if err := ensureFile(config.Eval.IsolatePath, isolateURL, 0744, "isolate binary"); err != nil {
+		return err
 	}
-	if _, err := os.Stat(config.Eval.IsolatePath); os.IsNotExist(err) {
-		// download the config file
-		fmt.Println("Downloading isolate config")
-		if err := downloadFile(configURL, config.Eval.IsolatePath, 0644); err != nil {
-			return err
-		}
-		fmt.Println("Isolate config downloaded")
+	if err := ensureFile(isolateConfigPath(), configURL, 0644, "isolate config"); err != nil {
+		return err
+	}
+	if err := VerifyIsolate(); err != nil {
+		return err
 	}
+	if err := installIsolateConfig(); err != nil {
+		return err
+	}
+
+	version, err := readIsolateVersion()
+	if err != nil {
+		return fmt.Errorf("could not get the isolate version: %w", err)
+	}
+	isolateVersion = version
+	log.Printf("Using isolate %q\n", version)
 
 	if err := os.MkdirAll(config.Eval.CompilePath, 0777); err != nil {
 		return err
@@ -237,11 +312,109 @@ func Initialize() error {
 	return nil
 }
 
+// IsolateVersion returns the version reported by the isolate binary, it is empty if Initialize was not called
+func IsolateVersion() string {
+	return isolateVersion
+}
+
+// VerifyIsolate checks that the isolate binary and its config exist and match the checksums from the config, if they are set
+func VerifyIsolate() error {
+	if err := verifyChecksum(config.Eval.IsolatePath, config.Eval.IsolateSHA256); err != nil {
+		return err
+	}
+	return verifyChecksum(isolateConfigPath(), config.Eval.IsolateConfigSHA256)
+}
+
+// isolateConfigPath is the isolate config that is used, by default the one read by isolate
+func isolateConfigPath() string {
+	if config.Eval.IsolateConfigPath == "" {
+		return configPath
+	}
+	return config.Eval.IsolateConfigPath
+}
+
+// ensureFile downloads the file if it is missing and downloads are allowed
+func ensureFile(p, url string, perm os.FileMode, name string) error {
+	_, err := os.Stat(p)
+	if err == nil || !os.IsNotExist(err) {
+		return err
+	}
+	if config.Eval.DisableIsolateDownload {
+		return fmt.Errorf("the %s was not found at %q and downloading it is disabled (see disable_isolate_download in the eval config)", name, p)
+	}
+
+	log.Printf("Downloading the %s from %s\n", name, url)
+	if err := downloadFile(url, p, perm); err != nil {
+		return fmt.Errorf("could not download the %s: %w", name, err)
+	}
+	log.Printf("The %s was downloaded to %q\n", name, p)
+	return nil
+}
+
+func verifyChecksum(p, expected string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if expected == "" {
+		return nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if sum := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(sum, expected) {
+		return fmt.Errorf("the SHA256 of %q is %s, expected %s", p, sum, expected)
+	}
+	return nil
+}
+
+// installIsolateConfig copies the configured isolate config where isolate reads it from, if they are different files
+func installIsolateConfig() error {
+	src := isolateConfigPath()
+	if src == configPath {
+		return nil
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	if old, err := os.ReadFile(configPath); err == nil && bytes.Equal(old, data) {
+		return nil
+	}
+	if err := os.MkdirAll(path.Dir(configPath), 0755); err != nil {
+		return err
+	}
+	log.Printf("Installing the isolate config from %q\n", src)
+	return os.WriteFile(configPath, data, 0644)
+}
+
+// readIsolateVersion returns the first line of the output of isolate --version
+func readIsolateVersion() (string, error) {
+	out, err := exec.Command(config.Eval.IsolatePath, "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0]), nil
+}
+
+// InitializeRemote should be called instead of Initialize when the submissions are evaluated by remote workers,
+// since the isolate binary and the compilers are needed only on the workers
+func InitializeRemote() error {
+	return os.MkdirAll(config.Eval.CompilePath, 0777)
+}
+
 func downloadFile(url, path string, perm os.FileMode) error {
 	resp, err := http.Get(url)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
 
 	file, err := os.Create(path)
 	if err != nil {
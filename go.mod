@@ -35,4 +35,5 @@ require (
 	golang.org/x/net v0.0.0-20210414194228-064579744ee0 // indirect
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c
 	golang.org/x/text v0.3.6 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -46,10 +46,41 @@ type EmailConf struct {
 
 // EvalConf is the data required for the eval service
 type EvalConf struct {
-	IsolatePath   string `toml:"isolatePath"`
-	CompilePath   string `toml:"compilePath"`
-	Address       string `toml:"address"`
-	NumConcurrent int    `toml:"num_concurrent"`
+	IsolatePath string `toml:"isolatePath"`
+	CompilePath string `toml:"compilePath"`
+
+	// IsolateConfigPath is the isolate config file. isolate always reads /usr/local/etc/isolate,
+	// so any other file is copied there at startup. It defaults to /usr/local/etc/isolate
+	IsolateConfigPath string `toml:"isolate_config_path"`
+	// IsolateSHA256 and IsolateConfigSHA256 are the expected checksums of the isolate binary and config, in hex.
+	// The startup fails if they don't match. They are not checked if empty
+	IsolateSHA256       string `toml:"isolate_sha256"`
+	IsolateConfigSHA256 string `toml:"isolate_config_sha256"`
+	// DisableIsolateDownload stops the latest isolate release from being downloaded if the binary or the config are missing.
+	// If it is set, the startup fails instead, which is useful for offline deployments
+	DisableIsolateDownload bool `toml:"disable_isolate_download"`
+
+	// Address is the address the worker listens on
+	Address string `toml:"address"`
+	// MetricsAddress is the address on which the worker serves its Prometheus metrics, at /metrics. It is disabled if empty
+	MetricsAddress string `toml:"metrics_address"`
+	NumConcurrent  int    `toml:"num_concurrent"`
+
+	// RemoteWorkers are the addresses of the workers that evaluate the submissions.
+	// If it is empty, the submissions are evaluated locally
+	RemoteWorkers []string `toml:"remote_workers"`
+	// Token is the shared secret between the web node and the workers
+	Token string `toml:"token"`
+
+	// PriorityShares maps every submission priority ("live", "contest", "rejudge", "background")
+	// to the percentage of NumConcurrent reserved for it when the grader is busy
+	PriorityShares map[string]int `toml:"priority_shares"`
+
+	// RerunThreshold is how close to the time limit (as a percentage of it) a test must run to be run again.
+	// Tests that exceed the time limit are also run again. The lowest time is kept
+	RerunThreshold float64 `toml:"rerun_threshold"`
+	// MaxReruns is the maximum number of times a test is run again, 0 disables reruns
+	MaxReruns int `toml:"max_reruns"`
 }
 
 // CommonConf is the data required for all services
@@ -100,6 +131,15 @@ type Language struct {
 	SourceName string `toml:"source_name"`
 
 	CompiledName string `toml:"compiled_name"`
+
+	// The limits of the problems are multiplied by the multipliers (if not 0), then the offsets are added.
+	// They can be overridden by every problem
+	TimeMultiplier float64 `toml:"time_multiplier"`
+	// TimeOffset is in seconds
+	TimeOffset       float64 `toml:"time_offset"`
+	MemoryMultiplier float64 `toml:"memory_multiplier"`
+	// MemoryOffset is in kilobytes, it is useful for runtimes with a big base memory usage, like the JVM
+	MemoryOffset int `toml:"memory_offset"`
 }
 
 // /LANGUAGE DEFINITION STUFF --------------------
@@ -4,6 +4,7 @@ import (
 	"archive/zip"
 	"bufio"
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"io"
@@ -35,19 +36,21 @@ func NewArchiveCtx() *ArchiveCtx {
 	return &ArchiveCtx{tests: make(map[int]archiveTest), scoredTests: make([]int, 0, 10), hasScoreFile: false}
 }
 
+// ProcessArchiveFile reads a test file or the test score file. name is the path of the file in the archive
 func ProcessArchiveFile(ctx *ArchiveCtx, name string, file io.Reader) error {
-	if strings.HasSuffix(name, ".txt") { // test score file
+	base := path.Base(name)
+	if strings.HasSuffix(base, ".txt") { // test score file
 
 		// If there's multiple score files, quit
 		if ctx.hasScoreFile {
-			return ErrBadArchive
+			return archiveErrorf(name, 0, "there is more than one test score file")
 		}
 		ctx.hasScoreFile = true
 
 		br := bufio.NewScanner(file)
 
-		for br.Scan() {
-			line := br.Text()
+		for lineNum := 1; br.Scan(); lineNum++ {
+			line := strings.TrimSpace(br.Text())
 
 			if line == "" { // empty line, skip
 				continue
@@ -56,8 +59,7 @@ func ProcessArchiveFile(ctx *ArchiveCtx, name string, file io.Reader) error {
 			var testID int
 			var score int
 			if _, err := fmt.Sscanf(line, "%d %d\n", &testID, &score); err != nil {
-				log.Println(err)
-				return ErrBadTestFile
+				return archiveErrorf(name, lineNum, "expected `<test id> <score>`, got %q", line)
 			}
 
 			test := ctx.tests[testID]
@@ -65,7 +67,7 @@ func ProcessArchiveFile(ctx *ArchiveCtx, name string, file io.Reader) error {
 			ctx.tests[testID] = test
 			for _, ex := range ctx.scoredTests {
 				if ex == testID {
-					return ErrBadTestFile
+					return archiveErrorf(name, lineNum, "test %d already has a score", testID)
 				}
 			}
 
@@ -78,24 +80,23 @@ func ProcessArchiveFile(ctx *ArchiveCtx, name string, file io.Reader) error {
 	}
 
 	var tid int
-	if _, err := fmt.Sscanf(name, "%d-", &tid); err != nil {
-		log.Println("Bad name:", name)
-		return ErrBadArchive
+	if _, err := fmt.Sscanf(base, "%d-", &tid); err != nil {
+		return archiveErrorf(name, 0, "test files must be named <test id>-<name>.in, .out or .ok")
 	}
 
-	if strings.HasSuffix(name, ".in") { // test input file
+	if strings.HasSuffix(base, ".in") { // test input file
 		tf := ctx.tests[tid]
 		if tf.InFile != nil { // in file already exists
-			return fmt.Errorf("Multiple input files for test %d", tid)
+			return archiveErrorf(name, 0, "there are multiple input files for test %d", tid)
 		}
 
 		tf.InFile = file
 		ctx.tests[tid] = tf
 	}
-	if strings.HasSuffix(name, ".out") || strings.HasSuffix(name, ".ok") { // test output file
+	if strings.HasSuffix(base, ".out") || strings.HasSuffix(base, ".ok") { // test output file
 		tf := ctx.tests[tid]
 		if tf.OutFile != nil { // out file already exists
-			return fmt.Errorf("Multiple output files for test %d", tid)
+			return archiveErrorf(name, 0, "there are multiple output files for test %d", tid)
 		}
 
 		tf.OutFile = file
@@ -104,13 +105,84 @@ func ProcessArchiveFile(ctx *ArchiveCtx, name string, file io.Reader) error {
 	return nil
 }
 
+// archiveErrorf returns an error about a file of the archive. line is not shown if it is 0
+func archiveErrorf(name string, line int, format string, args ...interface{}) error {
+	if line > 0 {
+		name = fmt.Sprintf("%s:%d", name, line)
+	}
+	return &kilonova.Error{Code: kilonova.EINVALID, Message: name + ": " + fmt.Sprintf(format, args...)}
+}
+
+// readArchiveManifest finds the manifest of the archive and reads the files referenced by it. It returns nil if there is no manifest
+func readArchiveManifest(ar *zip.Reader) (*ArchiveManifest, error) {
+	var manifest *ArchiveManifest
+	for _, file := range ar.File {
+		if file.FileInfo().IsDir() || !IsManifest(file.Name) {
+			continue
+		}
+		if manifest != nil {
+			return nil, archiveErrorf(file.Name, 0, "the archive has another manifest, %s", manifest.Name)
+		}
+		data, err := readZipFile(file)
+		if err != nil {
+			return nil, err
+		}
+		manifest, err = ParseManifest(file.Name, data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if manifest == nil {
+		return nil, nil
+	}
+
+	manifest.Data = make(map[string][]byte)
+	for _, file := range ar.File {
+		if file.FileInfo().IsDir() || !manifest.References(file.Name) {
+			continue
+		}
+		data, err := readZipFile(file)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Data[file.Name] = data
+	}
+	return manifest, nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	f, err := file.Open()
+	if err != nil {
+		log.Println(err)
+		return nil, archiveErrorf(file.Name, 0, "couldn't read the file")
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		log.Println(err)
+		return nil, archiveErrorf(file.Name, 0, "couldn't read the file")
+	}
+	return data, nil
+}
+
+// ProcessZipTestArchive replaces the tests of the problem with the ones from the archive.
+// If the archive has a manifest (see ParseManifest), the problem settings, subtasks, checker, attachments and statement from it are also applied.
+// The whole archive is checked before anything is changed
 func (kn *Kilonova) ProcessZipTestArchive(pb *kilonova.Problem, ar *zip.Reader) error {
 	ctx := NewArchiveCtx()
 
+	manifest, err := readArchiveManifest(ar)
+	if err != nil {
+		return err
+	}
+
 	for _, file := range ar.File {
 		if file.FileInfo().IsDir() {
 			continue
 		}
+		if manifest != nil && (file.Name == manifest.Name || manifest.References(file.Name)) {
+			continue
+		}
 
 		f, err := file.Open()
 		if err != nil {
@@ -118,52 +190,117 @@ func (kn *Kilonova) ProcessZipTestArchive(pb *kilonova.Problem, ar *zip.Reader)
 			return errors.New("Unknown error")
 		}
 		defer f.Close() // This will always close all files, regardless of when the program leaves
-		if err := ProcessArchiveFile(ctx, path.Base(file.Name), f); err != nil {
+		if err := ProcessArchiveFile(ctx, file.Name, f); err != nil {
 			return err
 		}
 	}
 
-	if len(ctx.scoredTests) != len(ctx.tests) {
-		log.Println(len(ctx.scoredTests), len(ctx.tests))
-		return errors.New("Mismatched number of tests in archive and scored tests")
-	}
-
 	for k, v := range ctx.tests {
 		if v.InFile == nil || v.OutFile == nil {
 			return fmt.Errorf("Missing input or output file for test %d", k)
 		}
 	}
 
-	if !ctx.hasScoreFile {
-		return errors.New("Missing test score file")
+	if manifest != nil {
+		if err := manifest.Validate(pb.Type, ctx.tests); err != nil {
+			return err
+		}
 	}
 
-	// If we are loading an archive, the user might want to remove all tests first
-	// So let's do it for them
-	if err := kn.tserv.OrphanProblemTests(context.Background(), pb.ID); err != nil {
-		log.Println(err)
-		return err
+	// The scores of the tests are not needed if the subtasks are given
+	if !ctx.hasScoreFile && manifest != nil && len(manifest.SubTasks) > 0 {
+		if err := ctx.scoreFromSubTasks(manifest); err != nil {
+			return err
+		}
+	} else {
+		if !ctx.hasScoreFile {
+			return errors.New("Missing test score file")
+		}
+		if len(ctx.scoredTests) != len(ctx.tests) {
+			log.Println(len(ctx.scoredTests), len(ctx.tests))
+			return errors.New("Mismatched number of tests in archive and scored tests")
+		}
 	}
 
-	for testID, v := range ctx.tests {
-		var test kilonova.Test
-		test.ProblemID = pb.ID
-		test.VisibleID = testID
-		test.Score = v.Score
-		if err := kn.tserv.CreateTest(context.Background(), &test); err != nil {
-			log.Println(err)
+	if manifest != nil {
+		if err := kn.applyManifest(context.Background(), pb, manifest); err != nil {
 			return err
 		}
+	}
 
-		if err := kn.DM.SaveTestInput(test.ID, v.InFile); err != nil {
-			log.Println("Couldn't create test input", err)
-			return fmt.Errorf("Couldn't create test input: %w", err)
+	// If we are loading an archive, the user might want to remove all tests first
+	// So let's do it for them
+	tests := make([]NewTest, 0, len(ctx.tests))
+	for testID, v := range ctx.tests {
+		v := v
+		tests = append(tests, NewTest{
+			VisibleID: testID,
+			Score:     v.Score,
+			Input:     func() (io.ReadCloser, error) { return io.NopCloser(v.InFile), nil },
+			Output:    func() (io.ReadCloser, error) { return io.NopCloser(v.OutFile), nil },
+		})
+	}
+	var subTasks []ManifestSubTask
+	if manifest != nil {
+		subTasks = manifest.SubTasks
+	}
+	return kn.ReplaceTests(context.Background(), pb.ID, tests, subTasks)
+}
+
+// scoreFromSubTasks gives every test an equal part of the score of the first subtask that contains it
+func (ctx *ArchiveCtx) scoreFromSubTasks(manifest *ArchiveManifest) error {
+	scored := make(map[int]bool)
+	for _, stk := range manifest.SubTasks {
+		for i, score := range kilonova.SplitScore(stk.Score, len(stk.Tests)) {
+			id := stk.Tests[i]
+			if scored[id] {
+				continue
+			}
+			test := ctx.tests[id]
+			test.Score = score
+			ctx.tests[id], scored[id] = test, true
 		}
-		if err := kn.DM.SaveTestOutput(test.ID, v.OutFile); err != nil {
-			log.Println("Couldn't create test output", err)
-			return fmt.Errorf("Couldn't create test output: %w", err)
+	}
+	for id := range ctx.tests {
+		if !scored[id] {
+			return fmt.Errorf("Test %d is not in any subtask and there is no test score file", id)
 		}
 	}
+	return nil
+}
+
+// applyManifest updates the problem settings, the checker, the statement and the attachments from the manifest
+func (kn *Kilonova) applyManifest(ctx context.Context, pb *kilonova.Problem, manifest *ArchiveManifest) error {
+	upd := manifest.Update
+	if manifest.Statement != nil {
+		desc := string(manifest.Data[manifest.Statement.Path])
+		upd.Description = &desc
+	}
+	if manifest.Checker != nil {
+		code := string(manifest.Data[manifest.Checker.Path])
+		upd.HelperCode = &code
+	}
+	if err := kn.pserv.UpdateProblem(ctx, pb.ID, upd); err != nil && !errors.Is(err, kilonova.ErrNoUpdates) {
+		return err
+	}
 
+	for _, att := range manifest.Attachments {
+		if err := kn.saveAttachment(ctx, &kilonova.Attachment{ProblemID: pb.ID, Name: att.Name, Data: manifest.Data[att.Path], Visible: att.Visible, GraderLang: att.GraderLang}); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// saveAttachment updates the attachment of the problem with the same name or, if there is none, creates it. The ID of the attachment is set
+func (kn *Kilonova) saveAttachment(ctx context.Context, att *kilonova.Attachment) error {
+	existing, err := kn.aserv.Attachments(ctx, false, kilonova.AttachmentFilter{ProblemID: &att.ProblemID, Name: &att.Name})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if len(existing) == 0 {
+		return kn.aserv.CreateAttachment(ctx, att)
+	}
+	att.ID = existing[0].ID
+	return kn.aserv.UpdateAttachment(ctx, att.ID, kilonova.AttachmentUpdate{Data: att.Data, Visible: &att.Visible, GraderLang: &att.GraderLang})
+}
@@ -0,0 +1,925 @@
+package logic
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/internal/config"
+)
+
+// CMSTaskFile is the file that describes a task in the italy_yaml layout of CMS
+const CMSTaskFile = "task.yaml"
+
+// cmsCodename returns the name given by CMS to the test with the specified index
+func cmsCodename(i int) string {
+	return fmt.Sprintf("%03d", i)
+}
+
+// languageByExtension returns the enabled language with the file extension
+func languageByExtension(ext string) (string, bool) {
+	names := make([]string, 0, len(config.Languages))
+	for name := range config.Languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if config.Languages[name].Disabled {
+			continue
+		}
+		for _, e := range config.Languages[name].Extensions {
+			if e == ext {
+				return name, true
+			}
+		}
+	}
+	return "", false
+}
+
+type cmsParser struct {
+	*packageFiles
+	mp  *manifestParser
+	pkg *ProblemPackage
+
+	// n is the number of tests
+	n int
+	// gen holds gen/GEN, if the task has it
+	gen *cmsGen
+}
+
+// cmsGen holds the tests and the subtasks declared in gen/GEN
+type cmsGen struct {
+	// args holds the arguments of the generator for every test
+	args []string
+	// copied is set if some tests are copied instead of generated
+	copied bool
+	// subtasks holds the score and the number of tests of the subtasks declared with `# ST: <score>`
+	subtasks [][2]int
+}
+
+// ParseCMSTask translates a CMS task in the italy_yaml layout: task.yaml, the tests from input/ and output/,
+// the subtasks from gen/GEN (or the scoring from task.yaml), the checker from check/ (or cor/), the graders and the solutions from sol/,
+// the attachments from att/ and the statement from statement/ (or testo/).
+// The test with index i becomes test i+1.
+//
+// The checker must be in the task as source code and it is imported with the CMS protocol.
+// The sources from sol/, other than the graders, are imported as solutions that are expected to get AC
+func ParseCMSTask(ar *zip.Reader) (*ProblemPackage, error) {
+	desc := findPackageFile(ar, CMSTaskFile)
+	if desc == nil {
+		return nil, &kilonova.Error{Code: kilonova.EINVALID, Message: "The archive is not a CMS task, it doesn't have a task.yaml file"}
+	}
+	data, err := readZipFile(desc)
+	if err != nil {
+		return nil, err
+	}
+	root, lines, err := parseYAML(CMSTaskFile, string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	p := &cmsParser{
+		packageFiles: newPackageFiles(ar, path.Dir(desc.Name)),
+		mp:           &manifestParser{file: CMSTaskFile, lines: lines},
+		pkg:          &ProblemPackage{},
+	}
+	t := p.mp.table("", root)
+	for _, step := range []func(*manifestTable) error{p.limits, p.readGen, p.scoring, p.tests, p.tools, p.evaluation, p.sol, p.attachments, p.statement} {
+		if err := step(t); err != nil {
+			return nil, err
+		}
+		if p.mp.err != nil {
+			return nil, p.mp.err
+		}
+	}
+	return p.pkg, nil
+}
+
+func (p *cmsParser) warnf(format string, args ...interface{}) {
+	p.pkg.Warnings = append(p.pkg.Warnings, fmt.Sprintf(format, args...))
+}
+
+// findSource returns the path and the language of the source file with the specified path without the extension, like check/checker
+func (p *cmsParser) findSource(stem string) (string, string) {
+	var rels []string
+	for rel := range p.files {
+		if ext := path.Ext(rel); ext != "" && strings.TrimSuffix(rel, ext) == stem {
+			rels = append(rels, rel)
+		}
+	}
+	sort.Strings(rels)
+	for _, rel := range rels {
+		if lang, ok := languageByExtension(path.Ext(rel)); ok {
+			return rel, lang
+		}
+	}
+	return "", ""
+}
+
+// dirFiles returns the paths of the files in the directory, sorted
+func (p *cmsParser) dirFiles(dir string) []string {
+	var rels []string
+	for rel := range p.files {
+		if path.Dir(rel) == dir {
+			rels = append(rels, rel)
+		}
+	}
+	sort.Strings(rels)
+	return rels
+}
+
+func (p *cmsParser) limits(t *manifestTable) error {
+	upd := &p.pkg.Update
+
+	name, title := t.str("name"), t.str("title")
+	if title != nil && *title != "" {
+		upd.Name = title
+	} else if name != nil && *name != "" {
+		upd.Name = name
+	}
+
+	// timeout and memlimit are the names used by old tasks
+	timeLimit := t.float("time_limit")
+	if timeLimit == nil {
+		timeLimit = t.float("timeout")
+	}
+	if timeLimit == nil || *timeLimit <= 0 {
+		p.mp.fail("time_limit", "the task must have a positive time limit")
+		return nil
+	}
+	upd.TimeLimit = timeLimit
+	memoryLimit := t.positiveInt("memory_limit")
+	if memoryLimit == nil {
+		memoryLimit = t.positiveInt("memlimit")
+	}
+	if memoryLimit == nil {
+		p.mp.fail("memory_limit", "the task must have a memory limit (in MiB)")
+		return nil
+	}
+	kb := *memoryLimit * 1024
+	upd.MemoryLimit = &kb
+
+	in, out := "input.txt", "output.txt"
+	if v := t.str("infile"); v != nil {
+		in = *v
+	}
+	if v := t.str("outfile"); v != nil {
+		out = *v
+	}
+	console := in == "" && out == ""
+	upd.ConsoleInput = &console
+	if !console {
+		name := strings.TrimSuffix(in, path.Ext(in))
+		if in == "" {
+			name = strings.TrimSuffix(out, path.Ext(out))
+		}
+		upd.TestName = &name
+		if in != name+".in" || out != name+".out" {
+			p.warnf("The task uses the files %q and %q, they were replaced with %s.in and %s.out", in, out, name, name)
+		}
+	}
+
+	n := t.positiveInt("n_input")
+	if n == nil {
+		p.mp.fail("n_input", "the task must have tests")
+		return nil
+	}
+	p.n = *n
+	return nil
+}
+
+// readGen reads the tests and the subtasks from gen/GEN, in the format used by cmsMake and cmsImportTask
+func (p *cmsParser) readGen(t *manifestTable) error {
+	data, err := p.read("gen/GEN")
+	if err != nil {
+		return nil
+	}
+	gen := &cmsGen{}
+	for i, line := range strings.Split(string(data), "\n") {
+		test, comment := strings.TrimSpace(line), ""
+		if j := strings.IndexByte(test, '#'); j >= 0 {
+			test, comment = strings.TrimSpace(test[:j]), strings.TrimSpace(test[j+1:])
+		}
+		switch {
+		case strings.HasPrefix(comment, "ST:"):
+			score, err := strconv.Atoi(strings.TrimSpace(comment[3:]))
+			if err != nil || score < 0 {
+				return archiveErrorf(path.Join(p.root, "gen/GEN"), i+1, "invalid subtask score %q", strings.TrimSpace(comment[3:]))
+			}
+			gen.subtasks = append(gen.subtasks, [2]int{score, 0})
+		case test != "" || strings.HasPrefix(comment, "COPY:"):
+			gen.args = append(gen.args, test)
+			if test == "" {
+				gen.copied = true
+			}
+			if len(gen.subtasks) > 0 {
+				gen.subtasks[len(gen.subtasks)-1][1]++
+			}
+		}
+	}
+	p.gen = gen
+	return nil
+}
+
+// scoring translates the score type. Like cmsImportTask, the score type from task.yaml is used if it is given,
+// otherwise the subtasks from gen/GEN, scored with GroupMin, and as a last resort the Sum of total_value points
+func (p *cmsParser) scoring(t *manifestTable) error {
+	upd := &p.pkg.Update
+	scoreType := t.str("score_type")
+	params, hasParams := t.get("score_type_parameters")
+
+	var groups []*ManifestSubTask
+	switch {
+	case scoreType != nil && hasParams:
+		switch *scoreType {
+		case "Sum":
+			points, ok := toFloat(params)
+			if !ok || points < 0 {
+				p.mp.fail("score_type_parameters", "expected the points of a test")
+				return nil
+			}
+			upd.ScoringPolicy = kilonova.ScoringSum
+			p.setScores(nil, int(math.Round(points*float64(p.n))))
+			return nil
+		case "GroupMin", "GroupMul", "GroupThreshold":
+			upd.ScoringPolicy = kilonova.ScoringSubtaskMin
+			if *scoreType == "GroupMul" {
+				upd.ScoringPolicy = kilonova.ScoringSubtaskProduct
+			}
+			if *scoreType == "GroupThreshold" {
+				p.warnf("GroupThreshold is scored like GroupMin, the thresholds were ignored")
+			}
+			groups = p.parseGroups(params)
+			if groups == nil {
+				return nil
+			}
+		default:
+			p.mp.fail("score_type", "unknown score type %q", *scoreType)
+			return nil
+		}
+	case p.gen != nil && len(p.gen.subtasks) > 0:
+		upd.ScoringPolicy = kilonova.ScoringSubtaskMin
+		start := 0
+		for _, stk := range p.gen.subtasks {
+			group := &ManifestSubTask{Score: stk[0]}
+			for i := start; i < start+stk[1]; i++ {
+				group.Tests = append(group.Tests, i+1)
+			}
+			start += stk[1]
+			groups = append(groups, group)
+		}
+	default:
+		total := 100.0
+		if v := t.float("total_value"); v != nil {
+			total = *v
+		}
+		upd.ScoringPolicy = kilonova.ScoringSum
+		p.setScores(nil, int(math.Round(total)))
+		return nil
+	}
+
+	if mode := t.str("score_mode"); mode != nil && *mode == "max_subtask" && upd.ScoringPolicy == kilonova.ScoringSubtaskMin {
+		upd.ScoringPolicy = kilonova.ScoringBestSubtask
+	}
+	p.setScores(groups, 0)
+	return nil
+}
+
+// parseGroups parses the parameters of the group score types: a list of [score, tests], where tests is
+// the number of tests of the group, which follow the tests of the previous group, or a regular expression that matches the codenames of the tests
+func (p *cmsParser) parseGroups(params interface{}) []*ManifestSubTask {
+	list, ok := params.([]interface{})
+	if !ok {
+		p.mp.fail("score_type_parameters", "expected a list of [score, tests]")
+		return nil
+	}
+	groups := make([]*ManifestSubTask, 0, len(list))
+	start := 0
+	for i, v := range list {
+		key := fmt.Sprintf("score_type_parameters[%d]", i)
+		g, ok := v.([]interface{})
+		if !ok || len(g) < 2 {
+			p.mp.fail(key, "expected [score, tests]")
+			return nil
+		}
+		score, ok := toFloat(g[0])
+		if !ok || score < 0 {
+			p.mp.fail(key, "invalid score")
+			return nil
+		}
+		group := &ManifestSubTask{Score: int(math.Round(score))}
+		if count, ok := toInt(g[1]); ok {
+			if count < 0 || start+count > p.n {
+				p.mp.fail(key, "the group has more tests than the task")
+				return nil
+			}
+			for id := start; id < start+count; id++ {
+				group.Tests = append(group.Tests, id+1)
+			}
+			start += count
+		} else if expr, ok := g[1].(string); ok {
+			// CMS matches the regular expression at the start of the codename
+			re, err := regexp.Compile("^(?:" + expr + ")")
+			if err != nil {
+				p.mp.fail(key, "invalid regular expression %q", expr)
+				return nil
+			}
+			for id := 0; id < p.n; id++ {
+				if re.MatchString(cmsCodename(id)) {
+					group.Tests = append(group.Tests, id+1)
+				}
+			}
+		} else {
+			p.mp.fail(key, "expected the number of tests or a regular expression")
+			return nil
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// setScores makes the subtasks from the groups and gives every test an equal part of the score of its first group.
+// If there are no groups, total is split between the tests
+func (p *cmsParser) setScores(groups []*ManifestSubTask, total int) {
+	scores := make([]int, p.n)
+	if groups == nil {
+		copy(scores, kilonova.SplitScore(total, p.n))
+	} else {
+		// The tests that aren't in any subtask don't count, so they are worth 0 points
+		scored := make([]bool, p.n)
+		p.pkg.SubTasks = make([]ManifestSubTask, 0, len(groups))
+		for i, group := range groups {
+			group.VisibleID = i + 1
+			group.Key = fmt.Sprintf("score_type_parameters[%d]", i)
+			if len(group.Tests) > 0 {
+				for j, score := range kilonova.SplitScore(group.Score, len(group.Tests)) {
+					if idx := group.Tests[j] - 1; !scored[idx] {
+						scores[idx], scored[idx] = score, true
+					}
+				}
+			}
+			p.pkg.SubTasks = append(p.pkg.SubTasks, *group)
+		}
+	}
+	p.pkg.Tests = make([]PackageTest, p.n)
+	for i := range p.pkg.Tests {
+		p.pkg.Tests[i] = PackageTest{VisibleID: i + 1, Score: scores[i]}
+	}
+}
+
+func (p *cmsParser) tests(t *manifestTable) error {
+	for i := range p.pkg.Tests {
+		in, out := fmt.Sprintf("input/input%d.txt", i), fmt.Sprintf("output/output%d.txt", i)
+		for _, name := range []string{in, out} {
+			if _, ok := p.files[name]; !ok {
+				return archiveErrorf(path.Join(p.root, name), 0, "the test file is not in the task, generate the tests (with cmsMake) before archiving it")
+			}
+		}
+		p.pkg.Tests[i].Input, p.pkg.Tests[i].Answer = p.files[in], p.files[out]
+	}
+	return nil
+}
+
+// tools imports the generator and the validator. The test script is made from gen/GEN if all tests are generated
+func (p *cmsParser) tools(t *manifestTable) error {
+	upd := &p.pkg.Update
+	rel, lang := p.findSource("gen/valida")
+	if rel == "" {
+		rel, lang = p.findSource("gen/validator")
+	}
+	if rel != "" {
+		data, err := p.read(rel)
+		if err != nil {
+			return err
+		}
+		code := string(data)
+		upd.ValidatorCode, upd.ValidatorLang = &code, &lang
+	}
+
+	rel, lang = p.findSource("gen/generatore")
+	if rel == "" {
+		rel, lang = p.findSource("gen/generator")
+	}
+	if rel == "" {
+		return nil
+	}
+	data, err := p.read(rel)
+	if err != nil {
+		return err
+	}
+	code := string(data)
+	upd.GeneratorCode, upd.GeneratorLang = &code, &lang
+
+	if p.gen == nil || p.gen.copied || len(p.gen.args) != p.n {
+		return nil
+	}
+	var sb strings.Builder
+	sb.WriteString("# Imported from gen/GEN\n")
+	writeTest := func(id int) {
+		fmt.Fprintf(&sb, "gen %s > %d.in %d\n", p.gen.args[id-1], id, p.pkg.Tests[id-1].Score)
+	}
+	if p.pkg.SubTasks != nil {
+		for _, stk := range p.pkg.SubTasks {
+			fmt.Fprintf(&sb, "subtask %d\n", stk.Score)
+			for _, id := range stk.Tests {
+				writeTest(id)
+			}
+		}
+	} else {
+		for id := 1; id <= p.n; id++ {
+			writeTest(id)
+		}
+	}
+	script := sb.String()
+	if _, err := kilonova.ParseTestScript(script); err != nil {
+		p.warnf("The test script couldn't be made from gen/GEN: %s", kilonova.ErrorMessage(err))
+		return nil
+	}
+	upd.TestScript = &script
+	return nil
+}
+
+func (p *cmsParser) hasSource(stem string) bool {
+	rel, _ := p.findSource(stem)
+	return rel != ""
+}
+
+// evaluation translates the checker. Tasks without a checker are compared like CMS does, ignoring whitespace
+func (p *cmsParser) evaluation(t *manifestTable) error {
+	upd := &p.pkg.Update
+	for _, stem := range []string{"check/manager", "cor/manager"} {
+		if _, ok := p.files[stem]; ok || p.hasSource(stem) {
+			return archiveErrorf(path.Join(p.root, stem), 0, "communication tasks are not supported")
+		}
+	}
+
+	upd.Type = kilonova.ProblemTypeClassic
+	if v := t.bool("output_only"); v != nil && *v {
+		upd.Type = kilonova.ProblemTypeOutputOnly
+	}
+	for _, stem := range []string{"check/checker", "cor/correttore"} {
+		rel, lang := p.findSource(stem)
+		if rel == "" {
+			if _, ok := p.files[stem]; ok {
+				return archiveErrorf(path.Join(p.root, stem), 0, "the checker is compiled, its source (like %s.cpp) must be in the task too", stem)
+			}
+			continue
+		}
+		data, err := p.read(rel)
+		if err != nil {
+			return err
+		}
+		code := string(data)
+		if upd.Type == kilonova.ProblemTypeClassic {
+			upd.Type = kilonova.ProblemTypeCustomChecker
+		}
+		upd.HelperCode, upd.HelperCodeLang = &code, &lang
+		upd.CheckerProtocol = kilonova.CheckerProtocolCMS
+		return nil
+	}
+	upd.Comparator = kilonova.ComparatorDiff
+	return nil
+}
+
+// sol imports the graders and the headers from sol/ as grader attachments and the other sources as solutions
+func (p *cmsParser) sol(t *manifestTable) error {
+	var headers []string
+	headerLang := "cpp"
+	for _, rel := range p.dirFiles("sol") {
+		name, ext := path.Base(rel), path.Ext(rel)
+		if ext == ".h" || ext == ".hpp" {
+			headers = append(headers, rel)
+			continue
+		}
+		lang, ok := languageByExtension(ext)
+		if !ok {
+			p.warnf("%s was not imported, its language is not supported", rel)
+			continue
+		}
+		data, err := p.read(rel)
+		if err != nil {
+			return err
+		}
+		if strings.TrimSuffix(name, ext) == "grader" {
+			if lang == "c" && headerLang == "cpp" && !p.hasFile("sol/grader.cpp") {
+				headerLang = "c"
+			}
+			p.addAttachment(&kilonova.Attachment{Name: name, Data: data, GraderLang: lang})
+			continue
+		}
+		p.pkg.Solutions = append(p.pkg.Solutions, &kilonova.ProblemSolution{Name: name, Code: string(data), Language: lang, Expected: "AC"})
+	}
+	for _, rel := range headers {
+		data, err := p.read(rel)
+		if err != nil {
+			return err
+		}
+		p.addAttachment(&kilonova.Attachment{Name: path.Base(rel), Data: data, GraderLang: headerLang})
+	}
+	return nil
+}
+
+func (p *cmsParser) hasFile(rel string) bool {
+	_, ok := p.files[rel]
+	return ok
+}
+
+// addAttachment adds an attachment. If there is another one with the same name, it is made visible instead
+func (p *cmsParser) addAttachment(att *kilonova.Attachment) {
+	for _, other := range p.pkg.Attachments {
+		if other.Name == att.Name {
+			other.Visible = other.Visible || att.Visible
+			return
+		}
+	}
+	p.pkg.Attachments = append(p.pkg.Attachments, att)
+}
+
+func (p *cmsParser) attachments(t *manifestTable) error {
+	for _, rel := range p.dirFiles("att") {
+		data, err := p.read(rel)
+		if err != nil {
+			return err
+		}
+		p.addAttachment(&kilonova.Attachment{Name: path.Base(rel), Data: data, Visible: true})
+	}
+	return nil
+}
+
+// statement imports statement/statement.md as the description and the PDF statement as an attachment
+func (p *cmsParser) statement(t *manifestTable) error {
+	if data, err := p.read("statement/statement.md"); err == nil {
+		desc := string(data)
+		p.pkg.Update.Description = &desc
+	}
+	for _, rel := range []string{"statement/statement.pdf", "testo/testo.pdf"} {
+		data, err := p.read(rel)
+		if err != nil {
+			continue
+		}
+		p.addAttachment(&kilonova.Attachment{Name: "statement.pdf", Data: data, Visible: true})
+		if p.pkg.Update.Description == nil {
+			p.warnf("The task has only a PDF statement, which was added as an attachment")
+		}
+		return nil
+	}
+	if p.pkg.Update.Description == nil {
+		p.warnf("The task has no statement")
+	}
+	return nil
+}
+
+// cmsLayout is the way the tests and the scoring of a problem are written in a CMS task
+type cmsLayout struct {
+	// tests holds the tests in the order of the task
+	tests []*kilonova.Test
+	// groups holds the score and the number of tests of the groups of consecutive tests, if the task is scored by groups that don't overlap
+	groups [][2]int
+	// name is the short name of the task
+	name string
+	yaml string
+}
+
+// newCMSLayout translates the scoring of the problem to a CMS score type.
+// Subtasks become groups, ICPC scoring is a group with all tests and the tests with different scores are groups of one test.
+// If the subtasks don't overlap, the tests are reordered so every subtask is a range of tests, otherwise the groups are regular expressions
+func newCMSLayout(pb *kilonova.Problem, tests []*kilonova.Test, subTasks []*kilonova.SubTask) (*cmsLayout, error) {
+	tests = append([]*kilonova.Test(nil), tests...)
+	sort.Slice(tests, func(i, j int) bool { return tests[i].VisibleID < tests[j].VisibleID })
+	if len(tests) == 0 {
+		return nil, &kilonova.Error{Code: kilonova.EINVALID, Message: "The problem has no tests"}
+	}
+	byID := make(map[int]*kilonova.Test)
+	for _, test := range tests {
+		byID[test.ID] = test
+	}
+
+	type group struct {
+		score int
+		tests []*kilonova.Test
+	}
+	var groups []group
+	scoreType := "GroupMin"
+	switch policy := pb.ScoringPolicy; {
+	case (policy == "" || policy == kilonova.ScoringSubtaskMin || policy == kilonova.ScoringSubtaskProduct || policy == kilonova.ScoringBestSubtask) && len(subTasks) > 0:
+		if policy == kilonova.ScoringSubtaskProduct {
+			scoreType = "GroupMul"
+		}
+		subTasks = append([]*kilonova.SubTask(nil), subTasks...)
+		sort.Slice(subTasks, func(i, j int) bool { return subTasks[i].VisibleID < subTasks[j].VisibleID })
+		for _, stk := range subTasks {
+			g := group{score: stk.Score}
+			for _, id := range stk.Tests {
+				if test, ok := byID[id]; ok {
+					g.tests = append(g.tests, test)
+				}
+			}
+			sort.Slice(g.tests, func(i, j int) bool { return g.tests[i].VisibleID < g.tests[j].VisibleID })
+			groups = append(groups, g)
+		}
+	case policy == kilonova.ScoringICPC:
+		total := 0
+		if len(subTasks) > 0 {
+			for _, stk := range subTasks {
+				total += stk.Score
+			}
+		} else {
+			for _, test := range tests {
+				total += test.Score
+			}
+		}
+		groups = []group{{score: total, tests: tests}}
+	default:
+		for _, test := range tests {
+			if test.Score != tests[0].Score {
+				for _, test := range tests {
+					groups = append(groups, group{score: test.Score, tests: []*kilonova.Test{test}})
+				}
+				break
+			}
+		}
+		if groups == nil {
+			scoreType = "Sum"
+		}
+	}
+
+	layout := &cmsLayout{tests: tests}
+	var params string
+	if groups != nil {
+		seen := make(map[int]bool)
+		disjoint := true
+		for _, g := range groups {
+			for _, test := range g.tests {
+				if seen[test.ID] {
+					disjoint = false
+				}
+				seen[test.ID] = true
+			}
+		}
+
+		var parts []string
+		if disjoint {
+			layout.tests = make([]*kilonova.Test, 0, len(tests))
+			for _, g := range groups {
+				layout.tests = append(layout.tests, g.tests...)
+				layout.groups = append(layout.groups, [2]int{g.score, len(g.tests)})
+				parts = append(parts, fmt.Sprintf("[%d, %d]", g.score, len(g.tests)))
+			}
+			for _, test := range tests {
+				if !seen[test.ID] {
+					layout.tests = append(layout.tests, test)
+				}
+			}
+		} else {
+			index := make(map[int]int)
+			for i, test := range tests {
+				index[test.ID] = i
+			}
+			for _, g := range groups {
+				names := make([]string, 0, len(g.tests))
+				for _, test := range g.tests {
+					names = append(names, cmsCodename(index[test.ID]))
+				}
+				parts = append(parts, fmt.Sprintf("[%d, %s]", g.score, yamlString("("+strings.Join(names, "|")+")$")))
+			}
+		}
+		params = "[" + strings.Join(parts, ", ") + "]"
+	} else {
+		params = strconv.Itoa(tests[0].Score)
+	}
+
+	name := pb.TestName
+	if name == "" {
+		name = fmt.Sprintf("problem%d", pb.ID)
+	}
+	in, out := name+".in", name+".out"
+	if pb.ConsoleInput {
+		in, out = "", ""
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "name: %s\n", yamlString(name))
+	fmt.Fprintf(&sb, "title: %s\n", yamlString(pb.Name))
+	fmt.Fprintf(&sb, "time_limit: %s\n", strconv.FormatFloat(pb.TimeLimit, 'f', -1, 64))
+	fmt.Fprintf(&sb, "memory_limit: %d\n", (pb.MemoryLimit+1023)/1024)
+	fmt.Fprintf(&sb, "n_input: %d\n", len(tests))
+	fmt.Fprintf(&sb, "infile: %s\n", yamlString(in))
+	fmt.Fprintf(&sb, "outfile: %s\n", yamlString(out))
+	fmt.Fprintf(&sb, "score_type: %s\n", yamlString(scoreType))
+	fmt.Fprintf(&sb, "score_type_parameters: %s\n", params)
+	if pb.ScoringPolicy == kilonova.ScoringBestSubtask {
+		sb.WriteString("score_mode: \"max_subtask\"\n")
+	}
+	if pb.Type == kilonova.ProblemTypeOutputOnly {
+		sb.WriteString("output_only: true\n")
+	}
+	layout.name = name
+	layout.yaml = sb.String()
+	return layout, nil
+}
+
+// ExportCMSTask writes the problem as a zip with a CMS task in the italy_yaml layout, which can be imported with cmsImportTask.
+// CMS needs the compiled checker, so compileChecker is called if the problem has one. It can be nil if checkers can't be compiled.
+// The statement is written as statement/statement.md, while the PDF statement is the attachment named statement.pdf, if there is one.
+// Only the solutions that are expected to get AC are written in sol/, since cmsImportTask doesn't import them anyway.
+// The test with index i of the task is the i-th test of the problem, in the order given by newCMSLayout
+func (kn *Kilonova) ExportCMSTask(ctx context.Context, pb *kilonova.Problem, w io.Writer, compileChecker func(context.Context, *kilonova.Problem) ([]byte, error)) error {
+	hasChecker := false
+	switch pb.Type {
+	case kilonova.ProblemTypeInteractive:
+		return &kilonova.Error{Code: kilonova.EINVALID, Message: "Interactive problems can't be exported to CMS"}
+	case kilonova.ProblemTypeCustomChecker:
+		hasChecker = true
+	case kilonova.ProblemTypeOutputOnly:
+		hasChecker = pb.HelperCode != ""
+	}
+	if hasChecker && pb.CheckerProtocol != kilonova.CheckerProtocolCMS {
+		return &kilonova.Error{Code: kilonova.EINVALID, Message: "Only the checkers that use the CMS protocol can be exported to CMS"}
+	}
+	if !hasChecker && pb.Comparator != "" && pb.Comparator != kilonova.ComparatorDiff && pb.Comparator != kilonova.ComparatorTokens {
+		return &kilonova.Error{Code: kilonova.EINVALID, Message: fmt.Sprintf("The %q comparator can't be exported to CMS, which compares the outputs ignoring whitespace. Use a checker instead", pb.Comparator)}
+	}
+
+	tests, err := kn.tserv.Tests(ctx, pb.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	subTasks, err := kn.stkserv.SubTasks(ctx, pb.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	layout, err := newCMSLayout(pb, tests, subTasks)
+	if err != nil {
+		return err
+	}
+	atts, err := kn.aserv.Attachments(ctx, true, kilonova.AttachmentFilter{ProblemID: &pb.ID})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	sols, err := kn.solserv.ProblemSolutions(ctx, pb.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	var checker []byte
+	if hasChecker {
+		if compileChecker == nil {
+			return &kilonova.Error{Code: kilonova.EINTERNAL, Message: "The checker can't be compiled right now"}
+		}
+		if checker, err = compileChecker(ctx, pb); err != nil {
+			return err
+		}
+	}
+
+	zw := zip.NewWriter(w)
+	create := func(name string, data []byte, executable bool) error {
+		hdr := &zip.FileHeader{Name: path.Join(layout.name, name), Method: zip.Deflate}
+		hdr.SetMode(0644)
+		if executable {
+			hdr.SetMode(0755)
+		}
+		f, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(data)
+		return err
+	}
+	copyTest := func(name string, open func(int) (io.ReadCloser, error), id int) error {
+		rd, err := open(id)
+		if err != nil {
+			return err
+		}
+		defer rd.Close()
+		f, err := zw.Create(path.Join(layout.name, name))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(f, rd)
+		return err
+	}
+
+	if err := create(CMSTaskFile, []byte(layout.yaml), false); err != nil {
+		return err
+	}
+	if pb.Description != "" {
+		if err := create("statement/statement.md", []byte(pb.Description), false); err != nil {
+			return err
+		}
+	}
+	for i, test := range layout.tests {
+		if err := copyTest(fmt.Sprintf("input/input%d.txt", i), kn.DM.TestInput, test.ID); err != nil {
+			return err
+		}
+		if err := copyTest(fmt.Sprintf("output/output%d.txt", i), kn.DM.TestOutput, test.ID); err != nil {
+			return err
+		}
+	}
+
+	if hasChecker {
+		if err := create("check/checker", checker, true); err != nil {
+			return err
+		}
+		if err := create("check/checker"+langExtension(pb.HelperCodeLang), []byte(pb.HelperCode), false); err != nil {
+			return err
+		}
+	}
+
+	written := make(map[string]bool)
+	for _, att := range atts {
+		name := path.Join("att", att.Name)
+		switch {
+		case att.GraderLang != "":
+			name = path.Join("sol", att.Name)
+		case att.Name == "statement.pdf":
+			if err := create("statement/statement.pdf", att.Data, false); err != nil {
+				return err
+			}
+			continue
+		case !att.Visible:
+			continue
+		}
+		written[name] = true
+		if err := create(name, att.Data, false); err != nil {
+			return err
+		}
+	}
+	for _, sol := range sols {
+		exp, err := kilonova.ParseExpectedOutcome(sol.Expected)
+		if err != nil || !exp.AllAccepted() {
+			continue
+		}
+		name := sol.Name
+		if name == "" {
+			name = fmt.Sprintf("solution%d", sol.ID)
+		}
+		if ext := langExtension(sol.Language); path.Ext(name) != ext {
+			name += ext
+		}
+		name = path.Join("sol", path.Base(name))
+		if written[name] {
+			continue
+		}
+		written[name] = true
+		if err := create(name, []byte(sol.Code), false); err != nil {
+			return err
+		}
+	}
+
+	if pb.GeneratorCode != "" {
+		if err := create("gen/generatore"+langExtension(pb.GeneratorLang), []byte(pb.GeneratorCode), false); err != nil {
+			return err
+		}
+	}
+	if pb.ValidatorCode != "" {
+		if err := create("gen/valida"+langExtension(pb.ValidatorLang), []byte(pb.ValidatorCode), false); err != nil {
+			return err
+		}
+	}
+	if layout.groups != nil {
+		if err := create("gen/GEN", []byte(cmsGenFile(pb, layout)), false); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// cmsGenFile writes gen/GEN, with the subtasks of the layout. The tests created by the test script have the arguments of the generator,
+// while the other ones are copied from input/
+func cmsGenFile(pb *kilonova.Problem, layout *cmsLayout) string {
+	args := make(map[int]string)
+	if script, err := kilonova.ParseTestScript(pb.TestScript); err == nil && pb.TestScript != "" {
+		for _, test := range script.Tests {
+			args[test.VisibleID] = strings.Join(test.Args, " ")
+		}
+	}
+	var sb strings.Builder
+	i := 0
+	for _, g := range layout.groups {
+		fmt.Fprintf(&sb, "# ST: %d\n", g[0])
+		for end := i + g[1]; i < end; i++ {
+			if a, ok := args[layout.tests[i].VisibleID]; ok {
+				sb.WriteString(a + "\n")
+			} else {
+				fmt.Fprintf(&sb, "# COPY: input/input%d.txt\n", i)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// langExtension returns the first extension of the language
+func langExtension(lang string) string {
+	if l, ok := config.Languages[lang]; ok && len(l.Extensions) > 0 {
+		return l.Extensions[0]
+	}
+	return ""
+}
@@ -0,0 +1,172 @@
+package logic
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/KiloProjects/kilonova"
+)
+
+const testTaskYAML = `# A + B
+name: aplusb
+title: "A + B"
+time_limit: 1.5
+memory_limit: 256
+n_input: 4
+infile: ""
+outfile: ""
+public_testcases: 0
+`
+
+func TestParseCMSTask(t *testing.T) {
+	files := map[string]string{
+		"task.yaml":               testTaskYAML,
+		"gen/GEN":                 "# ST: 40\n1 2\n2 2 # small\n\n# ST: 60\n100 3\n1000 4\n",
+		"gen/generatore.cpp":      "int main() {}",
+		"check/checker.cpp":       "int main() {}",
+		"check/checker":           "binary",
+		"sol/grader.cpp":          "int main() {}",
+		"sol/aplusb.h":            "int sum(int a, int b);",
+		"sol/soluzione.cpp":       "int sum(int a, int b) { return a + b; }",
+		"att/aplusb.h":            "int sum(int a, int b);",
+		"statement/statement.pdf": "pdf",
+	}
+	for i := 0; i < 4; i++ {
+		files[fmt.Sprintf("input/input%d.txt", i)] = "1 2\n"
+		files[fmt.Sprintf("output/output%d.txt", i)] = "3\n"
+	}
+	pkg, err := ParseCMSTask(polygonZip(t, files))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upd := pkg.Update
+	if *upd.Name != "A + B" || *upd.TimeLimit != 1.5 || *upd.MemoryLimit != 262144 || !*upd.ConsoleInput {
+		t.Errorf("wrong limits %+v", upd)
+	}
+	if upd.Type != kilonova.ProblemTypeCustomChecker || upd.CheckerProtocol != kilonova.CheckerProtocolCMS || *upd.HelperCodeLang != "cpp" {
+		t.Errorf("wrong checker %+v", upd)
+	}
+	if upd.ScoringPolicy != kilonova.ScoringSubtaskMin || len(pkg.SubTasks) != 2 || !reflect.DeepEqual(pkg.SubTasks[1].Tests, []int{3, 4}) {
+		t.Errorf("wrong subtasks %+v", pkg.SubTasks)
+	}
+	if len(pkg.Tests) != 4 || pkg.Tests[0].Score != 20 || pkg.Tests[3].Score != 30 || pkg.Tests[3].Input.Name != "a-plus-b/input/input3.txt" {
+		t.Errorf("wrong tests %+v", pkg.Tests)
+	}
+	if upd.TestScript == nil || !strings.Contains(*upd.TestScript, "subtask 60\ngen 100 3 > 3.in 30\n") {
+		t.Errorf("wrong test script %v", upd.TestScript)
+	} else if _, err := kilonova.ParseTestScript(*upd.TestScript); err != nil {
+		t.Errorf("invalid test script: %v", err)
+	}
+	if len(pkg.Solutions) != 1 || pkg.Solutions[0].Name != "soluzione.cpp" || pkg.Solutions[0].Expected != "AC" {
+		t.Errorf("wrong solutions %+v", pkg.Solutions)
+	}
+	var atts []string
+	for _, att := range pkg.Attachments {
+		atts = append(atts, fmt.Sprintf("%s %s %v", att.Name, att.GraderLang, att.Visible))
+	}
+	if want := []string{"grader.cpp cpp false", "aplusb.h cpp true", "statement.pdf  true"}; !reflect.DeepEqual(atts, want) {
+		t.Errorf("wrong attachments %q", atts)
+	}
+	if !strings.Contains(strings.Join(pkg.Warnings, "\n"), "PDF") {
+		t.Errorf("the warnings don't mention the PDF statement: %q", pkg.Warnings)
+	}
+
+	// The score type from task.yaml takes precedence over gen/GEN
+	files["task.yaml"] = testTaskYAML + "score_type: GroupMul\nscore_type_parameters: [[10, \"00[0-2]\"], [90, \"00[23]\"]]\n"
+	pkg, err = ParseCMSTask(polygonZip(t, files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Update.ScoringPolicy != kilonova.ScoringSubtaskProduct || !reflect.DeepEqual(pkg.SubTasks[0].Tests, []int{1, 2, 3}) || !reflect.DeepEqual(pkg.SubTasks[1].Tests, []int{3, 4}) {
+		t.Errorf("wrong subtasks %+v", pkg.SubTasks)
+	}
+
+	delete(files, "output/output2.txt")
+	if _, err := ParseCMSTask(polygonZip(t, files)); err == nil || !strings.Contains(err.Error(), "a-plus-b/output/output2.txt") {
+		t.Errorf("wanted an error about the missing test, got %v", err)
+	}
+	files["output/output2.txt"] = "3\n"
+	files["task.yaml"] = testTaskYAML + "score_type: Sum\nscore_type_parameters:\n  nested: 1\n"
+	if _, err := ParseCMSTask(polygonZip(t, files)); err == nil || !strings.Contains(err.Error(), "task.yaml:11: score_type_parameters") {
+		t.Errorf("wanted an error about the score type parameters, got %v", err)
+	}
+	files["task.yaml"] = testTaskYAML + "score_type: [Sum\n"
+	if _, err := ParseCMSTask(polygonZip(t, files)); err == nil || !strings.Contains(err.Error(), "task.yaml:") {
+		t.Errorf("wanted an error about the invalid YAML, got %v", err)
+	}
+}
+
+func TestCMSLayout(t *testing.T) {
+	pb := &kilonova.Problem{ID: 1, Name: "A + B", TestName: "aplusb", TimeLimit: 0.5, MemoryLimit: 65537, ScoringPolicy: kilonova.ScoringBestSubtask}
+	tests := []*kilonova.Test{
+		{ID: 10, VisibleID: 1, Score: 10},
+		{ID: 11, VisibleID: 2, Score: 20},
+		{ID: 12, VisibleID: 3, Score: 30},
+		{ID: 13, VisibleID: 4, Score: 40},
+	}
+	subTasks := []*kilonova.SubTask{
+		{VisibleID: 1, Score: 30, Tests: []int{11, 13}},
+		{VisibleID: 2, Score: 70, Tests: []int{10, 12}},
+	}
+
+	// The layout must be imported back to the same problem, with the tests reordered by subtask
+	layout, err := newCMSLayout(pb, tests, subTasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []int
+	for _, test := range layout.tests {
+		order = append(order, test.VisibleID)
+	}
+	if !reflect.DeepEqual(order, []int{2, 4, 1, 3}) || !reflect.DeepEqual(layout.groups, [][2]int{{30, 2}, {70, 2}}) {
+		t.Errorf("wrong layout %v %v", order, layout.groups)
+	}
+	files := map[string]string{"task.yaml": layout.yaml}
+	for i := 0; i < 4; i++ {
+		files[fmt.Sprintf("input/input%d.txt", i)] = ""
+		files[fmt.Sprintf("output/output%d.txt", i)] = ""
+	}
+	pkg, err := ParseCMSTask(polygonZip(t, files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	upd := pkg.Update
+	if *upd.Name != "A + B" || *upd.TestName != "aplusb" || *upd.ConsoleInput || *upd.TimeLimit != 0.5 || *upd.MemoryLimit != 65*1024 || upd.ScoringPolicy != kilonova.ScoringBestSubtask {
+		t.Errorf("wrong limits %+v", upd)
+	}
+	if len(pkg.SubTasks) != 2 || !reflect.DeepEqual(pkg.SubTasks[0].Tests, []int{1, 2}) || pkg.SubTasks[1].Score != 70 {
+		t.Errorf("wrong subtasks %+v", pkg.SubTasks)
+	}
+
+	// Overlapping subtasks are written as regular expressions
+	subTasks[1].Tests = append(subTasks[1].Tests, 11)
+	layout, err = newCMSLayout(pb, tests, subTasks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layout.groups != nil || !strings.Contains(layout.yaml, `score_type_parameters: [[30, "(001|003)$"], [70, "(000|001|002)$"]]`) {
+		t.Errorf("wrong layout:\n%s", layout.yaml)
+	}
+	files["task.yaml"] = layout.yaml
+	if pkg, err = ParseCMSTask(polygonZip(t, files)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pkg.SubTasks[1].Tests, []int{1, 2, 3}) {
+		t.Errorf("wrong subtasks %+v", pkg.SubTasks)
+	}
+
+	// Tests with equal scores are scored with Sum
+	pb.ScoringPolicy = kilonova.ScoringSum
+	for _, test := range tests {
+		test.Score = 25
+	}
+	if layout, err = newCMSLayout(pb, tests, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(layout.yaml, "score_type: \"Sum\"\nscore_type_parameters: 25\n") {
+		t.Errorf("wrong layout:\n%s", layout.yaml)
+	}
+}
@@ -1,6 +1,8 @@
 package logic
 
 import (
+	"sync"
+
 	"github.com/KiloProjects/kilonova"
 )
 
@@ -9,11 +11,19 @@ type Kilonova struct {
 	Debug  bool
 	mailer kilonova.Mailer
 
-	userv kilonova.UserService
-	tserv kilonova.TestService
+	userv   kilonova.UserService
+	pserv   kilonova.ProblemService
+	tserv   kilonova.TestService
+	stkserv kilonova.SubTaskService
+	aserv   kilonova.AttachmentService
+	solserv kilonova.ProblemSolutionService
 
 	Sess  kilonova.Sessioner
 	Verif kilonova.Verificationer
+
+	// testLocks holds the locks of the problems whose tests are being replaced, by problem ID
+	testMu    sync.Mutex
+	testLocks map[int]*testLock
 }
 
 func New(db kilonova.TypeServicer, dm kilonova.DataStore, debug bool) (*Kilonova, error) {
@@ -22,5 +32,21 @@ func New(db kilonova.TypeServicer, dm kilonova.DataStore, debug bool) (*Kilonova
 		return nil, err
 	}
 
-	return &Kilonova{dm, debug, mailer, db.UserService(), db.TestService(), db.SessionService(), db.VerificationService()}, nil
+	return &Kilonova{
+		DM:     dm,
+		Debug:  debug,
+		mailer: mailer,
+
+		userv:   db.UserService(),
+		pserv:   db.ProblemService(),
+		tserv:   db.TestService(),
+		stkserv: db.SubTaskService(),
+		aserv:   db.AttachmentService(),
+		solserv: db.ProblemSolutionService(),
+
+		Sess:  db.SessionService(),
+		Verif: db.VerificationService(),
+
+		testLocks: make(map[int]*testLock),
+	}, nil
 }
@@ -0,0 +1,666 @@
+package logic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/internal/config"
+)
+
+// The manifest of a test archive must have one of these names
+const (
+	ManifestTOML = "problem.toml"
+	ManifestJSON = "problem.json"
+)
+
+// ArchiveManifest holds the problem settings from the manifest of a test archive, which are applied along with the tests.
+// The files are paths in the archive, relative to the manifest
+type ArchiveManifest struct {
+	// Name is the path of the manifest in the archive
+	Name string
+
+	Update kilonova.ProblemUpdate
+
+	Statement   *ManifestFile
+	Checker     *ManifestFile
+	Attachments []ManifestAttachment
+	// SubTasks is nil if the manifest doesn't have subtasks, in which case the subtasks of the problem are kept
+	SubTasks []ManifestSubTask
+
+	// Data holds the contents of the files referenced by the manifest, by path. It is filled while the archive is processed
+	Data map[string][]byte
+
+	lines map[string]int
+}
+
+// ManifestFile is a file referenced by the manifest
+type ManifestFile struct {
+	Path string
+	// Key is the manifest key that references the file, used in errors
+	Key string
+}
+
+type ManifestAttachment struct {
+	ManifestFile
+	Name       string
+	Visible    bool
+	GraderLang string
+}
+
+type ManifestSubTask struct {
+	VisibleID int
+	Score     int
+	// Tests holds the visible IDs of the tests
+	Tests []int
+	// Key is the manifest key of the subtask, used in errors
+	Key string
+}
+
+// IsManifest reports if the archive file is a manifest
+func IsManifest(name string) bool {
+	base := path.Base(name)
+	return base == ManifestTOML || base == ManifestJSON
+}
+
+// ParseManifest parses the manifest with the specified path in the archive.
+// The errors name the manifest and the line of the offending key, if it is known
+func ParseManifest(name string, data []byte) (*ArchiveManifest, error) {
+	p := &manifestParser{file: path.Base(name)}
+	var root map[string]interface{}
+	if p.file == ManifestJSON {
+		if err := json.Unmarshal(data, &root); err != nil {
+			switch err := err.(type) {
+			case *json.SyntaxError:
+				return nil, p.errorAt(offsetLine(data, err.Offset), "%v", err)
+			case *json.UnmarshalTypeError:
+				return nil, p.errorAt(offsetLine(data, err.Offset), "the manifest must be a JSON object")
+			}
+			return nil, p.errorAt(0, "%v", err)
+		}
+		p.lines = jsonLines(data)
+	} else {
+		// The errors of the TOML parser already contain the line
+		if _, err := toml.Decode(string(data), &root); err != nil {
+			return nil, p.errorAt(0, "%v", err)
+		}
+		p.lines = tomlLines(string(data))
+	}
+
+	m := &ArchiveManifest{Name: name, lines: p.lines}
+	t := p.table("", root)
+	dir := path.Dir(name)
+	file := func(key string, rel *string) *ManifestFile {
+		if rel == nil {
+			return nil
+		}
+		if *rel == "" || path.IsAbs(*rel) || strings.HasPrefix(path.Clean(*rel), "..") {
+			p.fail(key, "invalid file path %q, it must be relative to the manifest", *rel)
+			return nil
+		}
+		return &ManifestFile{Path: path.Join(dir, *rel), Key: key}
+	}
+
+	if v := t.str("type"); v != nil {
+		m.Update.Type = kilonova.ProblemType(*v)
+		switch m.Update.Type {
+		case kilonova.ProblemTypeClassic, kilonova.ProblemTypeCustomChecker, kilonova.ProblemTypeInteractive, kilonova.ProblemTypeOutputOnly:
+		default:
+			p.fail("type", "unknown problem type %q", *v)
+		}
+	}
+	if v := t.float("time_limit"); v != nil && *v <= 0 {
+		p.fail("time_limit", "the time limit must be positive")
+	} else {
+		m.Update.TimeLimit = v
+	}
+	m.Update.MemoryLimit = t.positiveInt("memory_limit")
+	m.Update.StackLimit = t.positiveInt("stack_limit")
+	m.Update.SourceSize = t.positiveInt("source_size")
+	if v := t.int("output_size_limit"); v != nil && *v < 0 {
+		p.fail("output_size_limit", "the output size limit can't be negative")
+	} else {
+		m.Update.OutputSizeLimit = v
+	}
+	m.Update.DefaultPoints = t.int("default_points")
+	m.Update.ConsoleInput = t.bool("console_input")
+	m.Update.ShortCircuit = t.bool("short_circuit")
+	if v := t.str("test_name"); v != nil {
+		if *v == "" || strings.ContainsAny(*v, "/\\") {
+			p.fail("test_name", "invalid test name %q", *v)
+		}
+		m.Update.TestName = v
+	}
+	if v := t.str("scoring_policy"); v != nil {
+		m.Update.ScoringPolicy = kilonova.ScoringPolicy(*v)
+		if !m.Update.ScoringPolicy.Valid() {
+			p.fail("scoring_policy", "unknown scoring policy %q", *v)
+		}
+	}
+	if v := t.str("comparator"); v != nil {
+		m.Update.Comparator = kilonova.Comparator(*v)
+		if !m.Update.Comparator.Valid() {
+			p.fail("comparator", "unknown comparator %q", *v)
+		}
+	}
+	if v := t.float("comparator_epsilon"); v != nil && *v < 0 {
+		p.fail("comparator_epsilon", "the epsilon can't be negative")
+	} else {
+		m.Update.ComparatorEpsilon = v
+	}
+	m.Statement = file("statement", t.str("statement"))
+
+	if ct := t.subTable("checker"); ct != nil {
+		m.Checker = file("checker.file", ct.str("file"))
+		if m.Checker == nil && p.err == nil {
+			p.fail("checker", "the checker must have a file")
+		}
+		lang := "cpp"
+		if v := ct.str("language"); v != nil {
+			lang = *v
+			p.checkLanguage("checker.language", lang)
+		}
+		m.Update.HelperCodeLang = &lang
+		if v := ct.str("protocol"); v != nil {
+			m.Update.CheckerProtocol = kilonova.CheckerProtocol(*v)
+			if !m.Update.CheckerProtocol.Valid() {
+				p.fail("checker.protocol", "unknown checker protocol %q", *v)
+			}
+		}
+		ct.checkUnknown()
+	}
+
+	for i, at := range t.tables("attachments") {
+		key := fmt.Sprintf("attachments[%d]", i)
+		f := file(key+".file", at.str("file"))
+		if f == nil {
+			if p.err == nil {
+				p.fail(key, "the attachment must have a file")
+			}
+			continue
+		}
+		att := ManifestAttachment{ManifestFile: *f, Name: path.Base(f.Path)}
+		if v := at.str("name"); v != nil {
+			att.Name = *v
+		}
+		if v := at.bool("visible"); v != nil {
+			att.Visible = *v
+		}
+		if v := at.str("grader_lang"); v != nil {
+			att.GraderLang = *v
+			if p.checkLanguage(key+".grader_lang", *v) && !config.Languages[*v].IsCompiled {
+				p.fail(key+".grader_lang", "grader files are supported only for compiled languages")
+			}
+			if att.Name != path.Base(att.Name) || strings.HasPrefix(att.Name, ".") {
+				p.fail(key+".name", "invalid grader file name %q", att.Name)
+			}
+		}
+		at.checkUnknown()
+		m.Attachments = append(m.Attachments, att)
+	}
+
+	if _, ok := root["subtasks"]; ok {
+		m.SubTasks = []ManifestSubTask{}
+	}
+	seenSubTasks := make(map[int]bool)
+	for i, st := range t.tables("subtasks") {
+		key := fmt.Sprintf("subtasks[%d]", i)
+		stk := ManifestSubTask{VisibleID: i + 1, Key: key}
+		if v := st.positiveInt("id"); v != nil {
+			stk.VisibleID = *v
+		}
+		if seenSubTasks[stk.VisibleID] {
+			p.fail(key, "there is more than one subtask with the ID %d", stk.VisibleID)
+		}
+		seenSubTasks[stk.VisibleID] = true
+		if v := st.int("score"); v != nil && *v >= 0 {
+			stk.Score = *v
+		} else if p.err == nil {
+			p.fail(key+".score", "the subtask must have a score, which can't be negative")
+		}
+		stk.Tests = st.intList("tests")
+		if len(stk.Tests) == 0 && p.err == nil {
+			p.fail(key+".tests", "the subtask must have tests")
+		}
+		st.checkUnknown()
+		m.SubTasks = append(m.SubTasks, stk)
+	}
+
+	t.checkUnknown()
+	if p.err != nil {
+		return nil, p.err
+	}
+	return m, nil
+}
+
+// Files returns all files referenced by the manifest
+func (m *ArchiveManifest) Files() []*ManifestFile {
+	var files []*ManifestFile
+	if m.Statement != nil {
+		files = append(files, m.Statement)
+	}
+	if m.Checker != nil {
+		files = append(files, m.Checker)
+	}
+	for i := range m.Attachments {
+		files = append(files, &m.Attachments[i].ManifestFile)
+	}
+	return files
+}
+
+// References reports if the archive file is referenced by the manifest
+func (m *ArchiveManifest) References(name string) bool {
+	for _, f := range m.Files() {
+		if f.Path == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Errorf returns an error about the manifest key, which names the manifest and the line of the key
+func (m *ArchiveManifest) Errorf(key string, format string, args ...interface{}) error {
+	p := &manifestParser{file: path.Base(m.Name), lines: m.lines}
+	p.fail(key, format, args...)
+	return p.err
+}
+
+// Validate checks the references of the manifest to the files and tests of the archive.
+// The type of the problem is used if the manifest doesn't change it
+func (m *ArchiveManifest) Validate(pbType kilonova.ProblemType, tests map[int]archiveTest) error {
+	for _, f := range m.Files() {
+		if _, ok := m.Data[f.Path]; !ok {
+			return m.Errorf(f.Key, "the file %q is not in the archive", f.Path)
+		}
+	}
+	if m.Update.Type != kilonova.ProblemTypeNone {
+		pbType = m.Update.Type
+	}
+	if m.Checker != nil && pbType == kilonova.ProblemTypeClassic {
+		return m.Errorf("checker", "classic problems can't have a checker, set the type of the problem")
+	}
+	names := make(map[string]string)
+	for _, att := range m.Attachments {
+		if prev, ok := names[att.Name]; ok {
+			return m.Errorf(att.Key, "the attachment name %q is also used by %s", att.Name, prev)
+		}
+		names[att.Name] = att.Key
+	}
+	for _, stk := range m.SubTasks {
+		for _, id := range stk.Tests {
+			if _, ok := tests[id]; !ok {
+				return m.Errorf(stk.Key+".tests", "test %d is not in the archive", id)
+			}
+		}
+	}
+	return nil
+}
+
+// manifestParser reads the values from the decoded manifest. After the first error, it stops checking the values
+type manifestParser struct {
+	file  string
+	lines map[string]int
+	err   error
+}
+
+func (p *manifestParser) errorAt(line int, format string, args ...interface{}) error {
+	prefix := p.file
+	if line > 0 {
+		prefix = fmt.Sprintf("%s:%d", p.file, line)
+	}
+	return &kilonova.Error{Code: kilonova.EINVALID, Message: prefix + ": " + fmt.Sprintf(format, args...)}
+}
+
+// fail records an error about the key, if there is no other error
+func (p *manifestParser) fail(key string, format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	// Use the line of the closest parent if the key doesn't appear in the manifest
+	line, k := 0, key
+	for k != "" {
+		if l, ok := p.lines[k]; ok {
+			line = l
+			break
+		}
+		k = k[:strings.LastIndexAny(k, ".[")+1]
+		k = strings.TrimRight(k, ".[")
+	}
+	p.err = p.errorAt(line, "%s: %s", key, fmt.Sprintf(format, args...))
+}
+
+func (p *manifestParser) checkLanguage(key, lang string) bool {
+	if l, ok := config.Languages[lang]; !ok || l.Disabled {
+		p.fail(key, "unknown language %q", lang)
+		return false
+	}
+	return true
+}
+
+func (p *manifestParser) table(path string, m map[string]interface{}) *manifestTable {
+	return &manifestTable{p: p, path: path, m: m, used: make(map[string]bool)}
+}
+
+// manifestTable is a table (object) of the manifest. It remembers the keys that were read, to report the unknown ones
+type manifestTable struct {
+	p    *manifestParser
+	path string
+	m    map[string]interface{}
+	used map[string]bool
+}
+
+func (t *manifestTable) key(k string) string {
+	if t.path == "" {
+		return k
+	}
+	return t.path + "." + k
+}
+
+func (t *manifestTable) get(k string) (interface{}, bool) {
+	t.used[k] = true
+	v, ok := t.m[k]
+	return v, ok && t.p.err == nil
+}
+
+func (t *manifestTable) checkUnknown() {
+	keys := make([]string, 0, len(t.m))
+	for k := range t.m {
+		if !t.used[k] {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) > 0 {
+		sort.Strings(keys)
+		t.p.fail(t.key(keys[0]), "unknown key")
+	}
+}
+
+func (t *manifestTable) str(k string) *string {
+	v, ok := t.get(k)
+	if !ok {
+		return nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		t.p.fail(t.key(k), "expected a string")
+		return nil
+	}
+	return &s
+}
+
+func (t *manifestTable) bool(k string) *bool {
+	v, ok := t.get(k)
+	if !ok {
+		return nil
+	}
+	b, ok := v.(bool)
+	if !ok {
+		t.p.fail(t.key(k), "expected true or false")
+		return nil
+	}
+	return &b
+}
+
+func (t *manifestTable) float(k string) *float64 {
+	v, ok := t.get(k)
+	if !ok {
+		return nil
+	}
+	f, ok := toFloat(v)
+	if !ok {
+		t.p.fail(t.key(k), "expected a number")
+		return nil
+	}
+	return &f
+}
+
+func (t *manifestTable) int(k string) *int {
+	v, ok := t.get(k)
+	if !ok {
+		return nil
+	}
+	i, ok := toInt(v)
+	if !ok {
+		t.p.fail(t.key(k), "expected an integer")
+		return nil
+	}
+	return &i
+}
+
+func (t *manifestTable) positiveInt(k string) *int {
+	v := t.int(k)
+	if v != nil && *v <= 0 {
+		t.p.fail(t.key(k), "the value must be positive")
+		return nil
+	}
+	return v
+}
+
+// intList reads an array of integers or a string of comma separated integers and ranges, like "1-5,8"
+func (t *manifestTable) intList(k string) []int {
+	v, ok := t.get(k)
+	if !ok {
+		return nil
+	}
+	var ids []int
+	switch v := v.(type) {
+	case []interface{}:
+		for _, x := range v {
+			id, ok := toInt(x)
+			if !ok {
+				t.p.fail(t.key(k), "expected a list of integers")
+				return nil
+			}
+			ids = append(ids, id)
+		}
+	case string:
+		for _, part := range strings.Split(v, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			bounds := strings.SplitN(part, "-", 2)
+			first, err1 := strconv.Atoi(strings.TrimSpace(bounds[0]))
+			last, err2 := first, error(nil)
+			if len(bounds) == 2 {
+				last, err2 = strconv.Atoi(strings.TrimSpace(bounds[1]))
+			}
+			if err1 != nil || err2 != nil || last < first {
+				t.p.fail(t.key(k), "invalid range %q", part)
+				return nil
+			}
+			for id := first; id <= last; id++ {
+				ids = append(ids, id)
+			}
+		}
+	default:
+		t.p.fail(t.key(k), "expected a list of integers or a string like \"1-5,8\"")
+		return nil
+	}
+	return ids
+}
+
+func (t *manifestTable) subTable(k string) *manifestTable {
+	v, ok := t.get(k)
+	if !ok {
+		return nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		t.p.fail(t.key(k), "expected a table")
+		return nil
+	}
+	return t.p.table(t.key(k), m)
+}
+
+func (t *manifestTable) tables(k string) []*manifestTable {
+	v, ok := t.get(k)
+	if !ok {
+		return nil
+	}
+	var maps []map[string]interface{}
+	switch v := v.(type) {
+	case []map[string]interface{}:
+		maps = v
+	case []interface{}:
+		for _, x := range v {
+			m, ok := x.(map[string]interface{})
+			if !ok {
+				t.p.fail(t.key(k), "expected a list of tables")
+				return nil
+			}
+			maps = append(maps, m)
+		}
+	default:
+		t.p.fail(t.key(k), "expected a list of tables")
+		return nil
+	}
+	tables := make([]*manifestTable, 0, len(maps))
+	for i, m := range maps {
+		tables = append(tables, t.p.table(fmt.Sprintf("%s[%d]", t.key(k), i), m))
+	}
+	return tables
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func toInt(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case int64:
+		return int(v), true
+	case float64:
+		// JSON numbers are decoded as floats
+		if v == math.Trunc(v) && math.Abs(v) < 1<<31 {
+			return int(v), true
+		}
+	}
+	return 0, false
+}
+
+func offsetLine(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return 1 + bytes.Count(data[:offset], []byte("\n"))
+}
+
+// tomlLines returns the line of every key and table of the TOML document. Arrays of tables are indexed like "subtasks[1]"
+func tomlLines(data string) map[string]int {
+	lines := make(map[string]int)
+	counts := make(map[string]int)
+	table := ""
+	// multiline is the delimiter of the multi-line string that is not closed yet
+	multiline := ""
+	for i, line := range strings.Split(data, "\n") {
+		num := i + 1
+		trimmed := strings.TrimSpace(line)
+		if multiline != "" {
+			if strings.Contains(trimmed, multiline) {
+				multiline = ""
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(trimmed, "[["):
+			end := strings.Index(trimmed, "]]")
+			if end < 0 {
+				continue
+			}
+			name := strings.TrimSpace(trimmed[2:end])
+			table = fmt.Sprintf("%s[%d]", name, counts[name])
+			counts[name]++
+			lines[table] = num
+		case strings.HasPrefix(trimmed, "["):
+			end := strings.Index(trimmed, "]")
+			if end < 0 {
+				continue
+			}
+			table = strings.TrimSpace(trimmed[1:end])
+			lines[table] = num
+		case strings.Contains(trimmed, "=") && !strings.HasPrefix(trimmed, "#"):
+			eq := strings.Index(trimmed, "=")
+			key := strings.Trim(strings.TrimSpace(trimmed[:eq]), `"'`)
+			if table != "" {
+				key = table + "." + key
+			}
+			if _, ok := lines[key]; !ok {
+				lines[key] = num
+			}
+			value := trimmed[eq+1:]
+			for _, delim := range []string{`"""`, "'''"} {
+				if strings.Count(value, delim) == 1 {
+					multiline = delim
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// jsonLines returns the line of every key of the JSON document, which must be valid. Array elements are indexed like "subtasks[1]"
+func jsonLines(data []byte) map[string]int {
+	lines := make(map[string]int)
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// lineAt returns the line of the token after the offset, since the offset is right after the previous token
+	lineAt := func(offset int64) int {
+		for offset < int64(len(data)) && strings.ContainsRune(" \t\r\n:,", rune(data[offset])) {
+			offset++
+		}
+		return offsetLine(data, offset)
+	}
+	var walk func(key string) error
+	walk = func(key string) error {
+		offset := dec.InputOffset()
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if _, ok := lines[key]; !ok && key != "" {
+			lines[key] = lineAt(offset)
+		}
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				offset := dec.InputOffset()
+				k, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				child := fmt.Sprint(k)
+				if key != "" {
+					child = key + "." + child
+				}
+				lines[child] = lineAt(offset)
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err := walk(fmt.Sprintf("%s[%d]", key, i)); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+		}
+		return err
+	}
+	walk("")
+	return lines
+}
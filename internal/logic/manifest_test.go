@@ -0,0 +1,126 @@
+package logic
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/internal/config"
+)
+
+func init() {
+	config.Languages = map[string]config.Language{"cpp": {IsCompiled: true, Extensions: []string{".cpp"}}, "python": {Extensions: []string{".py"}}}
+}
+
+func TestParseManifest(t *testing.T) {
+	toml := `type = "custom_checker"
+time_limit = 1
+memory_limit = 262144
+statement = "statement.md"
+
+[checker]
+file = "checker.cpp"
+protocol = "testlib"
+
+[[attachments]]
+file = "files/grader.cpp"
+grader_lang = "cpp"
+
+[[subtasks]]
+score = 40
+tests = [1, 2]
+
+[[subtasks]]
+score = 60
+tests = "3-5,7"
+`
+	json := `{
+	"type": "custom_checker",
+	"time_limit": 1,
+	"memory_limit": 262144,
+	"statement": "statement.md",
+	"checker": {"file": "checker.cpp", "protocol": "testlib"},
+	"attachments": [{"file": "files/grader.cpp", "grader_lang": "cpp"}],
+	"subtasks": [
+		{"score": 40, "tests": [1, 2]},
+		{"score": 60, "tests": "3-5,7"}
+	]
+}`
+	for name, data := range map[string]string{"pb/problem.toml": toml, "pb/problem.json": json} {
+		m, err := ParseManifest(name, []byte(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if m.Update.Type != kilonova.ProblemTypeCustomChecker || *m.Update.TimeLimit != 1 || *m.Update.MemoryLimit != 262144 {
+			t.Errorf("%s: wrong problem update %+v", name, m.Update)
+		}
+		if m.Statement.Path != "pb/statement.md" || m.Checker.Path != "pb/checker.cpp" || *m.Update.HelperCodeLang != "cpp" || m.Update.CheckerProtocol != kilonova.CheckerProtocolTestlib {
+			t.Errorf("%s: wrong files %+v %+v", name, m.Statement, m.Checker)
+		}
+		if len(m.Attachments) != 1 || m.Attachments[0].Name != "grader.cpp" || m.Attachments[0].Path != "pb/files/grader.cpp" {
+			t.Errorf("%s: wrong attachments %+v", name, m.Attachments)
+		}
+		if len(m.SubTasks) != 2 || m.SubTasks[1].VisibleID != 2 || m.SubTasks[1].Score != 60 || len(m.SubTasks[1].Tests) != 4 {
+			t.Errorf("%s: wrong subtasks %+v", name, m.SubTasks)
+		}
+		if !m.References("pb/checker.cpp") || m.References("pb/1-a.in") {
+			t.Errorf("%s: wrong references", name)
+		}
+
+		m.Data = map[string][]byte{"pb/statement.md": nil, "pb/checker.cpp": nil, "pb/files/grader.cpp": nil}
+		err = m.Validate(kilonova.ProblemTypeClassic, map[int]archiveTest{1: {}, 2: {}, 3: {}, 4: {}, 5: {}})
+		wantLine := map[string]string{"pb/problem.toml": "problem.toml:20:", "pb/problem.json": "problem.json:10:"}[name]
+		if err == nil || !strings.Contains(err.Error(), wantLine+" subtasks[1].tests: test 7 is not in the archive") {
+			t.Errorf("%s: wrong validation error %v", name, err)
+		}
+	}
+
+	var bad = []struct {
+		name string
+		data string
+		err  string
+	}{
+		{"problem.toml", "type = \"classic\"\ntime_limit = \"1\"", "problem.toml:2: time_limit: expected a number"},
+		{"problem.toml", "type = \"classic\"\n\n[checker]\nlanguage = \"cobol\"\nfile = \"a.cpp\"", "problem.toml:4: checker.language: unknown language"},
+		{"problem.toml", "[[subtasks]]\nscore = 10\ntests = [1]\n[[subtasks]]\nscore = 10\ntests = \"3-1\"", "problem.toml:6: subtasks[1].tests: invalid range"},
+		{"problem.toml", "[[subtasks]]\ntests = [1]", "problem.toml:1: subtasks[0].score: the subtask must have a score"},
+		{"problem.toml", "description = \"\"\"\na = b\n\"\"\"\nmemory = 5", "problem.toml:1: description: unknown key"},
+		{"problem.toml", "statement = \"../x.md\"", "problem.toml:1: statement: invalid file path"},
+		{"problem.toml", "type = ", "Near line 1"},
+		{"problem.json", "{\n\"type\": \"classic\",\n\"memory_limit\": 1.5\n}", "problem.json:3: memory_limit: expected an integer"},
+		{"problem.json", "{\n\"type\": \"classic\",\n\"attachments\": [\n{\"name\": \"x\"}\n]}", "problem.json:4: attachments[0]: the attachment must have a file"},
+		{"problem.json", "{\n\"type\": \"classic\"\n\"x\": 1}", "problem.json:3:"},
+	}
+	for _, test := range bad {
+		_, err := ParseManifest(test.name, []byte(test.data))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: wanted error containing %q, got %v", test.data, test.err, err)
+		}
+	}
+}
+
+func TestScoreFromSubTasks(t *testing.T) {
+	ctx := NewArchiveCtx()
+	for i := 1; i <= 5; i++ {
+		ctx.tests[i] = archiveTest{}
+	}
+	m := &ArchiveManifest{SubTasks: []ManifestSubTask{
+		{VisibleID: 1, Score: 10, Tests: []int{1, 2, 3}},
+		{VisibleID: 2, Score: 90, Tests: []int{3, 4, 5}},
+	}}
+	if err := ctx.scoreFromSubTasks(m); err != nil {
+		t.Fatal(err)
+	}
+	// The remainder goes to the first tests and test 3 keeps the score of the first subtask
+	want := map[int]int{1: 4, 2: 3, 3: 3, 4: 30, 5: 30}
+	for id, score := range want {
+		if ctx.tests[id].Score != score {
+			t.Errorf("test %d got %d points, wanted %d", id, ctx.tests[id].Score, score)
+		}
+	}
+
+	ctx.tests[6] = archiveTest{}
+	if err := ctx.scoreFromSubTasks(m); err == nil {
+		t.Error("test 6 isn't in any subtask, but there was no error")
+	}
+}
@@ -0,0 +1,148 @@
+package logic
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/KiloProjects/kilonova"
+)
+
+// ProblemPackage is a problem translated from the package of another judge, like a Polygon package or a CMS task.
+// It is applied with ImportPackage
+type ProblemPackage struct {
+	Update kilonova.ProblemUpdate
+
+	// Tests is nil if the package doesn't have all the test files, in which case the tests must be created with the imported generator
+	Tests []PackageTest
+	// SubTasks replace the subtasks of the problem, if the tests are imported
+	SubTasks    []ManifestSubTask
+	Attachments []*kilonova.Attachment
+	Solutions   []*kilonova.ProblemSolution
+
+	// Warnings lists the parts of the package that couldn't be translated
+	Warnings []string
+}
+
+type PackageTest struct {
+	VisibleID int
+	Score     int
+	Input     *zip.File
+	Answer    *zip.File
+}
+
+// packageFiles holds the files of a package, by their path relative to the root of the package
+type packageFiles struct {
+	// root is the directory of the package in the archive
+	root  string
+	files map[string]*zip.File
+}
+
+// findPackageFile returns the file of the archive with the specified name that is closest to the root, since packages are often archived with their directory
+func findPackageFile(ar *zip.Reader, name string) *zip.File {
+	var found *zip.File
+	for _, file := range ar.File {
+		if file.FileInfo().IsDir() || path.Base(file.Name) != name {
+			continue
+		}
+		if found == nil || strings.Count(file.Name, "/") < strings.Count(found.Name, "/") {
+			found = file
+		}
+	}
+	return found
+}
+
+func newPackageFiles(ar *zip.Reader, root string) *packageFiles {
+	f := &packageFiles{root: root, files: make(map[string]*zip.File)}
+	for _, file := range ar.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		if root == "." {
+			f.files[file.Name] = file
+		} else if strings.HasPrefix(file.Name, root+"/") {
+			f.files[strings.TrimPrefix(file.Name, root+"/")] = file
+		}
+	}
+	return f
+}
+
+// read returns the contents of the file with the path relative to the root of the package
+func (f *packageFiles) read(rel string) ([]byte, error) {
+	file, ok := f.files[path.Clean(rel)]
+	if !ok {
+		return nil, archiveErrorf(path.Join(f.root, rel), 0, "the file is not in the package")
+	}
+	return readZipFile(file)
+}
+
+// ImportPackage applies a package to the problem. The package must be fully translated beforehand, so nothing is changed if it is invalid.
+// The tests and subtasks of the problem are replaced (unless the package has no test files), while the attachments
+// and the solutions with the same name as the ones in the package are updated
+func (kn *Kilonova) ImportPackage(ctx context.Context, pb *kilonova.Problem, pkg *ProblemPackage) error {
+	for _, att := range pkg.Attachments {
+		att.ProblemID = pb.ID
+		if err := kn.saveAttachment(ctx, att); err != nil {
+			return err
+		}
+	}
+
+	upd := pkg.Update
+	if upd.Description != nil {
+		// The images of the statement are served as attachments
+		desc := *upd.Description
+		for _, att := range pkg.Attachments {
+			desc = strings.ReplaceAll(desc, "]("+att.Name+")", fmt.Sprintf("](/attachments/%d)", att.ID))
+		}
+		upd.Description = &desc
+	}
+	if err := kn.pserv.UpdateProblem(ctx, pb.ID, upd); err != nil && !errors.Is(err, kilonova.ErrNoUpdates) {
+		return err
+	}
+
+	if pkg.Tests != nil {
+		tests := make([]NewTest, 0, len(pkg.Tests))
+		for _, pt := range pkg.Tests {
+			tests = append(tests, NewTest{VisibleID: pt.VisibleID, Score: pt.Score, Input: pt.Input.Open, Output: pt.Answer.Open})
+		}
+		// The subtasks are replaced even if the package has none
+		subTasks := pkg.SubTasks
+		if subTasks == nil {
+			subTasks = []ManifestSubTask{}
+		}
+		if err := kn.ReplaceTests(ctx, pb.ID, tests, subTasks); err != nil {
+			return err
+		}
+	}
+
+	existing, err := kn.solserv.ProblemSolutions(ctx, pb.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	for _, sol := range pkg.Solutions {
+		sol.ProblemID = pb.ID
+		updated := false
+		for _, ex := range existing {
+			if ex.Name != sol.Name {
+				continue
+			}
+			status := kilonova.SolutionUnchecked
+			if err := kn.solserv.UpdateProblemSolution(ctx, ex.ID, kilonova.ProblemSolutionUpdate{Code: &sol.Code, Language: &sol.Language, Expected: &sol.Expected, CheckStatus: &status}); err != nil {
+				return err
+			}
+			sol.ID, updated = ex.ID, true
+			break
+		}
+		if updated {
+			continue
+		}
+		if err := kn.solserv.CreateProblemSolution(ctx, sol); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,669 @@
+package logic
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/internal/config"
+)
+
+// PolygonDescriptor is the file that describes a Polygon package
+const PolygonDescriptor = "problem.xml"
+
+// polygonXML holds the used parts of problem.xml
+type polygonXML struct {
+	Names []struct {
+		Language string `xml:"language,attr"`
+		Value    string `xml:"value,attr"`
+	} `xml:"names>name"`
+	Statements []struct {
+		Language string `xml:"language,attr"`
+		Path     string `xml:"path,attr"`
+		Type     string `xml:"type,attr"`
+	} `xml:"statements>statement"`
+	Judging struct {
+		InputFile  string           `xml:"input-file,attr"`
+		OutputFile string           `xml:"output-file,attr"`
+		Testsets   []polygonTestset `xml:"testset"`
+	} `xml:"judging"`
+	Resources   []polygonResource `xml:"files>resources>file"`
+	Executables []polygonSource   `xml:"files>executables>executable>source"`
+	Checker     *struct {
+		Name   string        `xml:"name,attr"`
+		Source polygonSource `xml:"source"`
+	} `xml:"assets>checker"`
+	Interactor *struct {
+		Source polygonSource `xml:"source"`
+	} `xml:"assets>interactor"`
+	Validators []polygonSource `xml:"assets>validators>validator>source"`
+	Solutions  []struct {
+		Tag    string        `xml:"tag,attr"`
+		Source polygonSource `xml:"source"`
+	} `xml:"assets>solutions>solution"`
+}
+
+type polygonSource struct {
+	Path string `xml:"path,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type polygonResource struct {
+	polygonSource
+	Assets []struct {
+		Name string `xml:"name,attr"`
+	} `xml:"assets>asset"`
+}
+
+type polygonTestset struct {
+	Name          string `xml:"name,attr"`
+	TimeLimit     int    `xml:"time-limit"`
+	MemoryLimit   int    `xml:"memory-limit"`
+	InputPattern  string `xml:"input-path-pattern"`
+	AnswerPattern string `xml:"answer-path-pattern"`
+	Tests         []struct {
+		Method string `xml:"method,attr"`
+		Cmd    string `xml:"cmd,attr"`
+		Group  string `xml:"group,attr"`
+		Points string `xml:"points,attr"`
+		Sample bool   `xml:"sample,attr"`
+	} `xml:"tests>test"`
+	Groups []struct {
+		Name         string `xml:"name,attr"`
+		Points       string `xml:"points,attr"`
+		PointsPolicy string `xml:"points-policy,attr"`
+		Dependencies []struct {
+			Group string `xml:"group,attr"`
+		} `xml:"dependencies>dependency"`
+	} `xml:"groups>group"`
+}
+
+// polygonComparators are the standard testlib checkers that have an equivalent comparator
+var polygonComparators = map[string]struct {
+	comparator kilonova.Comparator
+	epsilon    float64
+}{
+	"std::wcmp.cpp":   {kilonova.ComparatorTokens, 0},
+	"std::ncmp.cpp":   {kilonova.ComparatorTokens, 0},
+	"std::hcmp.cpp":   {kilonova.ComparatorTokens, 0},
+	"std::lcmp.cpp":   {kilonova.ComparatorDiff, 0},
+	"std::fcmp.cpp":   {kilonova.ComparatorDiff, 0},
+	"std::rcmp.cpp":   {kilonova.ComparatorFloat, 1.5e-6},
+	"std::rcmp4.cpp":  {kilonova.ComparatorFloat, 1e-4},
+	"std::rcmp6.cpp":  {kilonova.ComparatorFloat, 1e-6},
+	"std::rcmp9.cpp":  {kilonova.ComparatorFloat, 1e-9},
+	"std::dcmp.cpp":   {kilonova.ComparatorFloat, 1e-6},
+	"std::yesno.cpp":  {kilonova.ComparatorCaseInsensitive, 0},
+	"std::nyesno.cpp": {kilonova.ComparatorCaseInsensitive, 0},
+	"std::uncmp.cpp":  {kilonova.ComparatorUnorderedTokens, 0},
+}
+
+// polygonOutcomes maps the solution tags to expected outcomes. The tags that are missing can't be expressed as an expected outcome
+var polygonOutcomes = map[string]string{
+	"main":                  "AC",
+	"accepted":              "AC",
+	"wrong-answer":          "WA",
+	"presentation-error":    "WA",
+	"time-limit-exceeded":   "TLE",
+	"memory-limit-exceeded": "MLE",
+	"failed":                "RE",
+}
+
+// polygonLanguage returns the language of a Polygon source type, like cpp.g++17 or python.3. Headers are considered C/C++ sources
+func polygonLanguage(typ string) (string, bool) {
+	var lang string
+	switch {
+	case strings.HasPrefix(typ, "cpp."), strings.HasPrefix(typ, "h.g++"):
+		lang = "cpp"
+	case strings.HasPrefix(typ, "c."), strings.HasPrefix(typ, "h.gcc"):
+		lang = "c"
+	case strings.HasPrefix(typ, "java"):
+		lang = "java"
+	case strings.HasPrefix(typ, "python."):
+		lang = "python"
+	case typ == "go" || strings.HasPrefix(typ, "go."):
+		lang = "golang"
+	case strings.HasPrefix(typ, "haskell"):
+		lang = "haskell"
+	}
+	if l, ok := config.Languages[lang]; !ok || l.Disabled {
+		return "", false
+	}
+	return lang, true
+}
+
+var includeRe = regexp.MustCompile(`(?m)^[ \t]*#[ \t]*include[ \t]*"([^"]+)".*$`)
+
+type polygonParser struct {
+	*packageFiles
+	// resources holds the paths of the resource files (like testlib.h), by name
+	resources map[string]string
+
+	xml     polygonXML
+	testset *polygonTestset
+	// scores holds the score of every test
+	scores []int
+	// missing is the first test file that isn't in the package
+	missing string
+
+	pkg *ProblemPackage
+}
+
+// ParsePolygonPackage translates a Polygon package (the zip downloaded from the Packages page).
+// problem.xml can be at the root of the archive or in a directory.
+//
+// The standard checkers are replaced by comparators and the other checkers are imported as testlib checkers.
+// The test groups become subtasks: complete-group groups are scored like a subtask, while every test of an each-test group
+// becomes a subtask of its own. If the tests have no points, the problem is scored ICPC style.
+// The first validator is imported, as is the generator, if all generated tests use the same one.
+// The files included by the checker, the interactor, the generator and the validator (like testlib.h) are inlined
+func ParsePolygonPackage(ar *zip.Reader) (*ProblemPackage, error) {
+	desc := findPackageFile(ar, PolygonDescriptor)
+	if desc == nil {
+		return nil, &kilonova.Error{Code: kilonova.EINVALID, Message: "The archive is not a Polygon package, it doesn't have a problem.xml file"}
+	}
+
+	p := &polygonParser{
+		packageFiles: newPackageFiles(ar, path.Dir(desc.Name)),
+		resources:    make(map[string]string),
+		pkg:          &ProblemPackage{},
+	}
+
+	data, err := readZipFile(desc)
+	if err != nil {
+		return nil, err
+	}
+	if err := xml.Unmarshal(data, &p.xml); err != nil {
+		if serr, ok := err.(*xml.SyntaxError); ok {
+			return nil, archiveErrorf(desc.Name, serr.Line, "%s", serr.Msg)
+		}
+		return nil, archiveErrorf(desc.Name, 0, "%v", err)
+	}
+	for _, res := range p.xml.Resources {
+		p.resources[path.Base(res.Path)] = path.Clean(res.Path)
+	}
+
+	for _, step := range []func() error{p.limits, p.evaluation, p.tests, p.generator, p.statement, p.graders, p.solutions} {
+		if err := step(); err != nil {
+			return nil, err
+		}
+	}
+	return p.pkg, nil
+}
+
+func (p *polygonParser) warnf(format string, args ...interface{}) {
+	p.pkg.Warnings = append(p.pkg.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (p *polygonParser) errorf(format string, args ...interface{}) error {
+	return archiveErrorf(path.Join(p.root, PolygonDescriptor), 0, format, args...)
+}
+
+// source returns the code and the language of a source file.
+// The files included by C/C++ sources are inlined if inline is set, since the sources are compiled alone
+func (p *polygonParser) source(src polygonSource, inline bool) (string, string, error) {
+	lang, ok := polygonLanguage(src.Type)
+	if !ok {
+		return "", "", archiveErrorf(path.Join(p.root, src.Path), 0, "the language %q is not supported", src.Type)
+	}
+	data, err := p.read(src.Path)
+	if err != nil {
+		return "", "", err
+	}
+	code := string(data)
+	if inline && (lang == "c" || lang == "cpp") {
+		code = p.inlineIncludes(src.Path, code, make(map[string]bool))
+	}
+	return code, lang, nil
+}
+
+func (p *polygonParser) inlineIncludes(name, code string, seen map[string]bool) string {
+	return includeRe.ReplaceAllStringFunc(code, func(line string) string {
+		inc := includeRe.FindStringSubmatch(line)[1]
+		rel, ok := p.resources[path.Base(inc)]
+		if !ok {
+			p.warnf("%s includes %s, which is not in the package", name, inc)
+			return line
+		}
+		if seen[rel] {
+			return ""
+		}
+		seen[rel] = true
+		data, err := p.read(rel)
+		if err != nil {
+			p.warnf("%s includes %s, which couldn't be read", name, inc)
+			return line
+		}
+		return p.inlineIncludes(name, string(data), seen)
+	})
+}
+
+// limits translates the name, the limits and the input/output files
+func (p *polygonParser) limits() error {
+	upd := &p.pkg.Update
+
+	if len(p.xml.Names) > 0 {
+		langs := make([]string, 0, len(p.xml.Names))
+		for _, name := range p.xml.Names {
+			langs = append(langs, name.Language)
+		}
+		lang := pickLanguage(langs)
+		for _, name := range p.xml.Names {
+			if name.Language == lang && name.Value != "" {
+				v := name.Value
+				upd.Name = &v
+			}
+		}
+	}
+
+	var skipped []string
+	for i := range p.xml.Judging.Testsets {
+		ts := &p.xml.Judging.Testsets[i]
+		if p.testset == nil && ts.Name == "tests" {
+			p.testset = ts
+		} else {
+			skipped = append(skipped, ts.Name)
+		}
+	}
+	if p.testset == nil {
+		if len(p.xml.Judging.Testsets) == 0 {
+			return p.errorf("the package has no testset")
+		}
+		p.testset = &p.xml.Judging.Testsets[0]
+		skipped = skipped[1:]
+	}
+	if len(skipped) > 0 {
+		p.warnf("Only the testset %q was imported, the testsets %s were ignored", p.testset.Name, strings.Join(skipped, ", "))
+	}
+
+	ts := p.testset
+	if ts.TimeLimit <= 0 || ts.MemoryLimit <= 0 {
+		return p.errorf("the testset %q has no time limit or memory limit", ts.Name)
+	}
+	timeLimit := float64(ts.TimeLimit) / 1000
+	memoryLimit := ts.MemoryLimit / 1024
+	upd.TimeLimit = &timeLimit
+	upd.MemoryLimit = &memoryLimit
+
+	in, out := p.xml.Judging.InputFile, p.xml.Judging.OutputFile
+	console := in == "" && out == ""
+	upd.ConsoleInput = &console
+	if !console {
+		name := strings.TrimSuffix(in, path.Ext(in))
+		if in == "" {
+			name = strings.TrimSuffix(out, path.Ext(out))
+		}
+		upd.TestName = &name
+		if in != name+".in" || out != name+".out" {
+			p.warnf("The problem uses the files %q and %q, they were replaced with %s.in and %s.out", in, out, name, name)
+		}
+	}
+	return nil
+}
+
+// evaluation translates the checker or the interactor
+func (p *polygonParser) evaluation() error {
+	upd := &p.pkg.Update
+
+	if inter := p.xml.Interactor; inter != nil {
+		code, lang, err := p.source(inter.Source, true)
+		if err != nil {
+			return err
+		}
+		upd.Type = kilonova.ProblemTypeInteractive
+		upd.HelperCode = &code
+		upd.HelperCodeLang = &lang
+		p.warnf("The interactor was imported, but it must be adapted: it must print the score (0-100) and a message to stderr, instead of writing an output for the checker")
+		if p.xml.Checker != nil && !strings.HasPrefix(p.xml.Checker.Name, "std::") {
+			p.warnf("The checker was not imported, since the interactor scores the interactive problems")
+		}
+		return nil
+	}
+
+	chk := p.xml.Checker
+	if chk == nil {
+		upd.Type = kilonova.ProblemTypeClassic
+		upd.Comparator = kilonova.ComparatorTokens
+		p.warnf("The package has no checker, the outputs are compared token by token")
+		return nil
+	}
+	if cmp, ok := polygonComparators[chk.Name]; ok {
+		upd.Type = kilonova.ProblemTypeClassic
+		upd.Comparator = cmp.comparator
+		if cmp.epsilon > 0 {
+			eps := cmp.epsilon
+			upd.ComparatorEpsilon = &eps
+		}
+		return nil
+	}
+
+	code, lang, err := p.source(chk.Source, true)
+	if err != nil {
+		return err
+	}
+	upd.Type = kilonova.ProblemTypeCustomChecker
+	upd.HelperCode = &code
+	upd.HelperCodeLang = &lang
+	upd.CheckerProtocol = kilonova.CheckerProtocolTestlib
+	return nil
+}
+
+// tests finds the test files and translates the points and the groups of the tests
+func (p *polygonParser) tests() error {
+	ts := p.testset
+	n := len(ts.Tests)
+	if n == 0 {
+		return p.errorf("the testset %q has no tests", ts.Name)
+	}
+
+	hasPoints, rounded := false, false
+	parsePoints := func(s, what string) (int, error) {
+		if s == "" {
+			return 0, nil
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil || v < 0 {
+			return 0, p.errorf("%s has invalid points %q", what, s)
+		}
+		hasPoints = true
+		if v != math.Round(v) {
+			rounded = true
+		}
+		return int(math.Round(v)), nil
+	}
+
+	points := make([]int, n)
+	for i, test := range ts.Tests {
+		v, err := parsePoints(test.Points, fmt.Sprintf("test %d", i+1))
+		if err != nil {
+			return err
+		}
+		points[i] = v
+	}
+	completeGroups := make(map[string]bool)
+	groupPoints := make(map[string]int)
+	var deps []string
+	for _, group := range ts.Groups {
+		v, err := parsePoints(group.Points, fmt.Sprintf("group %q", group.Name))
+		if err != nil {
+			return err
+		}
+		if group.Points != "" {
+			groupPoints[group.Name] = v
+		}
+		if group.PointsPolicy == "complete-group" {
+			completeGroups[group.Name] = true
+		}
+		for _, dep := range group.Dependencies {
+			deps = append(deps, fmt.Sprintf("%s on %s", group.Name, dep.Group))
+		}
+	}
+	if rounded {
+		p.warnf("Some points are not integers, they were rounded")
+	}
+	if len(deps) > 0 {
+		p.warnf("The dependencies between groups are not supported, they were ignored (group %s)", strings.Join(deps, ", group "))
+	}
+
+	p.scores = make([]int, n)
+	useSubTasks := len(completeGroups) > 0
+	switch {
+	case !hasPoints:
+		p.pkg.Update.ScoringPolicy = kilonova.ScoringICPC
+		copy(p.scores, kilonova.SplitScore(100, n))
+	case !useSubTasks:
+		p.pkg.Update.ScoringPolicy = kilonova.ScoringSum
+		copy(p.scores, points)
+	default:
+		p.pkg.Update.ScoringPolicy = kilonova.ScoringSubtaskMin
+		p.pkg.SubTasks = make([]ManifestSubTask, 0)
+		groupIdx := make(map[string]int)
+		for i, test := range ts.Tests {
+			if !completeGroups[test.Group] {
+				p.pkg.SubTasks = append(p.pkg.SubTasks, ManifestSubTask{Score: points[i], Tests: []int{i + 1}, Key: fmt.Sprintf("test %d", i+1)})
+				continue
+			}
+			idx, ok := groupIdx[test.Group]
+			if !ok {
+				idx = len(p.pkg.SubTasks)
+				groupIdx[test.Group] = idx
+				p.pkg.SubTasks = append(p.pkg.SubTasks, ManifestSubTask{Key: fmt.Sprintf("group %s", test.Group)})
+			}
+			p.pkg.SubTasks[idx].Tests = append(p.pkg.SubTasks[idx].Tests, i+1)
+			if _, ok := groupPoints[test.Group]; !ok {
+				p.pkg.SubTasks[idx].Score += points[i]
+			}
+		}
+		for name, idx := range groupIdx {
+			if v, ok := groupPoints[name]; ok {
+				p.pkg.SubTasks[idx].Score = v
+			}
+		}
+		for i := range p.pkg.SubTasks {
+			stk := &p.pkg.SubTasks[i]
+			stk.VisibleID = i + 1
+			for j, score := range kilonova.SplitScore(stk.Score, len(stk.Tests)) {
+				p.scores[stk.Tests[j]-1] = score
+			}
+		}
+	}
+
+	if p.pkg.Update.ScoringPolicy != kilonova.ScoringICPC {
+		total := 0
+		if p.pkg.SubTasks != nil {
+			for _, stk := range p.pkg.SubTasks {
+				total += stk.Score
+			}
+		} else {
+			for _, v := range p.scores {
+				total += v
+			}
+		}
+		if total != 100 {
+			p.warnf("The points of the tests add up to %d, not 100", total)
+		}
+	}
+
+	inPattern, ansPattern := ts.InputPattern, ts.AnswerPattern
+	if inPattern == "" {
+		inPattern = "tests/%02d"
+	}
+	if ansPattern == "" {
+		ansPattern = inPattern + ".a"
+	}
+	tests := make([]PackageTest, 0, n)
+	for i := range ts.Tests {
+		test := PackageTest{VisibleID: i + 1, Score: p.scores[i]}
+		inName, ansName := fmt.Sprintf(inPattern, i+1), fmt.Sprintf(ansPattern, i+1)
+		test.Input, test.Answer = p.files[path.Clean(inName)], p.files[path.Clean(ansName)]
+		if test.Input == nil || test.Answer == nil {
+			p.missing = inName
+			if test.Input != nil {
+				p.missing = ansName
+			}
+			return nil
+		}
+		tests = append(tests, test)
+	}
+	p.pkg.Tests = tests
+	return nil
+}
+
+// generator imports the validator and the generator. If all tests are generated, the test script that recreates them is also made.
+// It fails if some test files are missing and they can't be generated
+func (p *polygonParser) generator() error {
+	upd := &p.pkg.Update
+
+	if len(p.xml.Validators) > 0 {
+		if code, lang, err := p.source(p.xml.Validators[0], true); err != nil {
+			p.warnf("The validator was not imported: %s", kilonova.ErrorMessage(err))
+		} else {
+			upd.ValidatorCode = &code
+			upd.ValidatorLang = &lang
+		}
+		if len(p.xml.Validators) > 1 {
+			p.warnf("Only the first validator was imported")
+		}
+	}
+
+	var missingErr error
+	if p.missing != "" {
+		missingErr = archiveErrorf(path.Join(p.root, p.missing), 0, "the test file is not in the package and the tests can't be generated, download the full package (with the generated tests)")
+	}
+
+	var gens []string
+	manual := 0
+	for _, test := range p.testset.Tests {
+		fields := strings.Fields(test.Cmd)
+		if test.Method != "generated" || len(fields) == 0 {
+			manual++
+			continue
+		}
+		if !containsString(gens, fields[0]) {
+			gens = append(gens, fields[0])
+		}
+	}
+	if len(gens) == 0 {
+		return missingErr
+	}
+	if len(gens) > 1 {
+		p.warnf("The tests use several generators (%s), but a problem has only one, so no generator was imported", strings.Join(gens, ", "))
+		return missingErr
+	}
+
+	var src *polygonSource
+	for i, exe := range p.xml.Executables {
+		if strings.TrimSuffix(path.Base(exe.Path), path.Ext(exe.Path)) == gens[0] {
+			src = &p.xml.Executables[i]
+		}
+	}
+	if src == nil {
+		p.warnf("The source of the generator %s is not in the package", gens[0])
+		return missingErr
+	}
+	code, lang, err := p.source(*src, true)
+	if err != nil {
+		p.warnf("The generator was not imported: %s", kilonova.ErrorMessage(err))
+		return missingErr
+	}
+	upd.GeneratorCode = &code
+	upd.GeneratorLang = &lang
+
+	if manual > 0 {
+		// The manual tests can't be created by the test script
+		return missingErr
+	}
+	var sb strings.Builder
+	sb.WriteString("# Imported from the Polygon package\n")
+	writeTest := func(id int) {
+		fmt.Fprintf(&sb, "%s > %d.in %d\n", p.testset.Tests[id-1].Cmd, id, p.scores[id-1])
+	}
+	if p.pkg.SubTasks != nil {
+		for _, stk := range p.pkg.SubTasks {
+			fmt.Fprintf(&sb, "subtask %d\n", stk.Score)
+			for _, id := range stk.Tests {
+				writeTest(id)
+			}
+		}
+	} else {
+		for id := range p.testset.Tests {
+			writeTest(id + 1)
+		}
+	}
+	script := sb.String()
+	if _, err := kilonova.ParseTestScript(script); err != nil {
+		p.warnf("The test script couldn't be made: %s", kilonova.ErrorMessage(err))
+		return missingErr
+	}
+	upd.TestScript = &script
+
+	if p.missing != "" {
+		p.warnf("%s is not in the package, so no tests were imported. Generate them from the test generation page", p.missing)
+	}
+	return nil
+}
+
+// graders imports the resource files that are compiled with the solutions as grader attachments
+func (p *polygonParser) graders() error {
+	for _, res := range p.xml.Resources {
+		isGrader := false
+		for _, asset := range res.Assets {
+			if asset.Name == "solution" {
+				isGrader = true
+			}
+		}
+		if !isGrader {
+			continue
+		}
+		lang, ok := polygonLanguage(res.Type)
+		if !ok {
+			p.warnf("The grader file %s was not imported, the language %q is not supported", res.Path, res.Type)
+			continue
+		}
+		data, err := p.read(res.Path)
+		if err != nil {
+			return err
+		}
+		p.addAttachment(&kilonova.Attachment{Name: path.Base(res.Path), Data: data, GraderLang: lang})
+	}
+	return nil
+}
+
+// addAttachment adds an attachment, unless there is another one with the same name
+func (p *polygonParser) addAttachment(att *kilonova.Attachment) {
+	for _, other := range p.pkg.Attachments {
+		if other.Name == att.Name {
+			return
+		}
+	}
+	p.pkg.Attachments = append(p.pkg.Attachments, att)
+}
+
+func (p *polygonParser) solutions() error {
+	for _, sol := range p.xml.Solutions {
+		name := path.Base(sol.Source.Path)
+		expected, ok := polygonOutcomes[sol.Tag]
+		if !ok {
+			p.warnf("The solution %s was not imported, its tag %q can't be expressed as an expected outcome", name, sol.Tag)
+			continue
+		}
+		code, lang, err := p.source(sol.Source, false)
+		if err != nil {
+			p.warnf("The solution %s was not imported: %s", name, kilonova.ErrorMessage(err))
+			continue
+		}
+		p.pkg.Solutions = append(p.pkg.Solutions, &kilonova.ProblemSolution{Name: name, Code: code, Language: lang, Expected: expected})
+	}
+	return nil
+}
+
+// pickLanguage returns the preferred language of the statement or of the name
+func pickLanguage(langs []string) string {
+	for _, pref := range []string{"romanian", "english"} {
+		if containsString(langs, pref) {
+			return pref
+		}
+	}
+	if len(langs) == 0 {
+		return ""
+	}
+	sorted := append([]string(nil), langs...)
+	sort.Strings(sorted)
+	return sorted[0]
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+package logic
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/KiloProjects/kilonova"
+)
+
+// statementHeadings are the headings of the statement sections, in the language of the statement
+type statementHeadings struct {
+	Legend, Input, Output, Interaction, Scoring, Examples, Notes string
+	ExampleInput, ExampleOutput                                  string
+}
+
+var polygonHeadings = map[string]statementHeadings{
+	"romanian": {"Cerință", "Date de intrare", "Date de ieșire", "Interacțiune", "Punctare", "Exemple", "Observații", "Intrare", "Ieșire"},
+	"english":  {"Statement", "Input", "Output", "Interaction", "Scoring", "Examples", "Notes", "Input", "Output"},
+}
+
+var (
+	latexFormatRe  = regexp.MustCompile(`\\(textbf|textit|emph|texttt|underline)\{([^{}]*)\}`)
+	latexImageRe   = regexp.MustCompile(`\\includegraphics(\[[^\]]*\])?\{([^{}]*)\}`)
+	latexListRe    = regexp.MustCompile(`\\(begin|end)\{(itemize|enumerate)\}|\\item\b[ \t]*`)
+	latexCommandRe = regexp.MustCompile(`\\[a-zA-Z]+`)
+
+	latexReplacer = strings.NewReplacer("``", "“", "''", "”", "<<", "«", ">>", "»", "---", "—", "~", " ", `\\`, "  \n", `\%`, "%", `\&`, "&", `\#`, "#")
+)
+
+// latexConverter converts the statement sections from LaTeX to Markdown.
+// Only the text formatting, the lists and the images are converted, the math is left as it is
+type latexConverter struct {
+	// lists holds the list environments that are open
+	lists   []string
+	images  []string
+	unknown map[string]bool
+}
+
+func (c *latexConverter) convert(s string) string {
+	// Old Polygon statements use $$$ for inline math
+	s = strings.ReplaceAll(s, "$$$$$$", "$$")
+	s = strings.ReplaceAll(s, "$$$", "$")
+
+	var sb strings.Builder
+	for s != "" {
+		i := strings.IndexByte(s, '$')
+		if i < 0 {
+			sb.WriteString(c.text(s))
+			break
+		}
+		sb.WriteString(c.text(s[:i]))
+		delim := "$"
+		if strings.HasPrefix(s[i:], "$$") {
+			delim = "$$"
+		}
+		end := strings.Index(s[i+len(delim):], delim)
+		if end < 0 {
+			sb.WriteString(s[i:])
+			break
+		}
+		end += i + 2*len(delim)
+		sb.WriteString(s[i:end])
+		s = s[end:]
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// text converts a part of a section that is not math
+func (c *latexConverter) text(s string) string {
+	s = latexImageRe.ReplaceAllStringFunc(s, func(m string) string {
+		name := path.Base(latexImageRe.FindStringSubmatch(m)[2])
+		c.images = append(c.images, name)
+		return "![](" + name + ")"
+	})
+	s = latexFormatRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := latexFormatRe.FindStringSubmatch(m)
+		switch sub[1] {
+		case "textbf":
+			return "**" + sub[2] + "**"
+		case "texttt":
+			return "`" + sub[2] + "`"
+		case "underline":
+			return sub[2]
+		default:
+			return "*" + sub[2] + "*"
+		}
+	})
+	s = latexListRe.ReplaceAllStringFunc(s, func(m string) string {
+		sub := latexListRe.FindStringSubmatch(m)
+		switch sub[1] {
+		case "begin":
+			c.lists = append(c.lists, sub[2])
+			return ""
+		case "end":
+			if len(c.lists) > 0 {
+				c.lists = c.lists[:len(c.lists)-1]
+			}
+			return ""
+		}
+		if len(c.lists) == 0 {
+			return "- "
+		}
+		indent := strings.Repeat("  ", len(c.lists)-1)
+		if c.lists[len(c.lists)-1] == "enumerate" {
+			return indent + "1. "
+		}
+		return indent + "- "
+	})
+	s = latexReplacer.Replace(s)
+	for _, cmd := range latexCommandRe.FindAllString(s, -1) {
+		c.unknown[cmd] = true
+	}
+	return s
+}
+
+// statement translates the statement. The statement sections are converted to Markdown,
+// otherwise the HTML statement is used as it is and, as a last resort, the PDF statement becomes an attachment
+func (p *polygonParser) statement() error {
+	var langs []string
+	for rel := range p.files {
+		parts := strings.Split(rel, "/")
+		if len(parts) == 3 && parts[0] == "statement-sections" && !containsString(langs, parts[1]) {
+			langs = append(langs, parts[1])
+		}
+	}
+	if len(langs) > 0 {
+		return p.statementSections(pickLanguage(langs))
+	}
+
+	var langsByType = make(map[string][]string)
+	for _, st := range p.xml.Statements {
+		langsByType[st.Type] = append(langsByType[st.Type], st.Language)
+	}
+	for _, typ := range []string{"text/html", "application/pdf"} {
+		lang := pickLanguage(langsByType[typ])
+		for _, st := range p.xml.Statements {
+			if st.Type != typ || st.Language != lang {
+				continue
+			}
+			data, err := p.read(st.Path)
+			if err != nil {
+				continue
+			}
+			if typ == "text/html" {
+				desc := string(data)
+				p.pkg.Update.Description = &desc
+				p.warnf("The package has no statement sections, the HTML statement was imported without its images")
+			} else {
+				p.addAttachment(&kilonova.Attachment{Name: "statement.pdf", Data: data, Visible: true})
+				p.warnf("The package has only a PDF statement, which was added as an attachment")
+			}
+			return nil
+		}
+	}
+	p.warnf("The package has no statement")
+	return nil
+}
+
+func (p *polygonParser) statementSections(lang string) error {
+	dir := path.Join("statement-sections", lang)
+	headings, ok := polygonHeadings[lang]
+	if !ok {
+		headings = polygonHeadings["english"]
+	}
+	conv := &latexConverter{unknown: make(map[string]bool)}
+
+	var sb strings.Builder
+	section := func(heading, text string) {
+		if text = strings.TrimSpace(text); text == "" {
+			return
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n\n")
+		}
+		sb.WriteString("## " + heading + "\n\n" + text)
+	}
+	read := func(name string) string {
+		if _, ok := p.files[path.Join(dir, name)]; !ok {
+			return ""
+		}
+		data, err := p.read(path.Join(dir, name))
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+
+	section(headings.Legend, conv.convert(read("legend.tex")))
+	section(headings.Input, conv.convert(read("input.tex")))
+	section(headings.Output, conv.convert(read("output.tex")))
+	section(headings.Interaction, conv.convert(read("interaction.tex")))
+	section(headings.Scoring, conv.convert(read("scoring.tex")))
+
+	// The examples are the example files of the statement or, if there are none, the sample tests
+	var names []string
+	for rel := range p.files {
+		if path.Dir(rel) == dir && strings.HasPrefix(path.Base(rel), "example.") && !strings.HasSuffix(rel, ".a") {
+			names = append(names, path.Base(rel))
+		}
+	}
+	sort.Strings(names)
+	var examples [][2]string
+	for _, name := range names {
+		examples = append(examples, [2]string{read(name), read(name + ".a")})
+	}
+	if len(examples) == 0 && p.pkg.Tests != nil {
+		for i, test := range p.testset.Tests {
+			if !test.Sample {
+				continue
+			}
+			in, err := readZipFile(p.pkg.Tests[i].Input)
+			if err != nil {
+				return err
+			}
+			out, err := readZipFile(p.pkg.Tests[i].Answer)
+			if err != nil {
+				return err
+			}
+			examples = append(examples, [2]string{string(in), string(out)})
+		}
+	}
+	var ex strings.Builder
+	for _, example := range examples {
+		if ex.Len() > 0 {
+			ex.WriteString("\n\n")
+		}
+		ex.WriteString("**" + headings.ExampleInput + "**\n\n```\n" + strings.TrimRight(example[0], "\n") + "\n```\n\n")
+		ex.WriteString("**" + headings.ExampleOutput + "**\n\n```\n" + strings.TrimRight(example[1], "\n") + "\n```")
+	}
+	section(headings.Examples, ex.String())
+
+	section(headings.Notes, conv.convert(read("notes.tex")))
+
+	desc := sb.String()
+	p.pkg.Update.Description = &desc
+
+	for _, name := range conv.images {
+		data, err := p.read(path.Join(dir, name))
+		if err != nil {
+			p.warnf("The image %s of the statement is not in the package", name)
+			continue
+		}
+		p.addAttachment(&kilonova.Attachment{Name: name, Data: data, Visible: true})
+	}
+	if len(conv.unknown) > 0 {
+		cmds := make([]string, 0, len(conv.unknown))
+		for cmd := range conv.unknown {
+			cmds = append(cmds, cmd)
+		}
+		sort.Strings(cmds)
+		p.warnf("The statement uses LaTeX commands that were not converted: %s", strings.Join(cmds, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package logic
+
+import (
+	"archive/zip"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/KiloProjects/kilonova"
+)
+
+const testProblemXML = `<?xml version="1.0" encoding="utf-8" standalone="no"?>
+<problem revision="3" short-name="a-plus-b">
+    <names>
+        <name language="english" value="A + B"/>
+    </names>
+    <judging input-file="" output-file="">
+        <testset name="tests">
+            <time-limit>2000</time-limit>
+            <memory-limit>268435456</memory-limit>
+            <test-count>3</test-count>
+            <input-path-pattern>tests/%02d</input-path-pattern>
+            <answer-path-pattern>tests/%02d.a</answer-path-pattern>
+            <tests>
+                <test method="TEST1" sample="true" group="0" points="0"/>
+                <test cmd="gen 1" method="generated" group="1" points="40"/>
+                <test cmd="gen 2" method="generated" group="1" points="60"/>
+            </tests>
+            <groups>
+                <group name="0" points-policy="each-test"/>
+                <group name="1" points-policy="complete-group"/>
+            </groups>
+        </testset>
+        <testset name="pretests">
+            <time-limit>2000</time-limit>
+            <memory-limit>268435456</memory-limit>
+        </testset>
+    </judging>
+    <files>
+        <resources>
+            <file path="files/testlib.h" type="h.g++"/>
+        </resources>
+        <executables>
+            <executable><source path="files/gen.cpp" type="cpp.g++17"/></executable>
+        </executables>
+    </files>
+    <assets>
+        <checker name="check.cpp" type="testlib">
+            <source path="files/check.cpp" type="cpp.g++17"/>
+        </checker>
+        <solutions>
+            <solution tag="main"><source path="solutions/sol.cpp" type="cpp.g++17"/></solution>
+            <solution tag="wrong-answer"><source path="solutions/wa.py" type="python.3"/></solution>
+            <solution tag="rejected"><source path="solutions/bad.cpp" type="cpp.g++17"/></solution>
+        </solutions>
+    </assets>
+</problem>`
+
+func polygonZip(t *testing.T, files map[string]string) *zip.Reader {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, data := range files {
+		f, err := w.Create("a-plus-b/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(data))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ar, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ar
+}
+
+func TestParsePolygonPackage(t *testing.T) {
+	files := map[string]string{
+		"problem.xml":     testProblemXML,
+		"files/testlib.h": "// testlib\n",
+		"files/check.cpp": "#include \"testlib.h\"\nint main() {}\n",
+		"files/gen.cpp":   "#include \"testlib.h\"\nint main() {}\n",
+		"tests/01":        "1 2\n", "tests/01.a": "3\n",
+		"tests/02": "2 2\n", "tests/02.a": "4\n",
+		"tests/03": "3 2\n", "tests/03.a": "5\n",
+		"solutions/sol.cpp": "int main() {}", "solutions/wa.py": "print(0)", "solutions/bad.cpp": "int main() {}",
+		"statement-sections/english/legend.tex": "Print $$$a+b$$$, in \\textbf{bold}. \\foo\n\\begin{itemize}\n\\item one\n\\end{itemize}\n\\includegraphics[width=5cm]{pic.png}",
+		"statement-sections/english/pic.png":    "png",
+	}
+	pkg, err := ParsePolygonPackage(polygonZip(t, files))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	upd := pkg.Update
+	if *upd.Name != "A + B" || *upd.TimeLimit != 2 || *upd.MemoryLimit != 262144 || !*upd.ConsoleInput {
+		t.Errorf("wrong limits %+v", upd)
+	}
+	if upd.Type != kilonova.ProblemTypeCustomChecker || upd.CheckerProtocol != kilonova.CheckerProtocolTestlib || *upd.HelperCode != "// testlib\n\nint main() {}\n" {
+		t.Errorf("wrong checker %q", *upd.HelperCode)
+	}
+	if upd.GeneratorCode == nil || upd.TestScript != nil {
+		t.Errorf("the generator must be imported without the script, since there is a manual test")
+	}
+	if upd.ScoringPolicy != kilonova.ScoringSubtaskMin || len(pkg.SubTasks) != 2 || pkg.SubTasks[0].Score != 0 || pkg.SubTasks[1].Score != 100 || len(pkg.SubTasks[1].Tests) != 2 {
+		t.Errorf("wrong subtasks %+v", pkg.SubTasks)
+	}
+	if len(pkg.Tests) != 3 || pkg.Tests[0].Score != 0 || pkg.Tests[1].Score != 50 || pkg.Tests[2].Input.Name != "a-plus-b/tests/03" {
+		t.Errorf("wrong tests %+v", pkg.Tests)
+	}
+	for _, want := range []string{"## Statement", "Print $a+b$, in **bold**.", "- one", "![](pic.png)", "## Examples", "```\n1 2\n```"} {
+		if !strings.Contains(*upd.Description, want) {
+			t.Errorf("the statement doesn't contain %q:\n%s", want, *upd.Description)
+		}
+	}
+	if len(pkg.Attachments) != 1 || pkg.Attachments[0].Name != "pic.png" || !pkg.Attachments[0].Visible {
+		t.Errorf("wrong attachments %+v", pkg.Attachments)
+	}
+	if len(pkg.Solutions) != 2 || pkg.Solutions[0].Expected != "AC" || pkg.Solutions[1].Language != "python" || pkg.Solutions[1].Expected != "WA" {
+		t.Errorf("wrong solutions %+v", pkg.Solutions)
+	}
+	warnings := strings.Join(pkg.Warnings, "\n")
+	for _, want := range []string{"pretests", "bad.cpp", `\foo`} {
+		if !strings.Contains(warnings, want) {
+			t.Errorf("the warnings don't mention %q:\n%s", want, warnings)
+		}
+	}
+
+	// The generated tests can't be recreated, since the first test is manual
+	delete(files, "tests/03.a")
+	if _, err := ParsePolygonPackage(polygonZip(t, files)); err == nil || !strings.Contains(err.Error(), "a-plus-b/tests/03.a") {
+		t.Errorf("wanted an error about the missing test, got %v", err)
+	}
+
+	// Without points and manual tests, the problem is scored ICPC style and the tests can be generated
+	xml := strings.Replace(testProblemXML, `method="TEST1"`, `cmd="gen 0" method="generated"`, 1)
+	xml = strings.NewReplacer(` points="0"`, "", ` points="40"`, "", ` points="60"`, "").Replace(xml)
+	files["problem.xml"] = xml
+	pkg, err = ParsePolygonPackage(polygonZip(t, files))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pkg.Tests != nil || pkg.SubTasks != nil || pkg.Update.ScoringPolicy != kilonova.ScoringICPC {
+		t.Errorf("wrong tests %+v %+v", pkg.Tests, pkg.SubTasks)
+	}
+	if pkg.Update.TestScript == nil || !strings.Contains(*pkg.Update.TestScript, "gen 2 > 3.in 33\n") {
+		t.Errorf("wrong test script %v", pkg.Update.TestScript)
+	}
+}
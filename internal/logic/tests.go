@@ -0,0 +1,111 @@
+package logic
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/KiloProjects/kilonova"
+)
+
+// NewTest is a test that replaces the tests of a problem, see ReplaceTests
+type NewTest struct {
+	VisibleID int
+	Score     int
+	// Input and Output open the data of the test
+	Input  func() (io.ReadCloser, error)
+	Output func() (io.ReadCloser, error)
+}
+
+// testLock is held while the tests of a problem are replaced.
+// refs counts the replacements that hold or wait for it, so it can be removed after the last one
+type testLock struct {
+	sync.Mutex
+	refs int
+}
+
+func (kn *Kilonova) lockTests(pbID int) {
+	kn.testMu.Lock()
+	lock, ok := kn.testLocks[pbID]
+	if !ok {
+		lock = &testLock{}
+		kn.testLocks[pbID] = lock
+	}
+	lock.refs++
+	kn.testMu.Unlock()
+
+	lock.Lock()
+}
+
+func (kn *Kilonova) unlockTests(pbID int) {
+	kn.testMu.Lock()
+	defer kn.testMu.Unlock()
+	lock := kn.testLocks[pbID]
+	lock.Unlock()
+	if lock.refs--; lock.refs == 0 {
+		delete(kn.testLocks, pbID)
+	}
+}
+
+// ReplaceTests puts the tests in place of the ones of the problem. If subTasks is not nil, they replace the subtasks of the problem.
+// The new tests are created orphaned and their data is saved first, then they are swapped with the old ones in a single transaction,
+// so the problem keeps its old tests and subtasks if anything fails. The replacements of the tests of a problem don't run at the same time
+func (kn *Kilonova) ReplaceTests(ctx context.Context, pbID int, tests []NewTest, subTasks []ManifestSubTask) (err error) {
+	kn.lockTests(pbID)
+	defer kn.unlockTests(pbID)
+
+	ids := make(map[int]int)
+	created := make([]int, 0, len(tests))
+	defer func() {
+		if err == nil {
+			return
+		}
+		// The context might be the reason of the failure
+		if err := kn.tserv.DeleteTests(context.Background(), created); err != nil {
+			log.Printf("Couldn't delete the new tests of problem %d: %s\n", pbID, err)
+		}
+	}()
+
+	for _, nt := range tests {
+		test := kilonova.Test{ProblemID: pbID, VisibleID: nt.VisibleID, Score: nt.Score, Orphaned: true}
+		if err := kn.tserv.CreateTest(ctx, &test); err != nil {
+			log.Println(err)
+			return err
+		}
+		created = append(created, test.ID)
+		ids[nt.VisibleID] = test.ID
+
+		if err := saveTestFile(nt.Input, test.ID, kn.DM.SaveTestInput); err != nil {
+			log.Println("Couldn't create test input", err)
+			return fmt.Errorf("Couldn't create test input: %w", err)
+		}
+		if err := saveTestFile(nt.Output, test.ID, kn.DM.SaveTestOutput); err != nil {
+			log.Println("Couldn't create test output", err)
+			return fmt.Errorf("Couldn't create test output: %w", err)
+		}
+	}
+
+	var stks []*kilonova.SubTask
+	if subTasks != nil {
+		stks = make([]*kilonova.SubTask, 0, len(subTasks))
+		for _, mstk := range subTasks {
+			stk := &kilonova.SubTask{ProblemID: pbID, VisibleID: mstk.VisibleID, Score: mstk.Score, Tests: make([]int, 0, len(mstk.Tests))}
+			for _, id := range mstk.Tests {
+				stk.Tests = append(stk.Tests, ids[id])
+			}
+			stks = append(stks, stk)
+		}
+	}
+	return kn.tserv.ReplaceProblemTests(ctx, pbID, created, stks)
+}
+
+func saveTestFile(open func() (io.ReadCloser, error), id int, save func(int, io.Reader) error) error {
+	f, err := open()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return save(id, f)
+}
@@ -0,0 +1,125 @@
+package logic
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var yamlErrorLine = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// parseYAML decodes a YAML document whose root is a mapping, like the CMS task files. Integers are decoded as int64 and floats as float64, like the TOML decoder does.
+// The line of every key is returned alongside the values, for the errors. The keys are named like in the other manifests ("a.b", "a[1]")
+func parseYAML(name, data string) (map[string]interface{}, map[string]int, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &doc); err != nil {
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			return nil, nil, archiveErrorf(name, line, "%s", m[2])
+		}
+		return nil, nil, archiveErrorf(name, 0, "%s", strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+
+	lines := make(map[string]int)
+	// An empty document has no content
+	if len(doc.Content) == 0 {
+		return make(map[string]interface{}), lines, nil
+	}
+	v, err := yamlValue(name, doc.Content[0], "", lines)
+	if err != nil {
+		return nil, nil, err
+	}
+	root, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, nil, archiveErrorf(name, doc.Content[0].Line, "expected a mapping of keys to values")
+	}
+	return root, lines, nil
+}
+
+// yamlValue converts the node to the values used by the manifests, recording the lines of the keys under key
+func yamlValue(name string, node *yaml.Node, key string, lines map[string]int) (interface{}, error) {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return yamlValue(name, node.Alias, key, lines)
+	case yaml.MappingNode:
+		m := make(map[string]interface{})
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+			if k.Kind != yaml.ScalarNode {
+				return nil, archiveErrorf(name, k.Line, "the keys must be strings")
+			}
+			child := k.Value
+			if key != "" {
+				child = key + "." + k.Value
+			}
+			if _, ok := m[k.Value]; ok {
+				return nil, archiveErrorf(name, k.Line, "%s: the key appears more than once", child)
+			}
+			lines[child] = k.Line
+			val, err := yamlValue(name, v, child, lines)
+			if err != nil {
+				return nil, err
+			}
+			m[k.Value] = val
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		list := make([]interface{}, 0, len(node.Content))
+		for i, item := range node.Content {
+			child := fmt.Sprintf("%s[%d]", key, i)
+			lines[child] = item.Line
+			val, err := yamlValue(name, item, child, lines)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, val)
+		}
+		return list, nil
+	case yaml.ScalarNode:
+		return yamlScalar(name, node)
+	}
+	return nil, archiveErrorf(name, node.Line, "unexpected YAML node")
+}
+
+// yamlScalar decodes a scalar. The plain booleans of YAML 1.1 (yes, no, on, off) are accepted, since CMS uses them
+func yamlScalar(name string, node *yaml.Node) (interface{}, error) {
+	var err error
+	switch node.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool":
+		var b bool
+		if err = node.Decode(&b); err == nil {
+			return b, nil
+		}
+	case "!!int":
+		var i int64
+		if err = node.Decode(&i); err == nil {
+			return i, nil
+		}
+	case "!!float":
+		var f float64
+		if err = node.Decode(&f); err == nil {
+			return f, nil
+		}
+	default:
+		if node.Style == 0 {
+			switch strings.ToLower(node.Value) {
+			case "yes", "on":
+				return true, nil
+			case "no", "off":
+				return false, nil
+			}
+		}
+		return node.Value, nil
+	}
+	return nil, archiveErrorf(name, node.Line, "invalid value %q", node.Value)
+}
+
+// yamlString quotes a string for a YAML file. The escapes of Go strings are valid in double-quoted YAML strings
+func yamlString(s string) string {
+	return strconv.Quote(s)
+}
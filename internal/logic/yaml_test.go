@@ -0,0 +1,73 @@
+package logic
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	data := `# A CMS task file
+name: aplusb # the short name
+title: "A + B # not a comment"
+time_limit: 1.5
+memory_limit: 256
+public_testcases: all
+infile: ''
+token_mode: disabled
+feedback: yes
+restricted: off
+score_type_parameters: [[40, "0[0-2]"],
+  [60, "0[3-9]"]]
+n_input: 10
+tags:
+  - easy
+  - math
+extra:
+  nested: 1
+description: |
+  two lines
+  of text
+`
+	root, lines, err := parseYAML("task.yaml", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"name":             "aplusb",
+		"title":            "A + B # not a comment",
+		"time_limit":       1.5,
+		"memory_limit":     int64(256),
+		"public_testcases": "all",
+		"infile":           "",
+		"token_mode":       "disabled",
+		"feedback":         true,
+		"restricted":       false,
+		"score_type_parameters": []interface{}{
+			[]interface{}{int64(40), "0[0-2]"},
+			[]interface{}{int64(60), "0[3-9]"},
+		},
+		"n_input":     int64(10),
+		"tags":        []interface{}{"easy", "math"},
+		"extra":       map[string]interface{}{"nested": int64(1)},
+		"description": "two lines\nof text\n",
+	}
+	if !reflect.DeepEqual(root, want) {
+		t.Errorf("wrong values:\n%#v\nwanted\n%#v", root, want)
+	}
+	for key, line := range map[string]int{"name": 2, "feedback": 9, "score_type_parameters[1]": 12, "tags[1]": 16, "extra.nested": 18} {
+		if lines[key] != line {
+			t.Errorf("%s: got line %d, wanted %d", key, lines[key], line)
+		}
+	}
+
+	for _, test := range []struct{ data, err string }{
+		{"name: a\nname: b\n", "task.yaml:2: name: the key appears more than once"},
+		{"name: [a\n", "task.yaml:"},
+		{"- a\n- b\n", "task.yaml:1: expected a mapping"},
+	} {
+		if _, _, err := parseYAML("task.yaml", test.data); err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: wanted an error containing %q, got %v", test.data, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+// Package metrics keeps the internal counters of the platform and exposes them in the Prometheus text format
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default histogram buckets, in seconds
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+var registry struct {
+	mu         sync.Mutex
+	collectors []collector
+}
+
+// register adds the collector to the registry. A collector with the same name is replaced, so components can be recreated
+func register(c collector) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	for i, old := range registry.collectors {
+		if old.name() == c.name() {
+			registry.collectors[i] = c
+			return
+		}
+	}
+	registry.collectors = append(registry.collectors, c)
+}
+
+// Handler serves all the registered metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		WriteAll(w)
+	})
+}
+
+// WriteAll writes all the registered metrics to w, in the Prometheus text format
+func WriteAll(w io.Writer) {
+	registry.mu.Lock()
+	collectors := make([]collector, len(registry.collectors))
+	copy(collectors, registry.collectors)
+	registry.mu.Unlock()
+
+	sort.Slice(collectors, func(i, j int) bool { return collectors[i].name() < collectors[j].name() })
+	for _, c := range collectors {
+		c.write(w)
+	}
+}
+
+type desc struct {
+	fqName string
+	help   string
+	labels []string
+}
+
+func (d *desc) name() string { return d.fqName }
+
+func (d *desc) header(w io.Writer, typ string) {
+	help := strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(d.help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.fqName, help, d.fqName, typ)
+}
+
+// key joins the label values, it panics if their number doesn't match the labels
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", d.fqName, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// labelString formats the labels, extra is added after them (ex: the le label of the histogram buckets)
+func (d *desc) labelString(values []string, extra ...string) string {
+	if len(d.labels) == 0 && len(extra) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(values)+len(extra)/2)
+	for i, l := range d.labels {
+		pairs = append(pairs, l+`="`+labelEscaper.Replace(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+labelEscaper.Replace(extra[i+1])+`"`)
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// sortedKeys returns the keys of the series, so they are always written in the same order
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter is a value that only goes up, split by the values of its labels
+type Counter struct {
+	desc
+	mu     sync.Mutex
+	values map[string]float64
+	series map[string][]string
+}
+
+// NewCounter creates and registers a counter
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name, help, labels}, values: make(map[string]float64), series: make(map[string][]string)}
+	register(c)
+	return c
+}
+
+// Inc adds 1 to the counter with the specified label values
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v to the counter with the specified label values. v must not be negative
+func (c *Counter) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counters can't decrease")
+	}
+	k := c.key(labelValues)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.series[k]; !ok {
+		c.series[k] = append([]string(nil), labelValues...)
+	}
+	c.values[k] += v
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.header(w, "counter")
+	if len(c.labels) == 0 && len(c.series) == 0 {
+		// A counter without labels is always shown, even if it's 0
+		fmt.Fprintf(w, "%s 0\n", c.fqName)
+		return
+	}
+	for _, k := range sortedKeys(c.series) {
+		fmt.Fprintf(w, "%s%s %s\n", c.fqName, c.labelString(c.series[k]), formatFloat(c.values[k]))
+	}
+}
+
+// Histogram counts the observed values in buckets, split by the values of its labels
+type Histogram struct {
+	desc
+	buckets []float64
+
+	mu     sync.Mutex
+	data   map[string]*histogramData
+	series map[string][]string
+}
+
+type histogramData struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram creates and registers a histogram. The buckets are the upper bounds, in increasing order
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc:    desc{name, help, labels},
+		buckets: buckets,
+		data:    make(map[string]*histogramData),
+		series:  make(map[string][]string),
+	}
+	register(h)
+	return h
+}
+
+// Observe adds the value to the histogram with the specified label values
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	k := h.key(labelValues)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	d, ok := h.data[k]
+	if !ok {
+		d = &histogramData{counts: make([]uint64, len(h.buckets))}
+		h.data[k] = d
+		h.series[k] = append([]string(nil), labelValues...)
+	}
+	for i, b := range h.buckets {
+		if v <= b {
+			d.counts[i]++
+		}
+	}
+	d.sum += v
+	d.count++
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.header(w, "histogram")
+	for _, k := range sortedKeys(h.series) {
+		values, d := h.series[k], h.data[k]
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.fqName, h.labelString(values, "le", formatFloat(b)), d.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.fqName, h.labelString(values, "le", "+Inf"), d.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.fqName, h.labelString(values), formatFloat(d.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.fqName, h.labelString(values), d.count)
+	}
+}
+
+// Sample is a value of a gauge, along with its label values
+type Sample struct {
+	LabelValues []string
+	Value       float64
+}
+
+// GaugeFunc is a value that can go up and down, which is read when the metrics are collected
+type GaugeFunc struct {
+	desc
+	f func() []Sample
+}
+
+// NewGaugeFunc creates and registers a gauge. f returns the current value for every combination of label values
+func NewGaugeFunc(name, help string, f func() []Sample, labels ...string) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name, help, labels}, f: f}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	samples := g.f()
+	g.header(w, "gauge")
+	for _, s := range samples {
+		g.key(s.LabelValues)
+		fmt.Fprintf(w, "%s%s %s\n", g.fqName, g.labelString(s.LabelValues), formatFloat(s.Value))
+	}
+}
@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	c := NewCounter("test_events_total", "Events\nby kind", "kind")
+	c.Inc("b")
+	c.Add(2, `a"1`)
+	h := NewHistogram("test_duration_seconds", "Durations", []float64{1, 5})
+	h.Observe(0.5)
+	h.Observe(3)
+	NewGaugeFunc("test_free", "Free slots", func() []Sample { return []Sample{{Value: 4}} })
+
+	var buf bytes.Buffer
+	WriteAll(&buf)
+	out := buf.String()
+
+	expected := []string{
+		"# HELP test_events_total Events\\nby kind\n# TYPE test_events_total counter\ntest_events_total{kind=\"a\\\"1\"} 2\ntest_events_total{kind=\"b\"} 1\n",
+		"test_duration_seconds_bucket{le=\"1\"} 1\ntest_duration_seconds_bucket{le=\"5\"} 2\ntest_duration_seconds_bucket{le=\"+Inf\"} 2\ntest_duration_seconds_sum 3.5\ntest_duration_seconds_count 2\n",
+		"# TYPE test_free gauge\ntest_free 4\n",
+	}
+	for _, exp := range expected {
+		if !strings.Contains(out, exp) {
+			t.Errorf("Output doesn't contain %q:\n%s", exp, out)
+		}
+	}
+}
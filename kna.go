@@ -1,7 +1,10 @@
 package kilonova
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"fmt"
 	"io"
 	"log"
 	"os"
@@ -31,6 +34,22 @@ func ReadKNA(data io.Reader) ([]*FullProblem, error) {
 		return nil, err
 	}
 
+	var version int
+	if err := db.Get(&version, "PRAGMA user_version"); err != nil {
+		db.Close()
+		return nil, err
+	}
+	// Archives without a version have a single row for every test file
+	inputs, outputs, order := "test_inputs", "test_outputs", "rowid"
+	switch version {
+	case 0:
+	case knaVersion:
+		inputs, outputs, order = "test_input_chunks", "test_output_chunks", "chunk"
+	default:
+		db.Close()
+		return nil, &Error{Code: EINVALID, Message: fmt.Sprintf("The archive has the unsupported format version %d, it was made by a newer version of Kilonova", version)}
+	}
+
 	pbrows, err := db.Queryx("SELECT * FROM problems;")
 	if err != nil {
 		return nil, err
@@ -39,6 +58,8 @@ func ReadKNA(data io.Reader) ([]*FullProblem, error) {
 	problems := []*FullProblem{}
 	for pbrows.Next() {
 		var problem FullProblem
+		// The columns missing from older archives keep the defaults. Like the problems that existed before it, they have no output size limit
+		problem.ComparatorEpsilon = DefaultComparatorEpsilon
 		if err := pbrows.StructScan(&problem.Problem); err != nil {
 			log.Println(err)
 			continue
@@ -57,14 +78,12 @@ func ReadKNA(data io.Reader) ([]*FullProblem, error) {
 				continue
 			}
 
-			input := []byte{}
-			err := db.Get(&input, "SELECT data FROM test_inputs WHERE test_id = ?", test.ID)
+			input, err := readKNAChunks(db, inputs, order, test.ID)
 			if err != nil {
 				continue
 			}
 
-			output := []byte{}
-			err = db.Get(&output, "SELECT data FROM test_outputs WHERE test_id = ?", test.ID)
+			output, err := readKNAChunks(db, outputs, order, test.ID)
 			if err != nil {
 				continue
 			}
@@ -128,9 +147,24 @@ func GenKNA(problems []*Problem, testServer TestService, subTaskServer SubTaskSe
 	short_description TEXT 	NOT NULL DEFAULT '',
 	default_points INTEGER 	NOT NULL DEFAULT 0,
 
-	pb_type 	TEXT CHECK(pb_type IN ('classic', 'interactive', 'custom_checker')) NOT NULL DEFAULT 'classic',
+	pb_type 	TEXT CHECK(pb_type IN ('classic', 'interactive', 'custom_checker', 'output_only')) NOT NULL DEFAULT 'classic',
 	helper_code TEXT 		NOT NULL DEFAULT '',
-	helper_code_lang TEXT 	NOT NULL DEFAULT 'cpp'
+	helper_code_lang TEXT 	NOT NULL DEFAULT 'cpp',
+	checker_protocol TEXT 	NOT NULL DEFAULT 'kilonova',
+	comparator 	TEXT 		NOT NULL DEFAULT 'diff',
+	comparator_epsilon FLOAT NOT NULL DEFAULT 0.000001,
+
+	output_size_limit INTEGER NOT NULL DEFAULT 0,
+	short_circuit INTEGER 	NOT NULL DEFAULT FALSE,
+	scoring_policy TEXT 	NOT NULL DEFAULT 'subtask_min',
+	language_limits TEXT 	NOT NULL DEFAULT '{}',
+	source_file_name TEXT 	NOT NULL DEFAULT '',
+
+	generator_code TEXT 	NOT NULL DEFAULT '',
+	generator_lang TEXT 	NOT NULL DEFAULT 'cpp',
+	validator_code TEXT 	NOT NULL DEFAULT '',
+	validator_lang TEXT 	NOT NULL DEFAULT 'cpp',
+	test_script TEXT 		NOT NULL DEFAULT ''
 );`); err != nil {
 		return nil, err
 	}
@@ -144,16 +178,26 @@ func GenKNA(problems []*Problem, testServer TestService, subTaskServer SubTaskSe
 		return nil, err
 	}
 
-	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS test_inputs (
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", knaVersion)); err != nil {
+		return nil, err
+	}
+
+	// The test files are split in chunks. The tables are not the ones of the archives without a version,
+	// so older versions of Kilonova can't mistake the first chunk for the whole file
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS test_input_chunks (
 	test_id 	INTEGER 	NOT NULL,
-	data 		BLOB 		NOT NULL
+	chunk 		INTEGER 	NOT NULL,
+	data 		BLOB 		NOT NULL,
+	PRIMARY KEY (test_id, chunk)
 );`); err != nil {
 		return nil, err
 	}
 
-	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS test_outputs (
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS test_output_chunks (
 	test_id 	INTEGER 	NOT NULL,
-	data 		BLOB 		NOT NULL
+	chunk 		INTEGER 	NOT NULL,
+	data 		BLOB 		NOT NULL,
+	PRIMARY KEY (test_id, chunk)
 );`); err != nil {
 		return nil, err
 	}
@@ -169,7 +213,11 @@ func GenKNA(problems []*Problem, testServer TestService, subTaskServer SubTaskSe
 
 	for _, pb := range problems {
 		var pbid int
-		if err := db.Get(&pbid, `INSERT INTO problems (name, description, test_name, time_limit, memory_limit, stack_limit, source_size, console_input, source_credits, author_credits, short_description, default_points, pb_type, helper_code, helper_code_lang) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`, pb.Name, pb.Description, pb.TestName, pb.TimeLimit, pb.MemoryLimit, pb.StackLimit, pb.SourceSize, pb.ConsoleInput, pb.SourceCredits, pb.AuthorCredits, pb.ShortDesc, pb.DefaultPoints, pb.Type, pb.HelperCode, pb.HelperCodeLang); err != nil {
+		if err := db.Get(&pbid, `INSERT INTO problems (name, description, test_name, time_limit, memory_limit, stack_limit, source_size, console_input, source_credits, author_credits, short_description, default_points, pb_type, helper_code, helper_code_lang,
+	checker_protocol, comparator, comparator_epsilon, output_size_limit, short_circuit, scoring_policy, language_limits, source_file_name, generator_code, generator_lang, validator_code, validator_lang, test_script)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+			pb.Name, pb.Description, pb.TestName, pb.TimeLimit, pb.MemoryLimit, pb.StackLimit, pb.SourceSize, pb.ConsoleInput, pb.SourceCredits, pb.AuthorCredits, pb.ShortDesc, pb.DefaultPoints, pb.Type, pb.HelperCode, pb.HelperCodeLang,
+			pb.CheckerProtocol, pb.Comparator, pb.ComparatorEpsilon, pb.OutputSizeLimit, pb.ShortCircuit, pb.ScoringPolicy, pb.LanguageLimits, pb.SourceFileName, pb.GeneratorCode, pb.GeneratorLang, pb.ValidatorCode, pb.ValidatorLang, pb.TestScript); err != nil {
 			log.Println(pb.ID, err)
 			continue
 		}
@@ -188,27 +236,12 @@ func GenKNA(problems []*Problem, testServer TestService, subTaskServer SubTaskSe
 				continue
 			}
 			testAssocs[test.ID] = testid
-			dataReader, err := dm.TestInput(test.ID)
-			if err != nil {
-				continue
-			}
-			data, err := io.ReadAll(dataReader)
-			if err != nil {
-				continue
-			}
-			if _, err := db.Exec(`INSERT INTO test_inputs (test_id, data) VALUES (?, ?)`, testid, data); err != nil {
-				continue
-			}
-
-			dataReader, err = dm.TestOutput(test.ID)
-			if err != nil {
-				continue
-			}
-			data, err = io.ReadAll(dataReader)
-			if err != nil {
+			if err := insertKNAChunks(db, "test_input_chunks", testid, dm.TestInput, test.ID); err != nil {
+				log.Println(pb.ID, test.ID, err)
 				continue
 			}
-			if _, err := db.Exec(`INSERT INTO test_outputs (test_id, data) VALUES (?, ?)`, testid, data); err != nil {
+			if err := insertKNAChunks(db, "test_output_chunks", testid, dm.TestOutput, test.ID); err != nil {
+				log.Println(pb.ID, test.ID, err)
 				continue
 			}
 		}
@@ -242,6 +275,53 @@ func GenKNA(problems []*Problem, testServer TestService, subTaskServer SubTaskSe
 	return os.Open(path)
 }
 
+// knaVersion is the format version of the archives made by GenKNA, which is stored in the user_version of the database.
+// Version 1 splits the test files in chunks
+const knaVersion = 1
+
+// knaChunkSize is the maximum size of a row of test data. Tests are split in chunks, so they are never fully held in memory
+const knaChunkSize = 1024 * 1024
+
+// insertKNAChunks saves the test file in the table, one chunk per row
+func insertKNAChunks(db *sqlx.DB, table string, testID int, open func(int) (io.ReadCloser, error), srcID int) error {
+	r, err := open(srcID)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	buf := make([]byte, knaChunkSize)
+	for chunk := 0; ; chunk++ {
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF && chunk > 0 {
+			return nil
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		// An empty file still gets a row
+		if _, err := db.Exec(`INSERT INTO `+table+` (test_id, chunk, data) VALUES (?, ?, ?)`, testID, chunk, buf[:n]); err != nil {
+			return err
+		}
+		if n < knaChunkSize {
+			return nil
+		}
+	}
+}
+
+// readKNAChunks joins the chunks of a test file, sorted by the order column.
+// Archives without a version have a single chunk
+func readKNAChunks(db *sqlx.DB, table, order string, testID int) ([]byte, error) {
+	chunks := [][]byte{}
+	if err := db.Select(&chunks, "SELECT data FROM "+table+" WHERE test_id = ? ORDER BY "+order, testID); err != nil {
+		return nil, err
+	}
+	if len(chunks) == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return bytes.Join(chunks, nil), nil
+}
+
 type knasubtask struct {
 	ProblemID int    `db:"problem_id"`
 	VisibleID int    `db:"visible_id"`
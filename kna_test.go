@@ -0,0 +1,96 @@
+package kilonova
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// knaTestStore serves the tests of a single problem from memory
+type knaTestStore struct {
+	TestService
+	SubTaskService
+	GraderStore
+
+	tests    []*Test
+	subtasks []*SubTask
+	data     map[int][]byte
+}
+
+func (s *knaTestStore) Tests(ctx context.Context, problemID int) ([]*Test, error) {
+	return s.tests, nil
+}
+
+func (s *knaTestStore) SubTasks(ctx context.Context, pbid int) ([]*SubTask, error) {
+	return s.subtasks, nil
+}
+
+func (s *knaTestStore) TestInput(testID int) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(s.data[testID])), nil
+}
+
+func (s *knaTestStore) TestOutput(testID int) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(s.data[-testID])), nil
+}
+
+func TestKNA(t *testing.T) {
+	pb := &Problem{
+		ID: 1, Name: "A + B", TestName: "aplusb", TimeLimit: 0.5, MemoryLimit: 65536, StackLimit: 16384, SourceSize: 10000,
+		Type: ProblemTypeCustomChecker, HelperCode: "int main() {}", HelperCodeLang: "cpp", CheckerProtocol: CheckerProtocolTestlib,
+		Comparator: ComparatorFloat, ComparatorEpsilon: 0, OutputSizeLimit: 1024, ShortCircuit: true, ScoringPolicy: ScoringICPC,
+		LanguageLimits: LanguageLimits{"python": {TimeMultiplier: 2}}, SourceFileName: "sum",
+		GeneratorCode: "gen", GeneratorLang: "python", ValidatorCode: "val", ValidatorLang: "cpp", TestScript: "gen 1 > 1.in 100",
+	}
+	store := &knaTestStore{
+		tests:    []*Test{{ID: 7, VisibleID: 1, Score: 100}},
+		subtasks: []*SubTask{{ID: 3, VisibleID: 1, Score: 100, Tests: []int{7}}},
+		// The input is split in three chunks
+		data: map[int][]byte{7: bytes.Repeat([]byte("1 2\n"), knaChunkSize*5/8), -7: []byte("3\n")},
+	}
+
+	rd, err := GenKNA([]*Problem{pb}, store, store, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(rd.(*os.File).Name())
+	defer rd.Close()
+	pbs, err := ReadKNA(rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pbs) != 1 {
+		t.Fatalf("got %d problems", len(pbs))
+	}
+
+	got := pbs[0].Problem
+	got.ID, got.CreatedAt = pb.ID, pb.CreatedAt
+	if !reflect.DeepEqual(&got, pb) {
+		t.Errorf("the problem changed:\n%+v\n%+v", &got, pb)
+	}
+	if len(pbs[0].Tests) != 1 || !bytes.Equal(pbs[0].Tests[0].Input, store.data[7]) || string(pbs[0].Tests[0].Output) != "3\n" {
+		t.Errorf("wrong tests %+v", pbs[0].Tests)
+	}
+	if len(pbs[0].SubTasks) != 1 || !reflect.DeepEqual(pbs[0].SubTasks[0].Tests, []int{pbs[0].Tests[0].ID}) {
+		t.Errorf("wrong subtasks %+v", pbs[0].SubTasks)
+	}
+
+	// Archives made by newer versions are rejected instead of being read partially
+	db, err := sqlx.Connect("sqlite3", "file:"+rd.(*os.File).Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("PRAGMA user_version = 100"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	rd.Seek(0, io.SeekStart)
+	if _, err := ReadKNA(rd); err == nil || !strings.Contains(err.Error(), "version 100") {
+		t.Errorf("wanted an error about the version, got %v", err)
+	}
+}
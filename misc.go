@@ -51,6 +51,7 @@ type TypeServicer interface {
 	SessionService() Sessioner
 	VerificationService() Verificationer
 	AttachmentService() AttachmentService
+	ProblemSolutionService() ProblemSolutionService
 	io.Closer
 }
 
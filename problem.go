@@ -2,6 +2,10 @@ package kilonova
 
 import (
 	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"math"
 	"time"
 )
 
@@ -11,10 +15,118 @@ const (
 	ProblemTypeNone          ProblemType = ""
 	ProblemTypeClassic       ProblemType = "classic"
 	ProblemTypeCustomChecker ProblemType = "custom_checker"
-	// TODO
-	ProblemTypeInteractive ProblemType = "interactive"
+	ProblemTypeInteractive   ProblemType = "interactive"
+	// ProblemTypeOutputOnly problems receive an archive with the output of every test instead of source code.
+	// The outputs are checked with the custom checker if the problem has one, otherwise with the comparator
+	ProblemTypeOutputOnly ProblemType = "output_only"
 )
 
+// LanguageOutputOnly is the language of the submissions to output-only problems
+const LanguageOutputOnly = "output_only"
+
+// Comparator is the built-in checker used for classic problems and for the output-only problems without a checker
+type Comparator string
+
+const (
+	// ComparatorDiff compares the outputs using diff, ignoring blank lines and changes in the amount of whitespace
+	ComparatorDiff Comparator = "diff"
+	// ComparatorTokens compares the outputs token by token, ignoring all whitespace
+	ComparatorTokens Comparator = "tokens"
+	// ComparatorFloat compares the numeric tokens with an absolute or relative epsilon
+	ComparatorFloat Comparator = "float"
+	// ComparatorCaseInsensitive compares the outputs token by token, ignoring the case of letters
+	ComparatorCaseInsensitive Comparator = "case_insensitive"
+	// ComparatorUnorderedLines accepts any permutation of the lines of the correct output
+	ComparatorUnorderedLines Comparator = "unordered_lines"
+	// ComparatorUnorderedTokens accepts any permutation of the tokens of the correct output
+	ComparatorUnorderedTokens Comparator = "unordered_tokens"
+)
+
+// DefaultOutputSizeLimit is the output size limit given to new problems, in kilobytes
+const DefaultOutputSizeLimit = 65536 // 64MB
+
+// DefaultComparatorEpsilon is the epsilon given to new problems. An epsilon of 0 is valid and makes the float comparator exact
+const DefaultComparatorEpsilon = 1e-6
+
+// CheckerProtocol is the way a custom checker is called and reports the result
+type CheckerProtocol string
+
+const (
+	// CheckerProtocolKilonova calls `checker program.out correct.out correct.in`. The checker prints the score (0-100), followed by the verdict
+	CheckerProtocolKilonova CheckerProtocol = "kilonova"
+	// CheckerProtocolTestlib calls `checker input output answer`, like testlib checkers. The verdict is given by the exit code
+	CheckerProtocolTestlib CheckerProtocol = "testlib"
+	// CheckerProtocolCMS calls `checker input answer output`, like CMS comparators. The checker prints the score (0.0-1.0) to stdout and the message to stderr
+	CheckerProtocolCMS CheckerProtocol = "cms"
+)
+
+// Valid reports if the protocol is known
+func (p CheckerProtocol) Valid() bool {
+	return p == CheckerProtocolKilonova || p == CheckerProtocolTestlib || p == CheckerProtocolCMS
+}
+
+// Valid reports if the comparator is known
+func (c Comparator) Valid() bool {
+	switch c {
+	case ComparatorDiff, ComparatorTokens, ComparatorFloat, ComparatorCaseInsensitive, ComparatorUnorderedLines, ComparatorUnorderedTokens:
+		return true
+	default:
+		return false
+	}
+}
+
+// LimitAdjustment changes the limits of a problem for a language, since some languages are slower or need more memory.
+// The limits are multiplied, then the offsets are added. Zero multipliers are treated as 1
+type LimitAdjustment struct {
+	TimeMultiplier float64 `json:"time_multiplier"`
+	// TimeOffset is in seconds
+	TimeOffset       float64 `json:"time_offset"`
+	MemoryMultiplier float64 `json:"memory_multiplier"`
+	// MemoryOffset is in kilobytes
+	MemoryOffset int `json:"memory_offset"`
+}
+
+// Apply returns the adjusted time (in seconds) and memory (in kilobytes) limits
+func (a LimitAdjustment) Apply(timeLimit float64, memoryLimit int) (float64, int) {
+	if a.TimeMultiplier != 0 {
+		timeLimit *= a.TimeMultiplier
+	}
+	if a.MemoryMultiplier != 0 {
+		memoryLimit = int(math.Round(float64(memoryLimit) * a.MemoryMultiplier))
+	}
+	return timeLimit + a.TimeOffset, memoryLimit + a.MemoryOffset
+}
+
+// LanguageLimits holds the limit adjustments of a problem, by language name.
+// They replace the adjustments from the language config
+type LanguageLimits map[string]LimitAdjustment
+
+// Value stores the adjustments as JSON
+func (l LanguageLimits) Value() (driver.Value, error) {
+	if l == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(l)
+	return string(data), err
+}
+
+// Scan reads the adjustments stored by Value
+func (l *LanguageLimits) Scan(src interface{}) error {
+	var data []byte
+	switch src := src.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case string:
+		data = []byte(src)
+	case []byte:
+		data = src
+	default:
+		return errors.New("Invalid type for language limits")
+	}
+	return json.Unmarshal(data, l)
+}
+
 type Problem struct {
 	ID            int       `json:"id"`
 	CreatedAt     time.Time `json:"created_at" db:"created_at"`
@@ -31,6 +143,10 @@ type Problem struct {
 	MemoryLimit int     `json:"memory_limit" db:"memory_limit"`
 	StackLimit  int     `json:"stack_limit" db:"stack_limit"`
 	SourceSize  int     `json:"source_size" db:"source_size"`
+	// OutputSizeLimit is the maximum size of the output file, in kilobytes. 0 means no limit
+	OutputSizeLimit int `json:"output_size_limit" db:"output_size_limit"`
+	// LanguageLimits overrides the limit adjustments of the languages
+	LanguageLimits LanguageLimits `json:"language_limits" db:"language_limits"`
 
 	SourceCredits string `json:"source_credits" db:"source_credits"`
 	AuthorCredits string `json:"author_credits" db:"author_credits"`
@@ -40,6 +156,29 @@ type Problem struct {
 	HelperCode     string      `json:"-" db:"helper_code"`
 	HelperCodeLang string      `json:"-" db:"helper_code_lang"`
 	ConsoleInput   bool        `json:"console_input" db:"console_input"`
+	// SourceFileName is the name of the submission's source file, without the extension.
+	// It is needed when grader files include it. If it is empty, the name from the language config is used
+	SourceFileName string `json:"source_file_name" db:"source_file_name"`
+
+	CheckerProtocol CheckerProtocol `json:"checker_protocol" db:"checker_protocol"`
+
+	ScoringPolicy ScoringPolicy `json:"scoring_policy" db:"scoring_policy"`
+
+	// ShortCircuit makes the grader evaluate the subtasks in order and skip the remaining tests of a subtask after one of them gets no points
+	ShortCircuit bool `json:"short_circuit" db:"short_circuit"`
+
+	// Comparator and ComparatorEpsilon are used by classic problems and by the output-only problems without a checker
+	Comparator        Comparator `json:"comparator" db:"comparator"`
+	ComparatorEpsilon float64    `json:"comparator_epsilon" db:"comparator_epsilon"`
+
+	// The generator is run with the arguments from the test script to create the test inputs, which are checked by the validator (if there is one).
+	// The validator reads the input from stdin and must exit with code 0 if it is valid
+	GeneratorCode string `json:"-" db:"generator_code"`
+	GeneratorLang string `json:"-" db:"generator_lang"`
+	ValidatorCode string `json:"-" db:"validator_code"`
+	ValidatorLang string `json:"-" db:"validator_lang"`
+	// TestScript is in the format accepted by ParseTestScript
+	TestScript string `json:"-" db:"test_script"`
 }
 
 // ProblemFilter is the struct with all filterable fields on the problem
@@ -72,6 +211,10 @@ type ProblemUpdate struct {
 	StackLimit  *int     `json:"stack_limit"`
 	SourceSize  *int     `json:"source_size"`
 
+	OutputSizeLimit *int `json:"output_size_limit"`
+	// LanguageLimits is updated if it is not nil, an empty map removes all overrides
+	LanguageLimits LanguageLimits `json:"language_limits"`
+
 	SourceCredits *string `json:"source_credits"`
 	AuthorCredits *string `json:"author_credits"`
 
@@ -80,7 +223,21 @@ type ProblemUpdate struct {
 	HelperCodeLang *string     `json:"helper_code_lang"`
 	SubtaskString  *string     `json:"subtask_string"`
 	ConsoleInput   *bool       `json:"console_input"`
+	SourceFileName *string     `json:"source_file_name"`
 	Visible        *bool       `json:"visible"`
+	ShortCircuit   *bool       `json:"short_circuit"`
+
+	ScoringPolicy ScoringPolicy `json:"scoring_policy"`
+
+	CheckerProtocol   CheckerProtocol `json:"checker_protocol"`
+	Comparator        Comparator      `json:"comparator"`
+	ComparatorEpsilon *float64        `json:"comparator_epsilon"`
+
+	GeneratorCode *string `json:"generator_code"`
+	GeneratorLang *string `json:"generator_lang"`
+	ValidatorCode *string `json:"validator_code"`
+	ValidatorLang *string `json:"validator_lang"`
+	TestScript    *string `json:"test_script"`
 }
 
 type ProblemService interface {
@@ -101,22 +258,28 @@ type Attachment struct {
 
 	Name string `json:"name"`
 	Data []byte `json:"data"`
+
+	// GraderLang makes the attachment a grader file for the language.
+	// Grader files (like grader.cpp or a header) are placed next to the submission code and compiled with it
+	GraderLang string `json:"grader_lang" db:"grader_lang"`
 }
 
 type AttachmentFilter struct {
-	ID        *int    `json:"id"`
-	ProblemID *int    `json:"problem_id"`
-	Visible   *bool   `json:"visible"`
-	Name      *string `json:"name"`
+	ID         *int    `json:"id"`
+	ProblemID  *int    `json:"problem_id"`
+	Visible    *bool   `json:"visible"`
+	Name       *string `json:"name"`
+	GraderLang *string `json:"grader_lang"`
 
 	Limit  int `json:"limit"`
 	Offset int `json:"offset"`
 }
 
 type AttachmentUpdate struct {
-	Visible *bool   `json:"visible"`
-	Data    []byte  `json:"data"`
-	Name    *string `json:"name"`
+	Visible    *bool   `json:"visible"`
+	Data       []byte  `json:"data"`
+	Name       *string `json:"name"`
+	GraderLang *string `json:"grader_lang"`
 }
 
 type AttachmentService interface {
@@ -0,0 +1,124 @@
+package kilonova
+
+import "math"
+
+// ScoringPolicy decides how the test results are combined into the score of a submission
+type ScoringPolicy string
+
+const (
+	// ScoringSum adds the score of every test, weighted by the percentage given by the checker. Subtasks are ignored
+	ScoringSum ScoringPolicy = "sum"
+	// ScoringSubtaskMin gives every subtask its score weighted by the lowest percentage of its tests.
+	// Problems without subtasks are scored like ScoringSum
+	ScoringSubtaskMin ScoringPolicy = "subtask_min"
+	// ScoringSubtaskProduct gives every subtask its score weighted by the product of the percentages of its tests.
+	// Problems without subtasks are scored like ScoringSum
+	ScoringSubtaskProduct ScoringPolicy = "subtask_product"
+	// ScoringICPC gives the full score only if all tests are fully correct, otherwise only the default points
+	ScoringICPC ScoringPolicy = "icpc"
+	// ScoringBestSubtask scores a submission like ScoringSubtaskMin, but the score of a user on the problem
+	// is the sum of the best score obtained on each subtask, across all submissions
+	ScoringBestSubtask ScoringPolicy = "best_subtask"
+)
+
+// Valid reports if the scoring policy is known
+func (p ScoringPolicy) Valid() bool {
+	switch p {
+	case ScoringSum, ScoringSubtaskMin, ScoringSubtaskProduct, ScoringICPC, ScoringBestSubtask:
+		return true
+	default:
+		return false
+	}
+}
+
+// TestResult is the outcome of a test, as needed for scoring
+type TestResult struct {
+	TestID int
+	// MaxScore is the score of the test
+	MaxScore int
+	// Percentage is the percentage of MaxScore that was obtained
+	Percentage int
+}
+
+// ComputeScore returns the score of a submission with the specified test results.
+// For the subtask based policies, the points obtained on every subtask are also returned, by subtask ID.
+// An empty policy is treated as ScoringSubtaskMin
+func ComputeScore(policy ScoringPolicy, defaultPoints int, results []TestResult, subTasks []*SubTask) (int, map[int]int) {
+	byTest := make(map[int]TestResult)
+	for _, res := range results {
+		byTest[res.TestID] = res
+	}
+
+	switch policy {
+	case ScoringICPC:
+		full := defaultPoints
+		if len(subTasks) > 0 {
+			for _, stk := range subTasks {
+				full += stk.Score
+			}
+		} else {
+			for _, res := range results {
+				full += res.MaxScore
+			}
+		}
+		for _, res := range results {
+			if res.Percentage < 100 {
+				return defaultPoints, nil
+			}
+		}
+		return full, nil
+	case "", ScoringSubtaskMin, ScoringSubtaskProduct, ScoringBestSubtask:
+		if len(subTasks) == 0 {
+			break
+		}
+		score := defaultPoints
+		stkScores := make(map[int]int)
+		for _, stk := range subTasks {
+			fraction := 1.0
+			for _, id := range stk.Tests {
+				res, ok := byTest[id]
+				if !ok {
+					continue
+				}
+				p := float64(res.Percentage) / 100.0
+				if policy == ScoringSubtaskProduct {
+					fraction *= p
+				} else if p < fraction {
+					fraction = p
+				}
+			}
+			stkScores[stk.ID] = int(math.Round(float64(stk.Score) * fraction))
+			score += stkScores[stk.ID]
+		}
+		return score, stkScores
+	}
+
+	score := defaultPoints
+	for _, res := range results {
+		score += int(math.Round(float64(res.MaxScore) * float64(res.Percentage) / 100.0))
+	}
+	return score, nil
+}
+
+// FullScore returns the score of a submission that passes all the tests of a problem, which is the score needed to solve it
+func FullScore(policy ScoringPolicy, defaultPoints int, tests []*Test, subTasks []*SubTask) int {
+	results := make([]TestResult, 0, len(tests))
+	for _, test := range tests {
+		results = append(results, TestResult{TestID: test.ID, MaxScore: test.Score, Percentage: 100})
+	}
+	score, _ := ComputeScore(policy, defaultPoints, results, subTasks)
+	return score
+}
+
+// SplitScore splits the total between n tests as evenly as possible, the first tests get the remaining points.
+// If there are more tests than points, some tests are worth 0 points, so the scores always add up to the total
+func SplitScore(total, n int) []int {
+	scores := make([]int, n)
+	for i := range scores {
+		scores[i] = total / n
+		if i < total%n {
+			scores[i]++
+		}
+	}
+	return scores
+}
@@ -0,0 +1,75 @@
+package kilonova
+
+import "testing"
+
+func TestComputeScore(t *testing.T) {
+	results := []TestResult{
+		{TestID: 1, MaxScore: 20, Percentage: 100},
+		{TestID: 2, MaxScore: 30, Percentage: 50},
+		{TestID: 3, MaxScore: 40, Percentage: 100},
+	}
+	subTasks := []*SubTask{
+		{ID: 1, Score: 40, Tests: []int{1, 2}},
+		{ID: 2, Score: 50, Tests: []int{2, 3}},
+	}
+
+	var tests = []struct {
+		policy   ScoringPolicy
+		subTasks []*SubTask
+		score    int
+	}{
+		{ScoringSum, subTasks, 10 + 20 + 15 + 40},
+		{ScoringSubtaskMin, subTasks, 10 + 20 + 25},
+		{ScoringSubtaskMin, nil, 10 + 20 + 15 + 40},
+		{ScoringSubtaskProduct, subTasks, 10 + 20 + 25},
+		{ScoringICPC, subTasks, 10},
+		{ScoringBestSubtask, subTasks, 10 + 20 + 25},
+	}
+
+	for _, test := range tests {
+		score, _ := ComputeScore(test.policy, 10, results, test.subTasks)
+		if score != test.score {
+			t.Errorf("%s: wanted score %d, got %d", test.policy, test.score, score)
+		}
+	}
+
+	results[1].Percentage = 100
+	if score, _ := ComputeScore(ScoringICPC, 10, results, nil); score != 100 {
+		t.Errorf("icpc: wanted score 100, got %d", score)
+	}
+
+	// The product of the percentages is lower than their minimum if more than one test is partially scored
+	results = []TestResult{
+		{TestID: 1, MaxScore: 50, Percentage: 50},
+		{TestID: 2, MaxScore: 50, Percentage: 50},
+	}
+	subTasks = []*SubTask{{ID: 1, Score: 100, Tests: []int{1, 2}}}
+	for policy, want := range map[ScoringPolicy]int{ScoringSubtaskMin: 50, ScoringSubtaskProduct: 25} {
+		score, stkScores := ComputeScore(policy, 0, results, subTasks)
+		if score != want || stkScores[1] != want {
+			t.Errorf("%s: wanted score %d, got %d (subtasks %v)", policy, want, score, stkScores)
+		}
+	}
+}
+
+func TestFullScore(t *testing.T) {
+	tests := []*Test{{ID: 1, Score: 20}, {ID: 2, Score: 30}}
+	subTasks := []*SubTask{{ID: 1, Score: 40, Tests: []int{1}}, {ID: 2, Score: 35, Tests: []int{1, 2}}}
+
+	var cases = []struct {
+		policy   ScoringPolicy
+		subTasks []*SubTask
+		score    int
+	}{
+		{ScoringSum, subTasks, 10 + 50},
+		{ScoringSubtaskMin, subTasks, 10 + 75},
+		{ScoringSubtaskMin, nil, 10 + 50},
+		{ScoringICPC, subTasks, 10 + 75},
+		{ScoringBestSubtask, subTasks, 10 + 75},
+	}
+	for _, c := range cases {
+		if score := FullScore(c.policy, 10, tests, c.subTasks); score != c.score {
+			t.Errorf("%s: wanted full score %d, got %d", c.policy, c.score, score)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package kilonova
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SolutionStatus is the result of the last check of a problem solution
+type SolutionStatus string
+
+const (
+	SolutionUnchecked SolutionStatus = ""
+	// SolutionPending solutions will be checked again, since the problem changed
+	SolutionPending SolutionStatus = "pending"
+	// SolutionOK solutions got the expected outcome
+	SolutionOK SolutionStatus = "ok"
+	// SolutionMismatch solutions got a different outcome than the expected one
+	SolutionMismatch SolutionStatus = "mismatch"
+	// SolutionError solutions could not be judged (ex: the checker doesn't compile)
+	SolutionError SolutionStatus = "error"
+)
+
+// ProblemSolution is a solution stored alongside a problem, like the model solution or a known-wrong one.
+// The solutions are judged like submissions, to make sure the tests and the limits give the expected outcome
+type ProblemSolution struct {
+	ID        int       `json:"id"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	ProblemID int       `json:"problem_id" db:"problem_id"`
+
+	Name     string `json:"name"`
+	Code     string `json:"code"`
+	Language string `json:"language"`
+	// Expected is the expected outcome, in the format accepted by ParseExpectedOutcome
+	Expected string `json:"expected"`
+
+	CheckStatus  SolutionStatus `json:"check_status" db:"check_status"`
+	CheckMessage string         `json:"check_message" db:"check_message"`
+	CheckedAt    *time.Time     `json:"checked_at" db:"checked_at"`
+}
+
+type ProblemSolutionUpdate struct {
+	Name     *string `json:"name"`
+	Code     *string `json:"code"`
+	Language *string `json:"language"`
+	Expected *string `json:"expected"`
+
+	CheckStatus  *SolutionStatus `json:"check_status"`
+	CheckMessage *string         `json:"check_message"`
+	CheckedAt    *time.Time      `json:"checked_at"`
+}
+
+type ProblemSolutionService interface {
+	CreateProblemSolution(ctx context.Context, sol *ProblemSolution) error
+	ProblemSolution(ctx context.Context, id int) (*ProblemSolution, error)
+	ProblemSolutions(ctx context.Context, problemID int) ([]*ProblemSolution, error)
+	UpdateProblemSolution(ctx context.Context, id int, upd ProblemSolutionUpdate) error
+	// UpdateProblemSolutions updates all solutions of the problem
+	UpdateProblemSolutions(ctx context.Context, problemID int, upd ProblemSolutionUpdate) error
+	DeleteProblemSolution(ctx context.Context, id int) error
+}
+
+// ExpectedVerdict requires a verdict on a test, on a subtask or anywhere. At most one of SubTask and Test is set.
+// AC requires all the tests in its scope to be accepted, the other verdicts require at least one test with the verdict
+type ExpectedVerdict struct {
+	Verdict VerdictCode
+	// SubTask and Test are visible IDs
+	SubTask int
+	Test    int
+}
+
+func (v ExpectedVerdict) String() string {
+	switch {
+	case v.SubTask > 0:
+		return fmt.Sprintf("%s on subtask %d", v.Verdict, v.SubTask)
+	case v.Test > 0:
+		return fmt.Sprintf("%s on test %d", v.Verdict, v.Test)
+	}
+	return string(v.Verdict)
+}
+
+// ExpectedOutcome is the parsed expected outcome of a problem solution
+type ExpectedOutcome struct {
+	// Score is the expected score of the solution, -1 if it is not checked
+	Score    int
+	Verdicts []ExpectedVerdict
+}
+
+// AllAccepted reports if the outcome requires all tests to be accepted
+func (e *ExpectedOutcome) AllAccepted() bool {
+	for _, v := range e.Verdicts {
+		if v.Verdict == VerdictAccepted && v.SubTask == 0 && v.Test == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// expectableVerdicts are the verdicts that can be expected, the other ones mean that the grader failed
+var expectableVerdicts = map[string]VerdictCode{
+	"AC":  VerdictAccepted,
+	"WA":  VerdictWrongAnswer,
+	"PC":  VerdictPartial,
+	"TLE": VerdictTimeLimit,
+	"MLE": VerdictMemoryLimit,
+	"RE":  VerdictRuntimeError,
+	"OLE": VerdictOutputLimit,
+	"CE":  VerdictCompileError,
+}
+
+// ParseExpectedOutcome parses an expected outcome made of clauses separated by commas, like "100 points",
+// "WA", "TLE on subtask 3" or "60 points, TLE on test 4, AC on subtask 1"
+func ParseExpectedOutcome(s string) (*ExpectedOutcome, error) {
+	out := &ExpectedOutcome{Score: -1}
+	clauses := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' })
+	for _, clause := range clauses {
+		fields := strings.Fields(clause)
+		if len(fields) == 0 {
+			continue
+		}
+		invalid := &Error{Code: EINVALID, Message: fmt.Sprintf("Invalid expected outcome %q", strings.TrimSpace(clause))}
+
+		if score, err := strconv.Atoi(strings.TrimSuffix(fields[0], "p")); err == nil {
+			if score < 0 || len(fields) > 2 || (len(fields) == 2 && !isPointsWord(fields[1])) {
+				return nil, invalid
+			}
+			if out.Score >= 0 {
+				return nil, &Error{Code: EINVALID, Message: "The expected score was given more than once"}
+			}
+			out.Score = score
+			continue
+		}
+
+		verdict, ok := expectableVerdicts[strings.ToUpper(fields[0])]
+		if !ok {
+			return nil, invalid
+		}
+		exp := ExpectedVerdict{Verdict: verdict}
+		switch len(fields) {
+		case 1:
+		case 4:
+			id, err := strconv.Atoi(fields[3])
+			if err != nil || id <= 0 || !strings.EqualFold(fields[1], "on") || verdict == VerdictCompileError {
+				return nil, invalid
+			}
+			switch strings.ToLower(fields[2]) {
+			case "subtask":
+				exp.SubTask = id
+			case "test":
+				exp.Test = id
+			default:
+				return nil, invalid
+			}
+		default:
+			return nil, invalid
+		}
+		out.Verdicts = append(out.Verdicts, exp)
+	}
+
+	if out.Score < 0 && len(out.Verdicts) == 0 {
+		return nil, &Error{Code: EINVALID, Message: "The expected outcome is empty"}
+	}
+	return out, nil
+}
+
+func isPointsWord(s string) bool {
+	switch strings.ToLower(s) {
+	case "p", "pts", "point", "points":
+		return true
+	}
+	return false
+}
+
+// SolutionOutcome is the outcome of judging a problem solution
+type SolutionOutcome struct {
+	CompileError bool
+	Score        int
+	// Verdicts holds the verdict of every test, by its visible ID
+	Verdicts map[int]VerdictCode
+	// SubTasks holds the visible IDs of the tests of every subtask, by the visible ID of the subtask
+	SubTasks map[int][]int
+}
+
+// Mismatches returns a description of every expectation that the outcome doesn't meet
+func (e *ExpectedOutcome) Mismatches(o *SolutionOutcome) []string {
+	expectsCE := false
+	for _, v := range e.Verdicts {
+		if v.Verdict == VerdictCompileError {
+			expectsCE = true
+		}
+	}
+	if o.CompileError || expectsCE {
+		if o.CompileError != expectsCE {
+			if expectsCE {
+				return []string{"expected a compilation error"}
+			}
+			return []string{"the solution didn't compile"}
+		}
+		return nil
+	}
+
+	var mismatches []string
+	if e.Score >= 0 && o.Score != e.Score {
+		mismatches = append(mismatches, fmt.Sprintf("expected %d points, got %d", e.Score, o.Score))
+	}
+	for _, v := range e.Verdicts {
+		var tests []int
+		switch {
+		case v.SubTask > 0:
+			ids, ok := o.SubTasks[v.SubTask]
+			if !ok {
+				mismatches = append(mismatches, fmt.Sprintf("subtask %d doesn't exist", v.SubTask))
+				continue
+			}
+			tests = ids
+		case v.Test > 0:
+			if _, ok := o.Verdicts[v.Test]; !ok {
+				mismatches = append(mismatches, fmt.Sprintf("test %d doesn't exist", v.Test))
+				continue
+			}
+			tests = []int{v.Test}
+		default:
+			for id := range o.Verdicts {
+				tests = append(tests, id)
+			}
+		}
+
+		found, all := false, true
+		for _, id := range tests {
+			if o.Verdicts[id] == v.Verdict {
+				found = true
+			} else {
+				all = false
+			}
+		}
+		if (v.Verdict == VerdictAccepted && !all) || (v.Verdict != VerdictAccepted && !found) {
+			mismatches = append(mismatches, "expected "+v.String())
+		}
+	}
+	return mismatches
+}
+
+// String describes the outcome, like "60 points; TLE on tests 4, 5; WA on test 7"
+func (o *SolutionOutcome) String() string {
+	if o.CompileError {
+		return "Compilation error"
+	}
+	byVerdict := make(map[VerdictCode][]int)
+	for id, v := range o.Verdicts {
+		if v != VerdictAccepted {
+			byVerdict[v] = append(byVerdict[v], id)
+		}
+	}
+	verdicts := make([]string, 0, len(byVerdict))
+	for v := range byVerdict {
+		verdicts = append(verdicts, string(v))
+	}
+	sort.Strings(verdicts)
+
+	parts := []string{fmt.Sprintf("%d points", o.Score)}
+	for _, v := range verdicts {
+		ids := byVerdict[VerdictCode(v)]
+		sort.Ints(ids)
+		strs := make([]string, 0, len(ids))
+		for _, id := range ids {
+			strs = append(strs, strconv.Itoa(id))
+		}
+		word := "test"
+		if len(ids) > 1 {
+			word = "tests"
+		}
+		parts = append(parts, fmt.Sprintf("%s on %s %s", v, word, strings.Join(strs, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
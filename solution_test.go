@@ -0,0 +1,78 @@
+package kilonova
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseExpectedOutcome(t *testing.T) {
+	var tests = []struct {
+		s   string
+		out *ExpectedOutcome
+	}{
+		{"100 points", &ExpectedOutcome{Score: 100}},
+		{"40p", &ExpectedOutcome{Score: 40}},
+		{"wa", &ExpectedOutcome{Score: -1, Verdicts: []ExpectedVerdict{{Verdict: VerdictWrongAnswer}}}},
+		{"60 points, TLE on subtask 3; AC on test 1", &ExpectedOutcome{Score: 60, Verdicts: []ExpectedVerdict{
+			{Verdict: VerdictTimeLimit, SubTask: 3},
+			{Verdict: VerdictAccepted, Test: 1},
+		}}},
+		{"", nil},
+		{"10 points, 20 points", nil},
+		{"TLE on subtask", nil},
+		{"CE on test 2", nil},
+		{"SE", nil},
+		{"-5 points", nil},
+	}
+
+	for _, test := range tests {
+		out, err := ParseExpectedOutcome(test.s)
+		if test.out == nil {
+			if err == nil {
+				t.Errorf("%q: expected an error", test.s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", test.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(out, test.out) {
+			t.Errorf("%q: wanted %+v, got %+v", test.s, test.out, out)
+		}
+	}
+}
+
+func TestOutcomeMismatches(t *testing.T) {
+	outcome := &SolutionOutcome{
+		Score:    60,
+		Verdicts: map[int]VerdictCode{1: VerdictAccepted, 2: VerdictAccepted, 3: VerdictTimeLimit, 4: VerdictWrongAnswer},
+		SubTasks: map[int][]int{1: {1, 2}, 2: {3, 4}},
+	}
+
+	var tests = []struct {
+		expected   string
+		mismatches int
+	}{
+		{"60 points, TLE on subtask 2, AC on subtask 1, WA on test 4", 0},
+		{"100 points", 1},
+		{"AC", 1},
+		{"MLE, TLE on subtask 1", 2},
+		{"RE on subtask 5, AC on test 9", 2},
+		{"CE", 1},
+	}
+
+	for _, test := range tests {
+		exp, err := ParseExpectedOutcome(test.expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m := exp.Mismatches(outcome); len(m) != test.mismatches {
+			t.Errorf("%q: wanted %d mismatches, got %q", test.expected, test.mismatches, m)
+		}
+	}
+
+	if s := outcome.String(); s != "60 points; TLE on test 3; WA on test 4" {
+		t.Errorf("Wrong outcome description %q", s)
+	}
+}
@@ -17,6 +17,64 @@ const (
 	StatusFinished Status = "finished"
 )
 
+// VerdictCode is the outcome of a subtest. The message shown to the user is stored separately, in SubTest.Verdict
+type VerdictCode string
+
+const (
+	VerdictNone         VerdictCode = ""
+	VerdictAccepted     VerdictCode = "AC"
+	VerdictWrongAnswer  VerdictCode = "WA"
+	VerdictPartial      VerdictCode = "PC"
+	VerdictTimeLimit    VerdictCode = "TLE"
+	VerdictMemoryLimit  VerdictCode = "MLE"
+	VerdictRuntimeError VerdictCode = "RE"
+	VerdictOutputLimit  VerdictCode = "OLE"
+	VerdictCompileError VerdictCode = "CE"
+	VerdictSystemError  VerdictCode = "SE"
+	VerdictCheckerFail  VerdictCode = "CF"
+	VerdictSkipped      VerdictCode = "SKIP"
+)
+
+// ScoreVerdict returns the verdict for a test that was given the specified percentage of its score
+func ScoreVerdict(score int) VerdictCode {
+	switch {
+	case score >= 100:
+		return VerdictAccepted
+	case score <= 0:
+		return VerdictWrongAnswer
+	default:
+		return VerdictPartial
+	}
+}
+
+// Priority decides the order in which the waiting submissions are evaluated
+type Priority int
+
+const (
+	PriorityBackground Priority = iota
+	PriorityRejudge
+	PriorityContest
+	PriorityLive
+)
+
+// Priorities holds all priorities, from the highest to the lowest
+var Priorities = []Priority{PriorityLive, PriorityContest, PriorityRejudge, PriorityBackground}
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLive:
+		return "live"
+	case PriorityContest:
+		return "contest"
+	case PriorityRejudge:
+		return "rejudge"
+	case PriorityBackground:
+		return "background"
+	default:
+		return fmt.Sprintf("priority_%d", int(p))
+	}
+}
+
 type Submission struct {
 	ID        int       `json:"id"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
@@ -29,14 +87,35 @@ type Submission struct {
 	CompileError   sql.NullBool   `db:"compile_error" json:"compile_error"`
 	CompileMessage sql.NullString `json:"compile_message,omitempty" db:"compile_message"`
 
-	Score   int  `json:"score"`
-	Visible bool `json:"visible"`
-	Quality bool `json:"quality"`
+	Score    int      `json:"score"`
+	Visible  bool     `json:"visible"`
+	Quality  bool     `json:"quality"`
+	Priority Priority `json:"priority"`
+
+	// WorkerID and LeaseExpiresAt are set while a grader holds the submission
+	WorkerID       sql.NullString `db:"worker_id" json:"-"`
+	LeaseExpiresAt sql.NullTime   `db:"lease_expires_at" json:"-"`
+	// Attempts counts the times the submission was claimed without being finished or released.
+	// It is reset when the submission is put back in the queue by a reevaluation
+	Attempts int `json:"-"`
+}
+
+// ClaimRequest describes the submissions a grader wants to take from the queue
+type ClaimRequest struct {
+	// WorkerID must be unique for every grader
+	WorkerID string
+	Limit    int
+	// Lease is how long the submissions are held before they are put back in the queue, if the lease is not renewed
+	Lease time.Duration
+	// If Priority is set, only the submissions with that priority are claimed.
+	// Otherwise, the submissions with the highest priority are claimed first
+	Priority *Priority
 }
 
 type SubmissionUpdate struct {
-	Status Status
-	Score  *int
+	Status   Status
+	Score    *int
+	Priority *Priority
 
 	CompileError   *bool
 	CompileMessage *string
@@ -57,21 +136,29 @@ type SubmissionFilter struct {
 	CompileError *bool   `json:"compile_error"`
 	Quality      *bool   `json:"quality"`
 
+	// VerdictCode matches the submissions with at least one subtest with the verdict
+	VerdictCode *VerdictCode `json:"verdict_code"`
+
 	Limit  int `json:"limit"`
 	Offset int `json:"offset"`
 }
 
 type SubTest struct {
-	ID           int       `json:"id"`
-	CreatedAt    time.Time `db:"created_at" json:"created_at"`
-	Done         bool      `json:"done"`
-	Verdict      string    `json:"verdict"`
-	Time         float64   `json:"time"`
-	Memory       int       `json:"memory"`
-	Score        int       `json:"score"`
-	TestID       int       `db:"test_id" json:"test_id"`
-	UserID       int       `db:"user_id" json:"user_id"`
-	SubmissionID int       `db:"submission_id" json:"submission_id"`
+	ID           int         `json:"id"`
+	CreatedAt    time.Time   `db:"created_at" json:"created_at"`
+	Done         bool        `json:"done"`
+	Verdict      string      `json:"verdict"`
+	VerdictCode  VerdictCode `db:"verdict_code" json:"verdict_code"`
+	Time         float64     `json:"time"`
+	Memory       int         `json:"memory"`
+	Score        int         `json:"score"`
+	TestID       int         `db:"test_id" json:"test_id"`
+	UserID       int         `db:"user_id" json:"user_id"`
+	SubmissionID int         `db:"submission_id" json:"submission_id"`
+
+	// Only used for interactive problems
+	InteractorTime   float64 `db:"interactor_time" json:"interactor_time"`
+	InteractorMemory int     `db:"interactor_memory" json:"interactor_memory"`
 }
 
 type SubTestUpdate struct {
@@ -80,6 +167,11 @@ type SubTestUpdate struct {
 	Score   *int
 	Verdict *string
 	Done    *bool
+
+	VerdictCode *VerdictCode
+
+	InteractorTime   *float64
+	InteractorMemory *int
 }
 
 type SubmissionService interface {
@@ -94,8 +186,25 @@ type SubmissionService interface {
 	BulkUpdateSubmissions(ctx context.Context, filter SubmissionFilter, upd SubmissionUpdate) error
 	DeleteSubmission(ctx context.Context, id int) error
 
+	// ClaimSubmissions atomically marks up to req.Limit waiting submissions as working and leases them to the worker, counting an attempt for each of them
+	ClaimSubmissions(ctx context.Context, req ClaimRequest) ([]*Submission, error)
+	// RenewSubmissionLease extends the lease of a submission. It fails if the worker doesn't hold the submission anymore
+	RenewSubmissionLease(ctx context.Context, id int, workerID string, lease time.Duration) error
+	// ReleaseSubmission puts a submission held by the worker back in the queue, without counting the attempt
+	ReleaseSubmission(ctx context.Context, id int, workerID string) error
+	// RequeueExpiredSubmissions puts back in the queue the working submissions whose lease expired (or that have no lease), returning their count
+	RequeueExpiredSubmissions(ctx context.Context) (int, error)
+	// QueueNotify returns a channel that is closed when submissions are added to the queue.
+	// A new channel must be requested after every notification
+	QueueNotify() <-chan struct{}
+
+	// SetSubTaskScores replaces the points obtained by the submission on each subtask, given by subtask ID
+	SetSubTaskScores(ctx context.Context, subID int, scores map[int]int) error
+
+	// MaxScore and MaxScores return the score of the user on the problems, according to their scoring policies
 	MaxScore(ctx context.Context, userid, problemid int) int
 	MaxScores(ctx context.Context, userid int, problemids []int) map[int]int
+	// SolvedProblems returns the problems on which the user has the FullScore
 	SolvedProblems(ctx context.Context, userid int) ([]int, error)
 }
 
@@ -49,6 +49,11 @@ type TestService interface {
 
 	OrphanProblemTests(ctx context.Context, problemID int) error
 	OrphanProblemTest(ctx context.Context, problemID int, testVID int) error
+	// ReplaceProblemTests orphans the tests of the problem and un-orphans the ones with the IDs, in a single transaction.
+	// If subTasks is not nil, they replace the subtasks of the problem in the same transaction and their IDs are set
+	ReplaceProblemTests(ctx context.Context, problemID int, testIDs []int, subTasks []*SubTask) error
+	// DeleteTests removes tests that were never used, like the ones created by a failed import
+	DeleteTests(ctx context.Context, ids []int) error
 	BiggestVID(ctx context.Context, problemID int) (int, error)
 }
 
@@ -0,0 +1,166 @@
+package kilonova
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ScriptTest is a test created by a test script, its input is the output of the generator called with Args
+type ScriptTest struct {
+	VisibleID int
+	Args      []string
+	Score     int
+	// Line is the line of the script where the test is declared
+	Line int
+}
+
+// ScriptSubTask groups the tests declared after a subtask line of a test script
+type ScriptSubTask struct {
+	VisibleID int
+	Score     int
+	// Tests holds the visible IDs of the tests
+	Tests []int
+}
+
+// TestScript is the parsed form of the script used to generate the tests of a problem
+type TestScript struct {
+	Tests    []ScriptTest
+	SubTasks []ScriptSubTask
+}
+
+// ParseTestScript parses a test script. Every line is one of:
+//   - empty or starting with #, which are ignored
+//   - `subtask <score>`, which starts a new subtask, containing the tests declared after it
+//   - `<generator> [args...] > <id>.in [score]`, which creates test <id> from the output of the generator called with the arguments
+//
+// The name of the generator is not used, since a problem has a single generator.
+// The tests without a score get an equal part of the points of their subtask (or of 100 points, if there are no subtasks)
+// that are not given to the other tests, split with SplitScore
+func ParseTestScript(s string) (*TestScript, error) {
+	script := &TestScript{}
+	seen := make(map[int]int)
+	// scored holds the visible IDs of the tests with a score in the script, which can be 0
+	scored := make(map[int]bool)
+	for i, line := range strings.Split(s, "\n") {
+		lineNum := i + 1
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		lineErr := func(format string, args ...interface{}) error {
+			return &Error{Code: EINVALID, Message: fmt.Sprintf("Line %d: %s", lineNum, fmt.Sprintf(format, args...))}
+		}
+
+		if fields[0] == "subtask" {
+			if len(fields) != 2 {
+				return nil, lineErr("expected `subtask <score>`")
+			}
+			score, err := strconv.Atoi(fields[1])
+			if err != nil || score < 0 {
+				return nil, lineErr("invalid subtask score %q", fields[1])
+			}
+			script.SubTasks = append(script.SubTasks, ScriptSubTask{VisibleID: len(script.SubTasks) + 1, Score: score})
+			continue
+		}
+
+		redirect := -1
+		for j, field := range fields {
+			if strings.ContainsAny(field, "<|&;") || (strings.Contains(field, ">") && field != ">") {
+				return nil, lineErr("unsupported shell syntax in %q", field)
+			}
+			if field == ">" {
+				if redirect >= 0 {
+					return nil, lineErr("the output is redirected more than once")
+				}
+				redirect = j
+			}
+		}
+		if redirect < 0 {
+			return nil, lineErr("missing the redirect to the test file (`> <id>.in`)")
+		}
+		if redirect == 0 {
+			return nil, lineErr("missing the generator name")
+		}
+		rest := fields[redirect+1:]
+		if len(rest) == 0 || len(rest) > 2 {
+			return nil, lineErr("expected `> <id>.in [score]` at the end of the line")
+		}
+		id, err := strconv.Atoi(strings.TrimSuffix(rest[0], ".in"))
+		if err != nil || !strings.HasSuffix(rest[0], ".in") || id <= 0 {
+			return nil, lineErr("invalid test file %q, it must be named <id>.in", rest[0])
+		}
+		if prev, ok := seen[id]; ok {
+			return nil, lineErr("test %d was already declared on line %d", id, prev)
+		}
+		seen[id] = lineNum
+
+		test := ScriptTest{VisibleID: id, Args: fields[1:redirect], Line: lineNum}
+		if len(rest) == 2 {
+			test.Score, err = strconv.Atoi(rest[1])
+			if err != nil || test.Score < 0 {
+				return nil, lineErr("invalid test score %q", rest[1])
+			}
+			scored[id] = true
+		}
+		script.Tests = append(script.Tests, test)
+		if len(script.SubTasks) > 0 {
+			stk := &script.SubTasks[len(script.SubTasks)-1]
+			stk.Tests = append(stk.Tests, id)
+		}
+	}
+
+	if len(script.Tests) == 0 {
+		return nil, &Error{Code: EINVALID, Message: "The test script doesn't create any test"}
+	}
+	inSubTasks := 0
+	for _, stk := range script.SubTasks {
+		if len(stk.Tests) == 0 {
+			return nil, &Error{Code: EINVALID, Message: fmt.Sprintf("Subtask %d has no tests", stk.VisibleID)}
+		}
+		inSubTasks += len(stk.Tests)
+	}
+	if len(script.SubTasks) > 0 && inSubTasks < len(script.Tests) {
+		return nil, &Error{Code: EINVALID, Message: "All tests must be in a subtask, if the script has subtasks"}
+	}
+
+	if len(script.SubTasks) == 0 {
+		scoreRemaining(script.Tests, scored, nil, 100)
+	}
+	for _, stk := range script.SubTasks {
+		scoreRemaining(script.Tests, scored, stk.Tests, stk.Score)
+	}
+	sort.SliceStable(script.Tests, func(i, j int) bool { return script.Tests[i].VisibleID < script.Tests[j].VisibleID })
+	return script, nil
+}
+
+// scoreRemaining splits the points left from total between the tests that are not scored (only the ones with the visible IDs in ids, if it is not nil)
+func scoreRemaining(tests []ScriptTest, scored map[int]bool, ids []int, total int) {
+	var unscored []int
+	for i, test := range tests {
+		if ids != nil && !containsInt(ids, test.VisibleID) {
+			continue
+		}
+		if scored[test.VisibleID] {
+			total -= test.Score
+		} else {
+			unscored = append(unscored, i)
+		}
+	}
+	if total < 0 {
+		total = 0
+	}
+	for j, score := range SplitScore(total, len(unscored)) {
+		tests[unscored[j]].Score = score
+	}
+}
+
+func containsInt(list []int, x int) bool {
+	for _, v := range list {
+		if v == x {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package kilonova
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTestScript(t *testing.T) {
+	script, err := ParseTestScript(`# small tests
+subtask 30
+gen 10 1 > 2.in
+gen 10 2 > 1.in
+
+subtask 70
+gen 100000 3 > 3.in 5
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTests := []ScriptTest{
+		{VisibleID: 1, Args: []string{"10", "2"}, Score: 15, Line: 4},
+		{VisibleID: 2, Args: []string{"10", "1"}, Score: 15, Line: 3},
+		{VisibleID: 3, Args: []string{"100000", "3"}, Score: 5, Line: 7},
+	}
+	if !reflect.DeepEqual(script.Tests, wantTests) {
+		t.Errorf("Wrong tests %+v", script.Tests)
+	}
+	wantStks := []ScriptSubTask{
+		{VisibleID: 1, Score: 30, Tests: []int{2, 1}},
+		{VisibleID: 2, Score: 70, Tests: []int{3}},
+	}
+	if !reflect.DeepEqual(script.SubTasks, wantStks) {
+		t.Errorf("Wrong subtasks %+v", script.SubTasks)
+	}
+
+	script, err = ParseTestScript("gen > 1.in\ngen > 2.in 40\ngen > 3.in\ngen > 4.in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, score := range []int{20, 40, 20, 20} {
+		if script.Tests[i].Score != score {
+			t.Errorf("Test %d: wanted score %d, got %d", i+1, score, script.Tests[i].Score)
+		}
+	}
+
+	// Tests can be worth 0 points, when they are scored so or there are more tests than points
+	script, err = ParseTestScript("subtask 1\ngen > 1.in\ngen > 2.in 0\ngen > 3.in")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, score := range []int{1, 0, 0} {
+		if script.Tests[i].Score != score {
+			t.Errorf("Test %d: wanted score %d, got %d", i+1, score, script.Tests[i].Score)
+		}
+	}
+
+	var bad = []struct {
+		script string
+		err    string
+	}{
+		{"", "doesn't create any test"},
+		{"gen 1 2", "Line 1: missing the redirect"},
+		{"gen 1 > 1.in\n\ngen 2 > 1.in", "Line 3: test 1 was already declared on line 1"},
+		{"gen 1 >1.in", "Line 1: unsupported shell syntax"},
+		{"gen 1 | gen 2 > 1.in", "Line 1: unsupported shell syntax"},
+		{"gen 1 > a.in", "Line 1: invalid test file"},
+		{"gen 1 > 1.in -1", "Line 1: invalid test score"},
+		{"> 1.in", "Line 1: missing the generator name"},
+		{"subtask x", "Line 1: invalid subtask score"},
+		{"gen > 1.in\nsubtask 100\ngen > 2.in", "must be in a subtask"},
+		{"subtask 10\nsubtask 20\ngen > 1.in", "Subtask 1 has no tests"},
+	}
+	for _, test := range bad {
+		_, err := ParseTestScript(test.script)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: wanted error containing %q, got %v", test.script, test.err, err)
+		}
+	}
+}
@@ -213,6 +213,10 @@ export class SubmissionManager {
 				
 				time.innerHTML = Math.floor(test.subtest.time * 1000) + " ms";
 				mem.innerHTML = bundled.sizeFormatter(test.subtest.memory*1024, 1, true)
+				if(this.problemEditor && test.subtest.interactor_time > 0) {
+					time.title = "Interactor: " + Math.floor(test.subtest.interactor_time * 1000) + " ms"
+					mem.title = "Interactor: " + bundled.sizeFormatter(test.subtest.interactor_memory*1024, 1, true)
+				}
 
 				score.classList.add("text-black")
 				score.style = "background-color:" + bundled.getGradient(test.subtest.score, 100) + ";"
@@ -13,9 +13,11 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"sort"
 	"strconv"
 
 	"github.com/KiloProjects/kilonova"
+	"github.com/KiloProjects/kilonova/eval"
 	"github.com/KiloProjects/kilonova/internal/config"
 )
 
@@ -37,6 +39,8 @@ var (
 	editIndex   = parse("edit/index.html")
 	editDesc    = parse("edit/desc.html")
 	editChecker = parse("edit/checker.html")
+	editSols    = parse("edit/solutions.html")
+	editGen     = parse("edit/generator.html")
 
 	testAdd    = parse("edit/testAdd.html", "edit/testTopbar.html")
 	testEdit   = parse("edit/testEdit.html", "edit/testTopbar.html")
@@ -78,11 +82,42 @@ type ProblemParams struct {
 	Languages map[string]config.Language
 }
 
+// LanguageLimit holds the effective limits of a problem for a language
+type LanguageLimit struct {
+	Language    string
+	TimeLimit   float64
+	MemoryLimit int
+}
+
+// LanguageLimits returns the effective limits of the enabled languages whose limits differ from the ones of the problem
+func (p *ProblemParams) LanguageLimits() []LanguageLimit {
+	var limits []LanguageLimit
+	for name, lang := range p.Languages {
+		if lang.Disabled {
+			continue
+		}
+		lim := eval.ProblemLimits(p.Problem, name)
+		if lim.TimeLimit == p.Problem.TimeLimit && lim.MemoryLimit == p.Problem.MemoryLimit {
+			continue
+		}
+		limits = append(limits, LanguageLimit{Language: lang.Printable, TimeLimit: lim.TimeLimit, MemoryLimit: lim.MemoryLimit})
+	}
+	sort.Slice(limits, func(i, j int) bool { return limits[i].Language < limits[j].Language })
+	return limits
+}
+
 type ProblemEditParams struct {
 	User    *kilonova.User
 	Problem *kilonova.Problem
 }
 
+// ProblemLangEditParams is used by the edit pages that need the languages, for code written by the problem authors
+type ProblemLangEditParams struct {
+	User      *kilonova.User
+	Problem   *kilonova.Problem
+	Languages map[string]config.Language
+}
+
 type ProblemListParams struct {
 	User        *kilonova.User
 	ProblemList *kilonova.ProblemList
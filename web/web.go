@@ -184,6 +184,12 @@ func (rt *Web) Handler() http.Handler {
 					r.Get("/checker", func(w http.ResponseWriter, r *http.Request) {
 						editChecker.Execute(w, &ProblemEditParams{util.User(r), util.Problem(r)})
 					})
+					r.Get("/solutions", func(w http.ResponseWriter, r *http.Request) {
+						editSols.Execute(w, &ProblemLangEditParams{util.User(r), util.Problem(r), config.Languages})
+					})
+					r.Get("/generator", func(w http.ResponseWriter, r *http.Request) {
+						editGen.Execute(w, &ProblemLangEditParams{util.User(r), util.Problem(r), config.Languages})
+					})
 					r.Route("/test", func(r chi.Router) {
 						r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 							testScores.Execute(w, &TestEditParams{util.User(r), util.Problem(r), &kilonova.Test{VisibleID: -2}, rt.tserv, rt.dm})